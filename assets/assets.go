@@ -0,0 +1,8 @@
+package assets
+
+import "embed"
+
+// HTML - шаблоны страниц панели мониторинга html/*.tmpl.
+//
+//go:embed html/*.tmpl
+var HTML embed.FS
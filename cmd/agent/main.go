@@ -14,7 +14,7 @@ import (
 func main() {
 	agent := app.NewAgent()
 	ctx := context.Background()
-	metric := &metric.Metrics{}
+	metric := metric.NewDefaultRegistry()
 
 	go agent.Poll(ctx, metric)
 	go agent.Report(ctx, metric)
@@ -1,12 +1,107 @@
 package adapter
 
-import "github.com/a-x-a/go-metric/internal/models/metric"
+import (
+	"encoding/json"
+	"time"
 
-type RequestMetric struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	"github.com/a-x-a/go-metric/internal/models/metric"
+)
+
+type (
+	RequestMetric struct {
+		ID     string            `json:"id"`               // имя метрики
+		MType  string            `json:"type"`             // параметр, принимающий значение gauge или counter
+		Delta  *int64            `json:"delta,omitempty"`  // значение метрики в случае передачи counter
+		Value  *float64          `json:"value,omitempty"`  // значение метрики в случае передачи gauge
+		Labels map[string]string `json:"labels,omitempty"` // метки метрики key=value
+
+		Sum       *float64   `json:"sum,omitempty"`       // сумма наблюдений в случае передачи histogram, summary или sketch
+		Count     *uint64    `json:"count,omitempty"`     // количество наблюдений histogram, summary или sketch, оценка количества элементов set
+		Bounds    []float64  `json:"bounds,omitempty"`    // верхние границы корзин histogram без +Inf
+		Buckets   []uint64   `json:"buckets,omitempty"`   // количество наблюдений в корзинах histogram, последняя корзина - +Inf
+		Quantiles []Quantile `json:"quantiles,omitempty"` // квантили summary
+		Sketch    *Sketch    `json:"sketch,omitempty"`    // корзины sketch
+		Members   []string   `json:"members,omitempty"`   // элементы, добавляемые в set
+		Registers []byte     `json:"registers,omitempty"` // регистры HyperLogLog set в base64
+		TTL       string     `json:"ttl,omitempty"`       // срок хранения метрики без обновлений, например 10m
+	}
+
+	// Sketch - корзины квантильного эскиза sketch.
+	Sketch struct {
+		RelativeAccuracy float64     `json:"accuracy"`           // относительная погрешность квантилей от 0 до 1
+		Min              float64     `json:"min"`                // минимальное наблюдение
+		Max              float64     `json:"max"`                // максимальное наблюдение
+		Zero             uint64      `json:"zero,omitempty"`     // количество нулевых наблюдений
+		Positive         *SketchBins `json:"positive,omitempty"` // корзины положительных наблюдений
+		Negative         *SketchBins `json:"negative,omitempty"` // корзины модулей отрицательных наблюдений
+	}
+
+	// SketchBins - корзины эскиза подряд начиная с индекса Offset.
+	SketchBins struct {
+		Offset int      `json:"offset"` // индекс первой корзины
+		Counts []uint64 `json:"counts"` // количество наблюдений в корзинах
+	}
+
+	// Quantile - значение квантиля summary.
+	Quantile struct {
+		Quantile float64 `json:"quantile"` // уровень квантиля от 0 до 1
+		Value    float64 `json:"value"`    // значение квантиля
+	}
+
+	// ResponseHistory - история значений метрики.
+	ResponseHistory struct {
+		ID     string         `json:"id"`     // имя метрики
+		MType  string         `json:"type"`   // параметр, принимающий значение gauge или counter
+		Points []HistoryPoint `json:"points"` // значения метрики в порядке возрастания времени
+	}
+
+	// ResponseMetrics - страница метрик.
+	ResponseMetrics struct {
+		Metrics    []RequestMetric `json:"metrics"`               // метрики в порядке возрастания идентификаторов
+		NextCursor string          `json:"next_cursor,omitempty"` // курсор следующей страницы, пустой для последней
+	}
+
+	// ResponseAgent - агент, отправлявший метрики на сервер.
+	ResponseAgent struct {
+		ID       string    `json:"id"`        // идентификатор агента
+		LastSeen time.Time `json:"last_seen"` // время последнего получения метрик от агента
+	}
+
+	// Frame - сообщение WebSocket с набором метрик.
+	Frame struct {
+		Seq     uint64          `json:"seq"`            // номер сообщения, возвращаемый в подтверждении
+		Hash    string          `json:"hash,omitempty"` // подпись HMAC-SHA256 поля metrics, если задан ключ подписи
+		Metrics json.RawMessage `json:"metrics"`        // метрики в формате /updates/
+	}
+
+	// Ack - подтверждение обработки сообщения WebSocket.
+	Ack struct {
+		Seq   uint64 `json:"seq"`             // номер подтверждаемого сообщения
+		Code  int    `json:"code"`            // код результата, как у соответствующего HTTP-запроса
+		Error string `json:"error,omitempty"` // описание ошибки
+	}
+
+	// HistoryPoint - значение метрики в момент времени.
+	HistoryPoint struct {
+		Timestamp time.Time `json:"timestamp"`       // время получения значения
+		Delta     *int64    `json:"delta,omitempty"` // значение метрики в случае counter
+		Value     *float64  `json:"value,omitempty"` // значение метрики в случае gauge
+	}
+)
+
+func NewHistoryPoint(timestamp time.Time, value metric.Metric) HistoryPoint {
+	point := HistoryPoint{Timestamp: timestamp}
+
+	switch v := value.(type) {
+	case metric.Counter:
+		val := int64(v)
+		point.Delta = &val
+	case metric.Gauge:
+		val := float64(v)
+		point.Value = &val
+	}
+
+	return point
 }
 
 func NewUpdateRequestMetricCounter(name string, value metric.Counter) RequestMetric {
@@ -29,6 +124,106 @@ func NewUpdateRequestMetricGauge(name string, value metric.Gauge) RequestMetric
 	}
 }
 
+func NewUpdateRequestMetricHistogram(name string, value metric.Histogram) RequestMetric {
+	sum, count := value.Sum, value.Count
+
+	return RequestMetric{
+		ID:      name,
+		MType:   value.Kind(),
+		Sum:     &sum,
+		Count:   &count,
+		Bounds:  append([]float64(nil), value.Bounds...),
+		Buckets: append([]uint64(nil), value.Buckets...),
+	}
+}
+
+func NewUpdateRequestMetricSummary(name string, value metric.Summary) RequestMetric {
+	sum, count := value.Sum, value.Count
+
+	m := RequestMetric{
+		ID:    name,
+		MType: value.Kind(),
+		Sum:   &sum,
+		Count: &count,
+	}
+
+	for _, q := range value.Quantiles {
+		m.Quantiles = append(m.Quantiles, Quantile{Quantile: q.Quantile, Value: q.Value})
+	}
+
+	return m
+}
+
+func NewUpdateRequestMetricSketch(name string, value metric.Sketch) RequestMetric {
+	sum, count := value.Sum, value.Count
+
+	return RequestMetric{
+		ID:    name,
+		MType: value.Kind(),
+		Sum:   &sum,
+		Count: &count,
+		Sketch: &Sketch{
+			RelativeAccuracy: value.RelativeAccuracy,
+			Min:              value.Min,
+			Max:              value.Max,
+			Zero:             value.Zero,
+			Positive:         newSketchBins(value.Positive),
+			Negative:         newSketchBins(value.Negative),
+		},
+	}
+}
+
+func NewUpdateRequestMetricSet(name string, value metric.Set) RequestMetric {
+	count := value.Estimate()
+
+	return RequestMetric{
+		ID:        name,
+		MType:     value.Kind(),
+		Count:     &count,
+		Registers: append([]byte(nil), value.Registers...),
+	}
+}
+
+// ToMetric - возвращает значение эскиза с суммой sum и количеством наблюдений count.
+// Если корзины выходят за допустимые для погрешности индексы, то возвращается
+// ошибка metric.ErrorInvalidSketch.
+func (s Sketch) ToMetric(sum float64, count uint64) (metric.Sketch, error) {
+	value := metric.Sketch{
+		RelativeAccuracy: s.RelativeAccuracy,
+		Zero:             s.Zero,
+		Count:            count,
+		Sum:              sum,
+		Min:              s.Min,
+		Max:              s.Max,
+	}
+
+	var err error
+
+	if s.Positive != nil {
+		if value.Positive, err = metric.NewSketchBins(s.RelativeAccuracy, s.Positive.Offset, s.Positive.Counts); err != nil {
+			return metric.Sketch{}, err
+		}
+	}
+
+	if s.Negative != nil {
+		if value.Negative, err = metric.NewSketchBins(s.RelativeAccuracy, s.Negative.Offset, s.Negative.Counts); err != nil {
+			return metric.Sketch{}, err
+		}
+	}
+
+	return value, nil
+}
+
+func newSketchBins(bins metric.SketchBins) *SketchBins {
+	if len(bins) == 0 {
+		return nil
+	}
+
+	offset, counts := bins.Dense()
+
+	return &SketchBins{Offset: offset, Counts: counts}
+}
+
 func NewGetRequestMetricCounter(name string) RequestMetric {
 	return RequestMetric{
 		ID:    name,
@@ -42,3 +237,57 @@ func NewGetRequestMetricGauge(name string) RequestMetric {
 		MType: string(metric.KindGauge),
 	}
 }
+
+// NewUpdateRequestMetrics - преобразует метрики агента в формат запроса к серверу.
+// Метрики неподдерживаемых типов пропускаются.
+func NewUpdateRequestMetrics(metrics []metric.NamedMetric) []RequestMetric {
+	requestMetrics := make([]RequestMetric, 0, len(metrics))
+
+	for _, m := range metrics {
+		var requestMetric RequestMetric
+
+		switch v := m.Value.(type) {
+		case metric.Counter:
+			requestMetric = NewUpdateRequestMetricCounter(m.Name, v)
+		case metric.Gauge:
+			requestMetric = NewUpdateRequestMetricGauge(m.Name, v)
+		default:
+			continue
+		}
+
+		if len(m.Labels) > 0 {
+			requestMetric.Labels = m.Labels
+		}
+
+		requestMetrics = append(requestMetrics, requestMetric)
+	}
+
+	return requestMetrics
+}
+
+// ToNamedMetrics - преобразует метрики запроса, полученные из NewUpdateRequestMetrics,
+// обратно в метрики агента. Если тип метрики не counter или gauge либо значение
+// не задано, то возвращается ошибка metric.ErrorInvalidMetricKind.
+func ToNamedMetrics(requestMetrics []RequestMetric) ([]metric.NamedMetric, error) {
+	metrics := make([]metric.NamedMetric, 0, len(requestMetrics))
+
+	for _, m := range requestMetrics {
+		nm := metric.NamedMetric{Name: m.ID}
+		if len(m.Labels) > 0 {
+			nm.Labels = m.Labels
+		}
+
+		switch {
+		case metric.MetricKind(m.MType) == metric.KindCounter && m.Delta != nil:
+			nm.Value = metric.Counter(*m.Delta)
+		case metric.MetricKind(m.MType) == metric.KindGauge && m.Value != nil:
+			nm.Value = metric.Gauge(*m.Value)
+		default:
+			return nil, metric.ErrorInvalidMetricKind
+		}
+
+		metrics = append(metrics, nm)
+	}
+
+	return metrics, nil
+}
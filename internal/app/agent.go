@@ -2,25 +2,135 @@ package app
 
 import (
 	"context"
+	"crypto/rsa"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/a-x-a/go-metric/internal/config"
+	"github.com/a-x-a/go-metric/internal/encryptor"
+	"github.com/a-x-a/go-metric/internal/identity"
 	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/outbox"
+	"github.com/a-x-a/go-metric/internal/retry"
 	"github.com/a-x-a/go-metric/internal/sender"
 )
 
 type (
 	agent struct {
 		Config config.AgentConfig
+		sender metricsSender
+		// outbox - очередь неотправленных метрик, если nil, то метрики отправляются напрямую.
+		outbox *outbox.Outbox
+		// counters - учёт доставленных на сервер значений счётчиков.
+		counters *counterDeltas
+		// labels - метки агента, добавляемые ко всем отправляемым метрикам.
+		labels metric.Labels
+	}
+
+	metricsSender interface {
+		SendMetrics(metrics []metric.NamedMetric) error
+		SendMetricsBatch(metrics []metric.NamedMetric) error
 	}
 )
 
 func NewAgent() *agent {
-	return &agent{Config: config.NewAgentConfig()}
+	app, err := newAgent(config.NewAgentConfig())
+	if err != nil {
+		panic(err)
+	}
+
+	return app
+}
+
+func newAgent(cfg config.AgentConfig) (*agent, error) {
+	var publicKey *rsa.PublicKey
+	if len(cfg.CryptoKey) > 0 {
+		key, err := encryptor.LoadPublicKey(cfg.CryptoKey)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey = key
+	}
+
+	delays, err := retry.ParseDelays(cfg.RetryDelays)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := retry.New(delays...)
+
+	var ms metricsSender
+
+	switch cfg.Transport {
+	case config.TransportHTTP, "":
+		ms = sender.NewSender(cfg.ServerAddress, cfg.PollInterval, cfg.Key, publicKey, backoff)
+	case config.TransportGRPC:
+		if publicKey != nil {
+			return nil, fmt.Errorf("transport %q doesn't support encryption", cfg.Transport)
+		}
+
+		gs, err := sender.NewGRPCSender(cfg.ServerAddress, cfg.PollInterval, cfg.Key, backoff)
+		if err != nil {
+			return nil, err
+		}
+
+		ms = gs
+	case config.TransportWebSocket:
+		if publicKey != nil {
+			return nil, fmt.Errorf("transport %q doesn't support encryption", cfg.Transport)
+		}
+
+		ms = sender.NewWSSender(cfg.ServerAddress, cfg.PollInterval, cfg.Key, backoff)
+	default:
+		return nil, fmt.Errorf("unknown transport %q", cfg.Transport)
+	}
+
+	labels, err := agentLabels(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ob *outbox.Outbox
+	if len(cfg.OutboxDir) > 0 {
+		ob, err = outbox.New(cfg.OutboxDir, cfg.OutboxMaxBatches, cfg.OutboxMaxAge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &agent{
+		Config:   cfg,
+		sender:   ms,
+		outbox:   ob,
+		counters: newCounterDeltas(),
+		labels:   labels,
+	}, nil
 }
 
-func (app *agent) Poll(ctx context.Context, metrics *metric.Metrics) {
+// agentLabels - возвращает метки агента: идентификатор агента и имя его хоста.
+func agentLabels(cfg config.AgentConfig) (metric.Labels, error) {
+	id, err := identity.Resolve(cfg.ID, cfg.IDFile)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := metric.Labels{metric.InstanceLabel: id, metric.HostLabel: hostname}
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+func (app *agent) Poll(ctx context.Context, metrics *metric.Registry) {
 	ticker := time.NewTicker(app.Config.PollInterval)
 	defer ticker.Stop()
 
@@ -34,19 +144,121 @@ func (app *agent) Poll(ctx context.Context, metrics *metric.Metrics) {
 	}
 }
 
-func (app *agent) Report(ctx context.Context, metrics *metric.Metrics) {
+// Report - периодически передаёт снимок метрик пулу из RateLimit отправителей,
+// ограничивая количество одновременных запросов к серверу.
+// Значения счётчиков в снимке заменяются их приростом с предыдущего снимка.
+// Если все отправители заняты и очередь заполнена, то снимок пропускается:
+// следующий снимок содержит актуальные значения gauge и прирост счётчиков
+// с учётом пропущенного снимка.
+func (app *agent) Report(ctx context.Context, metrics *metric.Registry) {
+	workers := app.Config.RateLimit
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan []metric.NamedMetric, workers)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.sendWorker(jobs)
+		}()
+	}
+
 	ticker := time.NewTicker(app.Config.ReportInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			err := sender.SendMetrics(app.Config.ServerAddress, app.Config.PollInterval, *metrics)
-			if err != nil {
-				fmt.Println(err)
+			snapshot := app.counters.take(withLabels(metrics.Collect(), app.labels))
+
+			select {
+			case jobs <- snapshot:
+			default:
+				app.counters.nack(snapshot)
+				fmt.Println("metrics report skipped: all senders are busy")
 			}
 		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
 			return
 		}
 	}
 }
+
+// withLabels - добавляет метки labels к метрикам, не переопределяя собственные метки метрик.
+func withLabels(metrics []metric.NamedMetric, labels metric.Labels) []metric.NamedMetric {
+	if len(labels) == 0 {
+		return metrics
+	}
+
+	for i, m := range metrics {
+		merged := make(metric.Labels, len(labels)+len(m.Labels))
+		for k, v := range labels {
+			merged[k] = v
+		}
+
+		for k, v := range m.Labels {
+			merged[k] = v
+		}
+
+		metrics[i].Labels = merged
+	}
+
+	return metrics
+}
+
+// sendWorker - отправляет снимки метрик из очереди jobs, пока она не будет закрыта.
+// Если задана очередь неотправленных метрик, то снимок сначала сохраняется в неё,
+// а затем отправляется вместе с ранее неотправленными снимками в порядке поступления.
+// Прирост счётчиков считается доставленным после отправки на сервер
+// или сохранения в очередь неотправленных метрик.
+func (app *agent) sendWorker(jobs <-chan []metric.NamedMetric) {
+	for metrics := range jobs {
+		if app.outbox != nil {
+			err := app.outbox.Push(metrics)
+			if err == nil {
+				app.counters.ack(metrics)
+
+				if err := app.outbox.Replay(app.deliver); err != nil {
+					fmt.Println(err)
+				}
+
+				continue
+			}
+
+			fmt.Println(err)
+		}
+
+		n, err := app.deliver(metrics)
+		app.counters.ack(metrics[:n])
+		app.counters.nack(metrics[n:])
+
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// deliver - отправляет метрики на сервер и возвращает количество доставленных метрик.
+// При отправке по одной метрике отправка останавливается на первой ошибке.
+func (app *agent) deliver(metrics []metric.NamedMetric) (int, error) {
+	if app.Config.Batch {
+		if err := app.sender.SendMetricsBatch(metrics); err != nil {
+			return 0, err
+		}
+
+		return len(metrics), nil
+	}
+
+	for i, m := range metrics {
+		if err := app.sender.SendMetrics([]metric.NamedMetric{m}); err != nil {
+			return i, err
+		}
+	}
+
+	return len(metrics), nil
+}
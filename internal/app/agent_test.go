@@ -2,22 +2,43 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/config"
+	"github.com/a-x-a/go-metric/internal/handler"
 	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/outbox"
+	"github.com/a-x-a/go-metric/internal/retry"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/storage"
 )
 
 func TestNewAgent(t *testing.T) {
 	require := require.New(t)
 
 	t.Run("create new agent", func(t *testing.T) {
+		t.Setenv("AGENT_ID_FILE", filepath.Join(t.TempDir(), "agent-id"))
+
 		got := NewAgent()
 		require.NotNil(got)
 	})
@@ -30,12 +51,15 @@ func Test_agent_Poll(t *testing.T) {
 		PollInterval:   2 * time.Second,
 		ReportInterval: 10 * time.Second,
 		ServerAddress:  "",
+		ID:             "test-agent",
 	}
-	metrics := &metric.Metrics{}
+	metrics := metric.NewDefaultRegistry()
+	app, err := newAgent(cfg)
+	require.NoError(err)
 
 	type args struct {
 		ctx     context.Context
-		metrics *metric.Metrics
+		metrics *metric.Registry
 	}
 	tests := []struct {
 		name string
@@ -44,7 +68,7 @@ func Test_agent_Poll(t *testing.T) {
 	}{
 		{
 			name: "poll",
-			app:  &agent{Config: cfg},
+			app:  app,
 			args: args{
 				ctx:     context.Background(),
 				metrics: metrics,
@@ -59,8 +83,8 @@ func Test_agent_Poll(t *testing.T) {
 
 			tt.app.Poll(cancellingCtx, tt.args.metrics)
 
-			require.NotEmpty(tt.args.metrics)
-			require.NotEmpty(tt.args.metrics.PollCount)
+			require.NotEmpty(tt.args.metrics.Collect())
+			require.NotContains(tt.args.metrics.Collect(), metric.NamedMetric{Name: "PollCount", Value: metric.Counter(0)})
 		})
 	}
 }
@@ -82,23 +106,24 @@ func Test_agent_Report(t *testing.T) {
 		PollInterval:   2 * time.Second,
 		ReportInterval: 2 * time.Second,
 		ServerAddress:  strings.TrimPrefix(server.URL, "http://"),
+		ID:             "test-agent",
 	}
 
-	metrics := metric.Metrics{
-		PollCount: metric.Counter(12),
-	}
+	metrics := metric.NewDefaultRegistry()
+	app, err := newAgent(cfg)
+	require.NoError(t, err)
 
 	tests := []struct {
 		name    string
 		app     *agent
 		ctx     context.Context
-		metrics *metric.Metrics
+		metrics *metric.Registry
 	}{
 		{
 			name:    "report",
-			app:     &agent{Config: cfg},
+			app:     app,
 			ctx:     context.Background(),
-			metrics: &metrics,
+			metrics: metrics,
 		},
 	}
 
@@ -110,3 +135,382 @@ func Test_agent_Report(t *testing.T) {
 		})
 	}
 }
+
+func Test_agentLabels(t *testing.T) {
+	require := require.New(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(err)
+
+	labels, err := agentLabels(config.AgentConfig{ID: "agent-1"})
+	require.NoError(err)
+	require.Equal(metric.Labels{metric.InstanceLabel: "agent-1", metric.HostLabel: hostname}, labels)
+
+	cfg := config.AgentConfig{IDFile: filepath.Join(t.TempDir(), "agent-id")}
+	first, err := agentLabels(cfg)
+	require.NoError(err)
+	require.True(strings.HasPrefix(first[metric.InstanceLabel], hostname+"-"))
+
+	second, err := agentLabels(cfg)
+	require.NoError(err)
+	require.Equal(first, second)
+
+	metrics := withLabels([]metric.NamedMetric{
+		{Name: "Alloc", Value: metric.Gauge(1)},
+		{Name: "Requests", Value: metric.Counter(1), Labels: metric.Labels{metric.HostLabel: "web"}},
+	}, labels)
+	require.Equal(labels, metrics[0].Labels)
+	require.Equal(metric.Labels{metric.InstanceLabel: "agent-1", metric.HostLabel: "web"}, metrics[1].Labels)
+}
+
+func Test_newAgentTransport(t *testing.T) {
+	require := require.New(t)
+
+	cfg := config.AgentConfig{
+		PollInterval:   2 * time.Second,
+		ReportInterval: 10 * time.Second,
+		ServerAddress:  "localhost:3200",
+		Transport:      config.TransportGRPC,
+		ID:             "test-agent",
+	}
+
+	app, err := newAgent(cfg)
+	require.NoError(err)
+	require.NotNil(app.sender)
+
+	cfg.Transport = config.TransportWebSocket
+	app, err = newAgent(cfg)
+	require.NoError(err)
+	require.NotNil(app.sender)
+
+	cfg.Transport = "udp"
+	_, err = newAgent(cfg)
+	require.Error(err)
+
+	// шифрование поддерживает только транспорт HTTP.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(err)
+
+	cfg.CryptoKey = filepath.Join(t.TempDir(), "public.pem")
+	err = os.WriteFile(cfg.CryptoKey, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}), 0600)
+	require.NoError(err)
+
+	for _, transport := range []string{config.TransportGRPC, config.TransportWebSocket} {
+		cfg.Transport = transport
+		_, err = newAgent(cfg)
+		require.ErrorContains(err, "doesn't support encryption")
+	}
+
+	cfg.Transport = config.TransportHTTP
+	app, err = newAgent(cfg)
+	require.NoError(err)
+	require.NotNil(app.sender)
+}
+
+type slowSender struct {
+	inFlight    int32
+	maxInFlight int32
+	sent        int32
+}
+
+func (s *slowSender) SendMetrics(metrics []metric.NamedMetric) error {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, n) {
+			break
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	atomic.AddInt32(&s.sent, 1)
+
+	return nil
+}
+
+func (s *slowSender) SendMetricsBatch(metrics []metric.NamedMetric) error {
+	return s.SendMetrics(metrics)
+}
+
+func Test_agent_ReportRateLimit(t *testing.T) {
+	require := require.New(t)
+
+	ms := &slowSender{}
+	app := &agent{
+		Config: config.AgentConfig{
+			ReportInterval: 5 * time.Millisecond,
+			RateLimit:      3,
+		},
+		sender:   ms,
+		counters: newCounterDeltas(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	app.Report(ctx, metric.NewRegistry(metric.NewPollCountCollector()))
+
+	require.Equal(int32(3), atomic.LoadInt32(&ms.maxInFlight))
+	require.Greater(atomic.LoadInt32(&ms.sent), int32(3))
+	require.Zero(atomic.LoadInt32(&ms.inFlight))
+}
+
+type flakySender struct {
+	mu       sync.Mutex
+	failures int
+	sent     [][]metric.NamedMetric
+}
+
+func (s *flakySender) SendMetrics(metrics []metric.NamedMetric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return retry.Retriable(errors.New("server unavailable"))
+	}
+
+	s.sent = append(s.sent, metrics)
+
+	return nil
+}
+
+func (s *flakySender) SendMetricsBatch(metrics []metric.NamedMetric) error {
+	return s.SendMetrics(metrics)
+}
+
+func Test_agent_ReportOutbox(t *testing.T) {
+	require := require.New(t)
+
+	ob, err := outbox.New(t.TempDir(), 100, 0)
+	require.NoError(err)
+
+	ms := &flakySender{failures: 3}
+	app := &agent{
+		Config:   config.AgentConfig{ReportInterval: 10 * time.Millisecond},
+		sender:   ms,
+		outbox:   ob,
+		counters: newCounterDeltas(),
+	}
+
+	registry := metric.NewRegistry(metric.NewPollCountCollector())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	go func() {
+		for ctx.Err() == nil {
+			registry.Poll()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	app.Report(ctx, registry)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	// приросты счётчика, не отправленные при недоступности сервера, отправлены позже.
+	require.Greater(len(ms.sent), 3)
+
+	total := metric.Counter(0)
+	for _, metrics := range ms.sent {
+		total += metrics[0].Value.(metric.Counter)
+	}
+
+	require.NotZero(total)
+	require.Equal(app.counters.acked["PollCount"], total)
+}
+
+func Test_agentOutboxDropsRejectedBatch(t *testing.T) {
+	require := require.New(t)
+
+	var rejected int32
+	ms := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	router := handler.NewRouter(ms, zap.NewNop(), "", nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// первый снимок отклоняется, повторная отправка его не доставит.
+		if atomic.CompareAndSwapInt32(&rejected, 0, 1) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	app, err := newAgent(config.AgentConfig{
+		PollInterval:     time.Second,
+		ReportInterval:   10 * time.Millisecond,
+		ServerAddress:    strings.TrimPrefix(server.URL, "http://"),
+		Batch:            true,
+		ID:               "agent-1",
+		OutboxDir:        t.TempDir(),
+		OutboxMaxBatches: 10,
+	})
+	require.NoError(err)
+
+	registry := metric.NewRegistry(metric.NewPollCountCollector())
+	report := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		app.Report(ctx, registry)
+	}
+
+	registry.Poll()
+	report()
+	require.Equal(int32(1), atomic.LoadInt32(&rejected))
+
+	// отклонённый снимок удалён из очереди и не задерживает следующие:
+	// доставлен только прирост счётчика после него.
+	registry.Poll()
+	registry.Poll()
+	report()
+
+	value, err := ms.Get("PollCount", "counter")
+	require.NoError(err)
+	require.Equal("2", value)
+
+	n, err := app.outbox.Len()
+	require.NoError(err)
+	require.Zero(n)
+}
+
+func Test_agentCounterDeltasEndToEnd(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("batch %t", batch), func(t *testing.T) {
+			require := require.New(t)
+
+			var down int32
+			ms := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+			router := handler.NewRouter(ms, zap.NewNop(), "", nil)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&down) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				router.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			app, err := newAgent(config.AgentConfig{
+				PollInterval:   time.Second,
+				ReportInterval: 10 * time.Millisecond,
+				ServerAddress:  strings.TrimPrefix(server.URL, "http://"),
+				Batch:          batch,
+				ID:             "agent-1",
+			})
+			require.NoError(err)
+
+			registry := metric.NewRegistry(metric.NewPollCountCollector())
+			report := func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				app.Report(ctx, registry)
+			}
+			poll := func(n int) {
+				for i := 0; i < n; i++ {
+					registry.Poll()
+				}
+			}
+
+			poll(3)
+			report()
+
+			value, err := ms.Get("PollCount", "counter")
+			require.NoError(err)
+			require.Equal("3", value)
+
+			// сервер недоступен, прирост счётчика не теряется.
+			atomic.StoreInt32(&down, 1)
+			poll(2)
+			report()
+
+			atomic.StoreInt32(&down, 0)
+			poll(4)
+			report()
+
+			value, err = ms.Get("PollCount", "counter")
+			require.NoError(err)
+			require.Equal("9", value)
+
+			agents := ms.Agents()
+			require.Len(agents, 1)
+			require.Equal("agent-1", agents[0].ID)
+		})
+	}
+}
+
+func Test_agentValueEndToEnd(t *testing.T) {
+	require := require.New(t)
+
+	ms := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	server := httptest.NewServer(handler.NewRouter(ms, zap.NewNop(), "", nil))
+	defer server.Close()
+
+	report := func(id string) {
+		app, err := newAgent(config.AgentConfig{
+			PollInterval:   time.Second,
+			ReportInterval: 10 * time.Millisecond,
+			ServerAddress:  strings.TrimPrefix(server.URL, "http://"),
+			ID:             id,
+		})
+		require.NoError(err)
+
+		registry := metric.NewRegistry(metric.NewRuntimeCollector())
+		registry.Poll()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		app.Report(ctx, registry)
+	}
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(err)
+
+		return resp.StatusCode, string(body)
+	}
+
+	// метрика агента с метками instance и host доступна без указания меток.
+	report("agent-1")
+
+	code, body := get("/value/gauge/Alloc")
+	require.Equal(http.StatusOK, code)
+
+	value, err := strconv.ParseFloat(body, 64)
+	require.NoError(err)
+	require.Greater(value, 0.0)
+
+	// метрики двух агентов различаются только метками.
+	report("agent-2")
+
+	code, _ = get("/value/gauge/Alloc")
+	require.Equal(http.StatusConflict, code)
+
+	code, body = get("/value/gauge/Alloc?labels=" + url.QueryEscape(`{instance="agent-2"}`))
+	require.Equal(http.StatusNotFound, code, body)
+
+	hostname, err := os.Hostname()
+	require.NoError(err)
+
+	record, err := ms.GetRecord("Alloc", "gauge", metric.Labels{metric.InstanceLabel: "agent-2", metric.HostLabel: hostname})
+	require.NoError(err)
+
+	code, body = get("/value/gauge/Alloc?labels=" + url.QueryEscape(record.GetLabels().String()))
+	require.Equal(http.StatusOK, code)
+	require.Equal(record.GetValue().String(), body)
+}
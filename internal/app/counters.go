@@ -0,0 +1,76 @@
+package app
+
+import (
+	"sync"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+)
+
+type (
+	// counterDeltas - учёт отправленных на сервер значений счётчиков.
+	// Сервер суммирует полученные значения counter, поэтому агент отправляет
+	// только прирост счётчика с момента последней подтверждённой отправки.
+	counterDeltas struct {
+		sync.Mutex
+		// acked - значения счётчиков, доставленные на сервер, по идентификатору метрики.
+		acked map[string]metric.Counter
+		// pending - приросты счётчиков, переданные на отправку, но ещё не подтверждённые.
+		pending map[string]metric.Counter
+	}
+)
+
+func newCounterDeltas() *counterDeltas {
+	return &counterDeltas{
+		acked:   make(map[string]metric.Counter),
+		pending: make(map[string]metric.Counter),
+	}
+}
+
+// take - заменяет значения счётчиков снимка их приростом с момента последней
+// передачи на отправку и помечает приросты неподтверждёнными.
+func (cd *counterDeltas) take(metrics []metric.NamedMetric) []metric.NamedMetric {
+	cd.Lock()
+	defer cd.Unlock()
+
+	result := make([]metric.NamedMetric, 0, len(metrics))
+
+	for _, m := range metrics {
+		if v, ok := m.Value.(metric.Counter); ok {
+			key := metric.Key(m.Name, m.Labels)
+			delta := v - cd.acked[key] - cd.pending[key]
+			cd.pending[key] += delta
+			m.Value = delta
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
+// ack - подтверждает доставку приростов счётчиков.
+func (cd *counterDeltas) ack(metrics []metric.NamedMetric) {
+	cd.Lock()
+	defer cd.Unlock()
+
+	for _, m := range metrics {
+		if v, ok := m.Value.(metric.Counter); ok {
+			key := metric.Key(m.Name, m.Labels)
+			cd.acked[key] += v
+			cd.pending[key] -= v
+		}
+	}
+}
+
+// nack - возвращает недоставленные приросты счётчиков, чтобы они вошли
+// в прирост следующего снимка.
+func (cd *counterDeltas) nack(metrics []metric.NamedMetric) {
+	cd.Lock()
+	defer cd.Unlock()
+
+	for _, m := range metrics {
+		if v, ok := m.Value.(metric.Counter); ok {
+			cd.pending[metric.Key(m.Name, m.Labels)] -= v
+		}
+	}
+}
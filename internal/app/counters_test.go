@@ -0,0 +1,42 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+)
+
+func Test_counterDeltas(t *testing.T) {
+	require := require.New(t)
+
+	cd := newCounterDeltas()
+	snapshot := func(count metric.Counter) []metric.NamedMetric {
+		return []metric.NamedMetric{
+			{Name: "Alloc", Value: metric.Gauge(1.5)},
+			{Name: "PollCount", Value: count},
+		}
+	}
+
+	first := cd.take(snapshot(5))
+	require.Equal(snapshot(5), first)
+	cd.ack(first)
+
+	second := cd.take(snapshot(8))
+	require.Equal(snapshot(3), second)
+
+	// прирост, переданный на отправку, не входит в следующий снимок.
+	third := cd.take(snapshot(10))
+	require.Equal(snapshot(2), third)
+
+	// недоставленный прирост входит в следующий снимок.
+	cd.nack(second)
+	cd.ack(third)
+
+	fourth := cd.take(snapshot(10))
+	require.Equal(snapshot(3), fourth)
+	cd.ack(fourth)
+
+	require.Equal(snapshot(0), cd.take(snapshot(10)))
+}
@@ -2,14 +2,19 @@ package app
 
 import (
 	"context"
+	"crypto/rsa"
 	"errors"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"github.com/a-x-a/go-metric/internal/config"
+	"github.com/a-x-a/go-metric/internal/encryptor"
+	"github.com/a-x-a/go-metric/internal/grpcserver"
 	"github.com/a-x-a/go-metric/internal/handler"
 	"github.com/a-x-a/go-metric/internal/service/metricservice"
 	"github.com/a-x-a/go-metric/internal/storage"
@@ -17,9 +22,12 @@ import (
 
 type (
 	server struct {
-		Config     config.ServerConfig
-		Storage    storage.Storage
+		Config  config.ServerConfig
+		Storage storage.Storage
+		// metrics - хранилище метрик с историей значений, если она включена.
+		metrics    storage.Storage
 		httpServer *http.Server
+		grpcServer *grpc.Server
 		logger     *zap.Logger
 	}
 
@@ -27,6 +35,12 @@ type (
 		Save() error
 		Load() error
 	}
+
+	// withDBStorage - хранилище, которое закрывается при остановке сервера:
+	// подключение к базе данных или журнал файлового хранилища.
+	withDBStorage interface {
+		Close() error
+	}
 )
 
 var (
@@ -35,18 +49,50 @@ var (
 )
 
 func NewServer(cfg config.ServerConfig, logger *zap.Logger) *server {
-	ds := storage.NewDataStorage(cfg.FileStoregePath, cfg.StoreInterval, logger)
-	ms := metricservice.New(ds, logger)
-	rt := handler.NewRouter(ms, logger)
+	ds, err := storage.NewDataStorage(cfg.DatabaseDSN, cfg.FileStoregePath, cfg.StoreInterval, cfg.WALFsync, logger)
+	if err != nil {
+		logger.Panic("failed to create storage", zap.Error(err))
+	}
+
+	var privateKey *rsa.PrivateKey
+	if len(cfg.CryptoKey) > 0 {
+		privateKey, err = encryptor.LoadPrivateKey(cfg.CryptoKey)
+		if err != nil {
+			logger.Panic("failed to load private key", zap.Error(err))
+		}
+	}
+
+	ss := ds
+	if cfg.HistoryRetention > 0 && cfg.HistorySize > 0 {
+		ss = storage.NewWithHistoryStorage(ds, cfg.HistoryRetention, cfg.HistorySize)
+	}
+
+	ms := metricservice.New(ss, logger)
+	rt := handler.NewRouter(ms, logger, cfg.Key, privateKey, handler.WithWebSocketConfig(handler.WebSocketConfig{
+		MaxFrameSize: cfg.WSMaxFrameSize,
+		IdleTimeout:  cfg.WSIdleTimeout,
+	}))
+	// контекст запросов отменяется в начале остановки сервера,
+	// чтобы завершить открытые потоки событий /stream.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
 	srv := &http.Server{
-		Addr:    cfg.ListenAddress,
-		Handler: rt,
+		Addr:        cfg.ListenAddress,
+		Handler:     rt,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.RegisterOnShutdown(cancelRequests)
+
+	var grpcSrv *grpc.Server
+	if len(cfg.GRPCAddress) > 0 {
+		grpcSrv = grpcserver.New(ms, cfg.Key, logger)
 	}
 
 	return &server{
 		Config:     cfg,
 		Storage:    ds,
+		metrics:    ss,
 		httpServer: srv,
+		grpcServer: grpcSrv,
 		logger:     logger,
 	}
 }
@@ -68,6 +114,14 @@ func (s *server) Run(ctx context.Context) {
 		go s.saveStorage(ctx)
 	}
 
+	if s.Config.JanitorInterval > 0 {
+		go s.expireMetrics(ctx)
+	}
+
+	if s.grpcServer != nil {
+		go s.runGRPCServer()
+	}
+
 	s.logger.Info("start http server", zap.String("address", s.Config.ListenAddress))
 
 	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
@@ -82,15 +136,38 @@ func (s *server) Shutdown(ctx context.Context, signal os.Signal) {
 		s.logger.Warn("server shutdowning error", zap.Error(err))
 	}
 
+	if s.grpcServer != nil {
+		s.grpcServer.GracefulStop()
+	}
+
 	if ds, ok := s.Storage.(withFileStorage); ok {
 		if err := ds.Save(); err != nil {
 			s.logger.Warn("storage saving error", zap.Error(err))
 		}
 	}
 
+	if ds, ok := s.Storage.(withDBStorage); ok {
+		if err := ds.Close(); err != nil {
+			s.logger.Warn("storage closing error", zap.Error(err))
+		}
+	}
+
 	s.logger.Info("successfully server shutdowning")
 }
 
+func (s *server) runGRPCServer() {
+	lis, err := net.Listen("tcp", s.Config.GRPCAddress)
+	if err != nil {
+		s.logger.Panic("failed to listen grpc address", zap.Error(err))
+	}
+
+	s.logger.Info("start grpc server", zap.String("address", s.Config.GRPCAddress))
+
+	if err := s.grpcServer.Serve(lis); err != nil {
+		s.logger.Panic("failed to start grpc server", zap.Error(err))
+	}
+}
+
 func (s *server) saveStorage(ctx context.Context) {
 	if _, ok := s.Storage.(withFileStorage); !ok {
 		s.logger.Debug("storage doesn't support saving to file")
@@ -116,6 +193,35 @@ func (s *server) saveStorage(ctx context.Context) {
 	}
 }
 
+// expireMetrics - периодически удаляет метрики, не обновлявшиеся дольше срока хранения.
+func (s *server) expireMetrics(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.expire(time.Now())
+
+		case <-ctx.Done():
+			s.logger.Info("shutdown metrics expiration")
+			return
+		}
+	}
+}
+
+func (s *server) expire(now time.Time) {
+	deleted, err := s.metrics.Expire(now, s.Config.MetricTTL)
+	if err != nil {
+		s.logger.Error("metrics expiration error", zap.Error(err))
+		return
+	}
+
+	for _, record := range deleted {
+		s.logger.Info("metric expired", zap.String("key", record.Key()))
+	}
+}
+
 func (s *server) loadStorage() error {
 	ds, ok := s.Storage.(withFileStorage)
 	if !ok {
@@ -14,6 +14,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/config"
+	"github.com/a-x-a/go-metric/internal/models/metric"
 	"github.com/a-x-a/go-metric/internal/storage"
 )
 
@@ -40,6 +41,7 @@ func Test_serverRunWithMemStorage(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -86,6 +88,7 @@ func Test_serverRunWithFileStorage(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -132,6 +135,7 @@ func Test_serverErrorListenAndServe(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -182,6 +186,7 @@ func Test_server_saveWithMemStorage(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -213,6 +218,7 @@ func Test_server_saveAndLoadWithFileStorage(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -234,6 +240,7 @@ func Test_server_loadWitMemStorage(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -252,6 +259,7 @@ func Test_server_loadWithError(t *testing.T) {
 	srv := server{
 		Config:     cfg,
 		Storage:    stor,
+		metrics:    stor,
 		httpServer: &http.Server{Addr: cfg.ListenAddress},
 		logger:     zap.NewNop(),
 	}
@@ -259,3 +267,36 @@ func Test_server_loadWithError(t *testing.T) {
 	err = srv.loadStorage()
 	require.Error(err)
 }
+
+func Test_serverExpire(t *testing.T) {
+	require := require.New(t)
+
+	stor := storage.NewWithHistoryStorage(storage.NewMemStorage(), time.Minute, 10)
+	cfg := config.NewServerConfig()
+	cfg.MetricTTL = time.Minute
+	srv := server{
+		Config:  cfg,
+		Storage: stor,
+		metrics: stor,
+		logger:  zap.NewNop(),
+	}
+
+	short, _ := storage.NewRecord("Short")
+	short.SetValue(metric.Gauge(1))
+	require.NoError(short.SetTTL(time.Second))
+
+	long, _ := storage.NewRecord("Long")
+	long.SetValue(metric.Gauge(2))
+
+	_, err := stor.Update([]storage.Record{short, long})
+	require.NoError(err)
+
+	srv.expire(time.Now().Add(30 * time.Second))
+	require.Len(stor.GetAll(), 1)
+
+	_, ok := stor.History("Short", time.Time{}, time.Now())
+	require.False(ok)
+
+	srv.expire(time.Now().Add(2 * time.Minute))
+	require.Empty(stor.GetAll())
+}
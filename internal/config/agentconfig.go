@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/caarlos0/env"
+
+	"github.com/a-x-a/go-metric/internal/identity"
 )
 
 type (
@@ -16,13 +18,58 @@ type (
 		ReportInterval time.Duration `env:"REPORT_INTERVAL"`
 		// ServerAddress - адрес сервера сбора метрик
 		ServerAddress string `env:"ADDRESS"`
+		// Batch - отправлять все метрики одним запросом на /updates/, по умолчанию false
+		Batch bool `env:"BATCH"`
+		// Key - ключ подписи запросов HMAC-SHA256, по умолчанию пустой
+		Key string `env:"KEY"`
+		// CryptoKey - путь до файла с открытым ключом шифрования запросов, по умолчанию пустой
+		CryptoKey string `env:"CRYPTO_KEY"`
+		// Transport - протокол отправки метрик на сервер: http, grpc или ws, по умолчанию http.
+		// При отправке по gRPC ServerAddress - адрес gRPC сервера, при отправке по ws
+		// метрики передаются через постоянное соединение WebSocket без шифрования.
+		Transport string `env:"TRANSPORT"`
+		// RateLimit - максимальное количество одновременно отправляемых на сервер запросов, по умолчанию 1
+		RateLimit int `env:"RATE_LIMIT"`
+		// RetryDelays - задержки перед повторными попытками отправки через запятую,
+		// по умолчанию "1s,3s,5s", пустое значение отключает повторные попытки
+		RetryDelays string `env:"RETRY_DELAYS"`
+		// OutboxDir - каталог очереди неотправленных метрик, по умолчанию пустой,
+		// пустое значение отключает очередь и неотправленные метрики теряются
+		OutboxDir string `env:"OUTBOX_DIR"`
+		// OutboxMaxBatches - максимальное количество снимков метрик в очереди, по умолчанию 100
+		OutboxMaxBatches int `env:"OUTBOX_MAX_BATCHES"`
+		// OutboxMaxAge - возраст снимка в очереди, после которого значения gauge
+		// отбрасываются, по умолчанию 3600 сек, значение `0` отключает ограничение
+		OutboxMaxAge time.Duration `env:"OUTBOX_MAX_AGE"`
+		// ID - идентификатор агента, добавляемый к метрикам меткой instance,
+		// по умолчанию пустой: идентификатор составляется из имени хоста и UUID агента
+		ID string `env:"AGENT_ID"`
+		// IDFile - файл, в котором сохраняется UUID агента между перезапусками,
+		// по умолчанию go-metric/agent-id в каталоге пользовательских настроек
+		IDFile string `env:"AGENT_ID_FILE"`
 	}
 )
 
+const (
+	// протоколы отправки метрик на сервер.
+	TransportHTTP, TransportGRPC, TransportWebSocket = "http", "grpc", "ws"
+)
+
 func NewAgentConfig() AgentConfig {
 	pollInterval := 2
 	reportInterval := 10
 	serverAddress := "localhost:8080"
+	batch := false
+	key := ""
+	cryptoKey := ""
+	transport := TransportHTTP
+	rateLimit := 1
+	retryDelays := "1s,3s,5s"
+	outboxDir := ""
+	outboxMaxBatches := 100
+	outboxMaxAge := 3600
+	id := ""
+	idFile := identity.DefaultPath()
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Использование:\n")
@@ -38,13 +85,57 @@ func NewAgentConfig() AgentConfig {
 	if flag.Lookup("r") == nil {
 		flag.IntVar(&reportInterval, "r", reportInterval, "частота отправки метрик на сервер")
 	}
+	if flag.Lookup("b") == nil {
+		flag.BoolVar(&batch, "b", batch, "отправлять все метрики одним запросом")
+	}
+	if flag.Lookup("k") == nil {
+		flag.StringVar(&key, "k", key, "ключ подписи запросов")
+	}
+	if flag.Lookup("crypto-key") == nil {
+		flag.StringVar(&cryptoKey, "crypto-key", cryptoKey, "путь до файла с открытым ключом шифрования")
+	}
+	if flag.Lookup("l") == nil {
+		flag.IntVar(&rateLimit, "l", rateLimit, "максимальное количество одновременно отправляемых запросов")
+	}
+	if flag.Lookup("retry-delays") == nil {
+		flag.StringVar(&retryDelays, "retry-delays", retryDelays, "задержки перед повторными попытками отправки через запятую")
+	}
+	if flag.Lookup("outbox-dir") == nil {
+		flag.StringVar(&outboxDir, "outbox-dir", outboxDir, "каталог очереди неотправленных метрик")
+	}
+	if flag.Lookup("outbox-max-batches") == nil {
+		flag.IntVar(&outboxMaxBatches, "outbox-max-batches", outboxMaxBatches, "максимальное количество снимков метрик в очереди")
+	}
+	if flag.Lookup("outbox-max-age") == nil {
+		flag.IntVar(&outboxMaxAge, "outbox-max-age", outboxMaxAge, "возраст снимка в очереди в секундах, после которого значения gauge отбрасываются")
+	}
+	if flag.Lookup("id") == nil {
+		flag.StringVar(&id, "id", id, "идентификатор агента")
+	}
+	if flag.Lookup("id-file") == nil {
+		flag.StringVar(&idFile, "id-file", idFile, "файл, в котором сохраняется UUID агента")
+	}
+	if flag.Lookup("transport") == nil {
+		flag.StringVar(&transport, "transport", transport, "протокол отправки метрик на сервер: http, grpc или ws")
+	}
 
 	flag.Parse()
 
 	cfg := AgentConfig{
-		PollInterval:   time.Duration(pollInterval) * time.Second,
-		ReportInterval: time.Duration(reportInterval) * time.Second,
-		ServerAddress:  serverAddress,
+		PollInterval:     time.Duration(pollInterval) * time.Second,
+		ReportInterval:   time.Duration(reportInterval) * time.Second,
+		ServerAddress:    serverAddress,
+		Batch:            batch,
+		Key:              key,
+		CryptoKey:        cryptoKey,
+		Transport:        transport,
+		RateLimit:        rateLimit,
+		RetryDelays:      retryDelays,
+		OutboxDir:        outboxDir,
+		OutboxMaxBatches: outboxMaxBatches,
+		OutboxMaxAge:     time.Duration(outboxMaxAge) * time.Second,
+		ID:               id,
+		IDFile:           idFile,
 	}
 
 	_ = env.Parse(&cfg)
@@ -12,6 +12,9 @@ type (
 	ServerConfig struct {
 		// ListenAddress - адрес сервера сбора метрик
 		ListenAddress string `env:"ADDRESS"`
+		// GRPCAddress - адрес gRPC сервера сбора метрик
+		// (по умолчанию пустой, gRPC сервер не запускается).
+		GRPCAddress string `env:"GRPC_ADDRESS"`
 		// StoreInterval - интервал времени в секундах, по истечении которого
 		// текущие показания сервера сохраняются на диск
 		// (по умолчанию 300 секунд, значение `0` делает запись синхронной).
@@ -23,15 +26,51 @@ type (
 		// определяющее, загружать или нет ранее сохранённые значения
 		// из указанного файла при старте сервера (по умолчанию `true`).
 		Restore bool `env:"RESTORE"`
+		// WALFsync - политика сброса журнала изменений на диск при синхронной записи:
+		// `always` - после каждой записи (по умолчанию), `interval` - не чаще раза в секунду,
+		// `never` - полагаться на операционную систему.
+		WALFsync string `env:"WAL_FSYNC"`
+		// DatabaseDSN - строка подключения к базе данных PostgreSQL,
+		// если указана, то метрики хранятся в базе данных (по умолчанию пустая).
+		DatabaseDSN string `env:"DATABASE_DSN"`
+		// Key - ключ подписи запросов и ответов HMAC-SHA256
+		// (по умолчанию пустой, подпись не используется).
+		Key string `env:"KEY"`
+		// CryptoKey - путь до файла с закрытым ключом расшифровки запросов
+		// (по умолчанию пустой, запросы не расшифровываются).
+		CryptoKey string `env:"CRYPTO_KEY"`
+		// HistoryRetention - срок хранения истории значений метрик
+		// (по умолчанию 0 секунд, значение `0` отключает хранение истории).
+		HistoryRetention time.Duration `env:"HISTORY_RETENTION"`
+		// HistorySize - максимальное количество хранимых значений каждой метрики (по умолчанию 1000).
+		HistorySize int `env:"HISTORY_SIZE"`
+		// MetricTTL - срок хранения метрик без обновлений, для которых не задан собственный срок
+		// (по умолчанию 0 секунд, значение `0` не ограничивает срок хранения).
+		MetricTTL time.Duration `env:"METRIC_TTL"`
+		// JanitorInterval - интервал удаления метрик, не обновлявшихся дольше срока хранения
+		// (по умолчанию 60 секунд, значение `0` отключает удаление).
+		JanitorInterval time.Duration `env:"JANITOR_INTERVAL"`
+		// WSMaxFrameSize - максимальный размер сообщения WebSocket с метриками в байтах (по умолчанию 1 МиБ).
+		WSMaxFrameSize int64 `env:"WS_MAX_FRAME_SIZE"`
+		// WSIdleTimeout - время ожидания сообщения от агента, после которого
+		// соединение WebSocket закрывается (по умолчанию 60 секунд).
+		WSIdleTimeout time.Duration `env:"WS_IDLE_TIMEOUT"`
 	}
 )
 
 func NewServerConfig() ServerConfig {
 	storeInterval := 300
+	historyRetention := 0
+	metricTTL := 0
+	janitorInterval := 60
+	wsIdleTimeout := 60
 	cfg := ServerConfig{
 		ListenAddress:   "localhost:8080",
 		FileStoregePath: "/tmp/metrics-db.json",
 		Restore:         true,
+		WALFsync:        "always",
+		HistorySize:     1000,
+		WSMaxFrameSize:  1 << 20,
 	}
 
 	flag.Usage = func() {
@@ -43,6 +82,10 @@ func NewServerConfig() ServerConfig {
 		flag.StringVar(&cfg.ListenAddress, "a", cfg.ListenAddress, "адрес и порт сервера сбора метрик")
 	}
 
+	if flag.Lookup("grpc-address") == nil {
+		flag.StringVar(&cfg.GRPCAddress, "grpc-address", cfg.GRPCAddress, "адрес и порт gRPC сервера сбора метрик")
+	}
+
 	if flag.Lookup("i") == nil {
 		flag.IntVar(&storeInterval, "i", storeInterval, "интервал сохранения текущих показаний сервера на диск")
 	}
@@ -55,9 +98,53 @@ func NewServerConfig() ServerConfig {
 		flag.BoolVar(&cfg.Restore, "r", cfg.Restore, "загружать или нет ранее сохранённые значения из файла при старте")
 	}
 
+	if flag.Lookup("wal-fsync") == nil {
+		flag.StringVar(&cfg.WALFsync, "wal-fsync", cfg.WALFsync, "политика сброса журнала изменений на диск: always, interval или never")
+	}
+
+	if flag.Lookup("d") == nil {
+		flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "строка подключения к базе данных")
+	}
+
+	if flag.Lookup("k") == nil {
+		flag.StringVar(&cfg.Key, "k", cfg.Key, "ключ подписи запросов и ответов")
+	}
+
+	if flag.Lookup("crypto-key") == nil {
+		flag.StringVar(&cfg.CryptoKey, "crypto-key", cfg.CryptoKey, "путь до файла с закрытым ключом шифрования")
+	}
+
+	if flag.Lookup("history-retention") == nil {
+		flag.IntVar(&historyRetention, "history-retention", historyRetention, "срок хранения истории значений метрик в секундах")
+	}
+
+	if flag.Lookup("history-size") == nil {
+		flag.IntVar(&cfg.HistorySize, "history-size", cfg.HistorySize, "максимальное количество хранимых значений каждой метрики")
+	}
+
+	if flag.Lookup("metric-ttl") == nil {
+		flag.IntVar(&metricTTL, "metric-ttl", metricTTL, "срок хранения метрик без обновлений в секундах")
+	}
+
+	if flag.Lookup("janitor-interval") == nil {
+		flag.IntVar(&janitorInterval, "janitor-interval", janitorInterval, "интервал удаления устаревших метрик в секундах")
+	}
+
+	if flag.Lookup("ws-max-frame-size") == nil {
+		flag.Int64Var(&cfg.WSMaxFrameSize, "ws-max-frame-size", cfg.WSMaxFrameSize, "максимальный размер сообщения WebSocket в байтах")
+	}
+
+	if flag.Lookup("ws-idle-timeout") == nil {
+		flag.IntVar(&wsIdleTimeout, "ws-idle-timeout", wsIdleTimeout, "время ожидания сообщения WebSocket от агента в секундах")
+	}
+
 	flag.Parse()
 
 	cfg.StoreInterval = time.Duration(storeInterval) * time.Second
+	cfg.HistoryRetention = time.Duration(historyRetention) * time.Second
+	cfg.MetricTTL = time.Duration(metricTTL) * time.Second
+	cfg.JanitorInterval = time.Duration(janitorInterval) * time.Second
+	cfg.WSIdleTimeout = time.Duration(wsIdleTimeout) * time.Second
 
 	_ = env.Parse(&cfg)
 
@@ -0,0 +1,172 @@
+package encryptor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"os"
+)
+
+const (
+	// HeaderEncryption - заголовок, которым помечаются зашифрованные запросы.
+	HeaderEncryption = "X-Encryption"
+	// EncryptionHybrid - гибридное шифрование: ключ AES-256 шифруется RSA-OAEP (SHA-256),
+	// тело шифруется AES-256-GCM.
+	EncryptionHybrid = "rsa-oaep-aes256-gcm"
+
+	// sessionKeySize - размер сессионного ключа AES-256.
+	sessionKeySize = 32
+	// keyLenSize - размер поля с длиной зашифрованного сессионного ключа.
+	keyLenSize = 2
+)
+
+var (
+	// ErrInvalidKey - файл не содержит ключ RSA в формате PEM.
+	ErrInvalidKey = errors.New("encryptor: не корректный ключ RSA")
+	// ErrInvalidMessage - сообщение повреждено или зашифровано другим ключом.
+	ErrInvalidMessage = errors.New("encryptor: не корректное зашифрованное сообщение")
+)
+
+// Encrypt - шифрует данные гибридным способом открытым ключом key.
+// Формат сообщения: длина зашифрованного сессионного ключа (2 байта, big endian),
+// зашифрованный сессионный ключ, nonce AES-GCM, шифротекст.
+func Encrypt(data []byte, key *rsa.PublicKey) ([]byte, error) {
+	sessionKey := make([]byte, sessionKeySize)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, sessionKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	size := keyLenSize + len(encryptedKey) + gcm.NonceSize()
+	msg := make([]byte, size, size+len(data)+gcm.Overhead())
+
+	binary.BigEndian.PutUint16(msg, uint16(len(encryptedKey)))
+	copy(msg[keyLenSize:], encryptedKey)
+
+	nonce := msg[keyLenSize+len(encryptedKey):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(msg, nonce, data, nil), nil
+}
+
+// Decrypt - расшифровывает сообщение, зашифрованное функцией Encrypt, закрытым ключом key.
+func Decrypt(msg []byte, key *rsa.PrivateKey) ([]byte, error) {
+	if len(msg) < keyLenSize {
+		return nil, ErrInvalidMessage
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(msg))
+	msg = msg[keyLenSize:]
+
+	if len(msg) < keyLen {
+		return nil, ErrInvalidMessage
+	}
+
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, msg[:keyLen], nil)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+
+	gcm, err := newGCM(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	msg = msg[keyLen:]
+	if len(msg) < gcm.NonceSize() {
+		return nil, ErrInvalidMessage
+	}
+
+	data, err := gcm.Open(nil, msg[:gcm.NonceSize()], msg[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidMessage
+	}
+
+	return data, nil
+}
+
+// LoadPublicKey - загружает открытый ключ RSA из файла в формате PEM (PKIX или PKCS #1).
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if k, ok := key.(*rsa.PublicKey); ok {
+		return k, nil
+	}
+
+	return nil, ErrInvalidKey
+}
+
+// LoadPrivateKey - загружает закрытый ключ RSA из файла в формате PEM (PKCS #1 или PKCS #8).
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if k, ok := key.(*rsa.PrivateKey); ok {
+		return k, nil
+	}
+
+	return nil, ErrInvalidKey
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	return block, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
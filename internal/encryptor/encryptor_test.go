@@ -0,0 +1,111 @@
+package encryptor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return key
+}
+
+func writePEM(t *testing.T, blockType string, data []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600)
+	require.NoError(t, err)
+
+	return path
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := generateKey(t)
+	another := generateKey(t)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "small", data: []byte(`{"id":"Alloc","type":"gauge","value":1.5}`)},
+		{name: "large batch", data: bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1.5},`), 10000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			msg, err := Encrypt(tt.data, &key.PublicKey)
+			require.NoError(err)
+
+			got, err := Decrypt(msg, key)
+			require.NoError(err)
+			require.Equal(string(tt.data), string(got))
+
+			_, err = Decrypt(msg, another)
+			require.ErrorIs(err, ErrInvalidMessage)
+
+			msg[len(msg)-1] ^= 0xff
+			_, err = Decrypt(msg, key)
+			require.ErrorIs(err, ErrInvalidMessage)
+		})
+	}
+
+	t.Run("truncated message", func(t *testing.T) {
+		_, err := Decrypt([]byte{0x01}, key)
+		require.ErrorIs(t, err, ErrInvalidMessage)
+
+		_, err = Decrypt([]byte{0x01, 0x00, 0x01}, key)
+		require.ErrorIs(t, err, ErrInvalidMessage)
+	})
+}
+
+func TestLoadKeys(t *testing.T) {
+	require := require.New(t)
+	key := generateKey(t)
+
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(err)
+
+	publicKeys := []string{
+		writePEM(t, "PUBLIC KEY", pkix),
+		writePEM(t, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&key.PublicKey)),
+	}
+	for _, path := range publicKeys {
+		got, err := LoadPublicKey(path)
+		require.NoError(err)
+		require.True(key.PublicKey.Equal(got))
+	}
+
+	privateKeys := []string{
+		writePEM(t, "PRIVATE KEY", pkcs8),
+		writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+	}
+	for _, path := range privateKeys {
+		got, err := LoadPrivateKey(path)
+		require.NoError(err)
+		require.True(key.Equal(got))
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(os.WriteFile(invalid, []byte("invalid"), 0600))
+
+	_, err = LoadPublicKey(invalid)
+	require.ErrorIs(err, ErrInvalidKey)
+	_, err = LoadPrivateKey(invalid)
+	require.ErrorIs(err, ErrInvalidKey)
+	_, err = LoadPublicKey(filepath.Join(t.TempDir(), "unknown.pem"))
+	require.Error(err)
+}
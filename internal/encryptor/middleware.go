@@ -0,0 +1,56 @@
+package encryptor
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// DecryptMiddleware - расшифровывает тело запросов, помеченных заголовком HeaderEncryption.
+// Если закрытый ключ не задан, то запросы передаются дальше без изменений.
+func DecryptMiddleware(key *rsa.PrivateKey, logger *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encryption := r.Header.Get(HeaderEncryption)
+			if len(encryption) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if encryption != EncryptionHybrid {
+				logger.Info("encryption method not supported", zap.String("method", encryption))
+				http.Error(w, "encryption method not supported", http.StatusBadRequest)
+				return
+			}
+
+			msg, err := io.ReadAll(r.Body)
+			if err != nil {
+				logger.Error("read request body", zap.Error(err))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			r.Body.Close()
+
+			data, err := Decrypt(msg, key)
+			if err != nil {
+				logger.Info("decrypt request body", zap.Error(err))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			r.Header.Del(HeaderEncryption)
+			r.ContentLength = int64(len(data))
+			r.Body = io.NopCloser(bytes.NewReader(data))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package encryptor
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDecryptMiddleware(t *testing.T) {
+	key := generateKey(t)
+	data := []byte(`[{"id":"Alloc","type":"gauge","value":1.5}]`)
+	msg, err := Encrypt(data, &key.PublicKey)
+	require.NoError(t, err)
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+
+	tests := []struct {
+		name       string
+		body       []byte
+		encryption string
+		code       int
+		want       []byte
+	}{
+		{
+			name:       "encrypted request",
+			body:       msg,
+			encryption: EncryptionHybrid,
+			code:       http.StatusOK,
+			want:       data,
+		},
+		{
+			name: "plain request",
+			body: data,
+			code: http.StatusOK,
+			want: data,
+		},
+		{
+			name:       "unknown encryption",
+			body:       msg,
+			encryption: "rot13",
+			code:       http.StatusBadRequest,
+		},
+		{
+			name:       "corrupted request",
+			body:       data,
+			encryption: EncryptionHybrid,
+			code:       http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if len(tt.encryption) > 0 {
+				req.Header.Set(HeaderEncryption, tt.encryption)
+			}
+
+			w := httptest.NewRecorder()
+			DecryptMiddleware(key, zap.NewNop())(echo).ServeHTTP(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+			if tt.code == http.StatusOK {
+				assert.Equal(t, string(tt.want), string(body))
+			}
+		})
+	}
+}
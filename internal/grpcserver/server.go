@@ -0,0 +1,253 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	pb "github.com/a-x-a/go-metric/internal/proto"
+	"github.com/a-x-a/go-metric/internal/signer"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+type (
+	metricService interface {
+		PushBatch(records []storage.Record) ([]storage.Record, error)
+		GetLabeled(name, kind string, labels metric.Labels) (string, error)
+		GetAll() []storage.Record
+	}
+
+	// MetricsServer - реализация gRPC сервиса сбора метрик.
+	MetricsServer struct {
+		pb.UnimplementedMetricsServer
+		service metricService
+		logger  *zap.Logger
+	}
+)
+
+// New - создаёт gRPC сервер с зарегистрированным сервисом сбора метрик.
+// Если задан ключ key, то запросы без подписи в метаданных или с неверной
+// подписью отклоняются с кодом Unauthenticated.
+func New(s metricService, key string, logger *zap.Logger) *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(loggerInterceptor(logger), signInterceptor(key, logger)))
+	pb.RegisterMetricsServer(srv, NewMetricsServer(s, logger))
+
+	return srv
+}
+
+func NewMetricsServer(s metricService, logger *zap.Logger) *MetricsServer {
+	return &MetricsServer{
+		service: s,
+		logger:  logger,
+	}
+}
+
+func (s *MetricsServer) Update(ctx context.Context, in *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	record, err := metricToRecord(in.GetMetric())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	records, err := s.service.PushBatch([]storage.Record{record})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.UpdateResponse{Metric: recordToMetric(records[0])}, nil
+}
+
+func (s *MetricsServer) BatchUpdate(ctx context.Context, in *pb.BatchUpdateRequest) (*pb.BatchUpdateResponse, error) {
+	if len(in.GetMetrics()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty batch")
+	}
+
+	records := make([]storage.Record, 0, len(in.GetMetrics()))
+	for _, m := range in.GetMetrics() {
+		record, err := metricToRecord(m)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "metric %q: %s", m.GetId(), err)
+		}
+
+		records = append(records, record)
+	}
+
+	result, err := s.service.PushBatch(records)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.BatchUpdateResponse{Metrics: make([]*pb.Metric, 0, len(result))}
+	for _, record := range result {
+		resp.Metrics = append(resp.Metrics, recordToMetric(record))
+	}
+
+	return resp, nil
+}
+
+func (s *MetricsServer) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
+	m := &pb.Metric{Id: in.GetId(), Type: in.GetType(), Labels: in.GetLabels()}
+
+	labels := metric.Labels(in.GetLabels())
+	if err := labels.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	switch in.GetType() {
+	case pb.MetricType_COUNTER:
+		value, err := s.service.GetLabeled(in.GetId(), string(metric.KindCounter), labels)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		val, err := metric.ToCounter(value)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		m.Delta = int64(val)
+
+	case pb.MetricType_GAUGE:
+		value, err := s.service.GetLabeled(in.GetId(), string(metric.KindGauge), labels)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		val, err := metric.ToGauge(value)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		m.Value = float64(val)
+
+	default:
+		return nil, status.Error(codes.InvalidArgument, metric.ErrorInvalidMetricKind.Error())
+	}
+
+	return &pb.GetResponse{Metric: m}, nil
+}
+
+func (s *MetricsServer) List(ctx context.Context, in *pb.ListRequest) (*pb.ListResponse, error) {
+	records := s.service.GetAll()
+
+	resp := &pb.ListResponse{Metrics: make([]*pb.Metric, 0, len(records))}
+	for _, record := range records {
+		// gRPC сервис передаёт только метрики counter и gauge.
+		if value := record.GetValue(); value == nil || !value.IsCounter() && !value.IsGauge() {
+			continue
+		}
+
+		resp.Metrics = append(resp.Metrics, recordToMetric(record))
+	}
+
+	return resp, nil
+}
+
+// loggerInterceptor - логирует каждый вызов gRPC сервиса.
+func loggerInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		logger.Info("",
+			zap.String("method", info.FullMethod),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("code", status.Code(err).String()),
+		)
+
+		return resp, err
+	}
+}
+
+// signInterceptor - проверяет подпись сообщения запроса signer.HashMessage,
+// переданную в метаданных signer.MetadataHash. Если ключ не задан,
+// то запросы передаются дальше без проверки.
+func signInterceptor(key string, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if len(key) == 0 {
+			return handler(ctx, req)
+		}
+
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Error(codes.Internal, "request is not a protobuf message")
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		hashes := md.Get(signer.MetadataHash)
+		if len(hashes) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing request signature")
+		}
+
+		if err := signer.VerifyMessage(msg, key, hashes[0]); err != nil {
+			logger.Info("request signature mismatch", zap.String("method", info.FullMethod))
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// toStatus - преобразует ошибку сервиса в статус gRPC.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, metric.ErrorMetricNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, metric.ErrorAmbiguousMetric):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, metric.ErrorInvalidMetricKind), errors.Is(err, storage.ErrInvalidName),
+		errors.Is(err, metric.ErrorInvalidLabel):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+func metricToRecord(m *pb.Metric) (storage.Record, error) {
+	record, err := storage.NewRecord(m.GetId())
+	if err != nil {
+		return storage.Record{}, err
+	}
+
+	labels := metric.Labels(m.GetLabels())
+	if err := labels.Validate(); err != nil {
+		return storage.Record{}, err
+	}
+
+	record.SetLabels(labels)
+
+	switch m.GetType() {
+	case pb.MetricType_COUNTER:
+		record.SetValue(metric.Counter(m.GetDelta()))
+	case pb.MetricType_GAUGE:
+		record.SetValue(metric.Gauge(m.GetValue()))
+	default:
+		return storage.Record{}, metric.ErrorInvalidMetricKind
+	}
+
+	return record, nil
+}
+
+func recordToMetric(record storage.Record) *pb.Metric {
+	m := &pb.Metric{Id: record.GetName(), Labels: record.GetLabels()}
+
+	switch v := record.GetValue().(type) {
+	case metric.Counter:
+		m.Type = pb.MetricType_COUNTER
+		m.Delta = int64(v)
+	case metric.Gauge:
+		m.Type = pb.MetricType_GAUGE
+		m.Value = float64(v)
+	}
+
+	return m
+}
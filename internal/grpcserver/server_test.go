@@ -0,0 +1,180 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	pb "github.com/a-x-a/go-metric/internal/proto"
+	"github.com/a-x-a/go-metric/internal/retry"
+	"github.com/a-x-a/go-metric/internal/sender"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/signer"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+func newTestClient(t *testing.T) pb.MetricsClient {
+	lis := bufconn.Listen(1024 * 1024)
+
+	srv := New(metricservice.New(storage.NewMemStorage(), zap.NewNop()), "", zap.NewNop())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewMetricsClient(conn)
+}
+
+func TestMetricsServer(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	resp, err := client.Update(ctx, &pb.UpdateRequest{
+		Metric: &pb.Metric{Id: "PollCount", Type: pb.MetricType_COUNTER, Delta: 5},
+	})
+	require.NoError(err)
+	require.Equal(int64(5), resp.GetMetric().GetDelta())
+
+	batch, err := client.BatchUpdate(ctx, &pb.BatchUpdateRequest{
+		Metrics: []*pb.Metric{
+			{Id: "PollCount", Type: pb.MetricType_COUNTER, Delta: 3},
+			{Id: "Alloc", Type: pb.MetricType_GAUGE, Value: 12.345},
+		},
+	})
+	require.NoError(err)
+	require.Len(batch.GetMetrics(), 2)
+	require.Equal(int64(8), batch.GetMetrics()[0].GetDelta())
+	require.Equal(12.345, batch.GetMetrics()[1].GetValue())
+
+	got, err := client.Get(ctx, &pb.GetRequest{Id: "Alloc", Type: pb.MetricType_GAUGE})
+	require.NoError(err)
+	require.Equal(12.345, got.GetMetric().GetValue())
+
+	got, err = client.Get(ctx, &pb.GetRequest{Id: "PollCount", Type: pb.MetricType_COUNTER})
+	require.NoError(err)
+	require.Equal(int64(8), got.GetMetric().GetDelta())
+
+	list, err := client.List(ctx, &pb.ListRequest{})
+	require.NoError(err)
+	require.Len(list.GetMetrics(), 2)
+
+	_, err = client.Get(ctx, &pb.GetRequest{Id: "Unknown", Type: pb.MetricType_GAUGE})
+	require.Equal(codes.NotFound, status.Code(err))
+
+	_, err = client.Get(ctx, &pb.GetRequest{Id: "Alloc"})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Update(ctx, &pb.UpdateRequest{
+		Metric: &pb.Metric{Type: pb.MetricType_GAUGE, Value: 1},
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = client.BatchUpdate(ctx, &pb.BatchUpdateRequest{})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestMetricsServerLabels(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	_, err := client.BatchUpdate(ctx, &pb.BatchUpdateRequest{
+		Metrics: []*pb.Metric{
+			{Id: "Requests", Type: pb.MetricType_COUNTER, Delta: 3, Labels: map[string]string{"host": "a"}},
+			{Id: "Requests", Type: pb.MetricType_COUNTER, Delta: 5, Labels: map[string]string{"host": "b"}},
+		},
+	})
+	require.NoError(err)
+
+	got, err := client.Get(ctx, &pb.GetRequest{
+		Id: "Requests", Type: pb.MetricType_COUNTER, Labels: map[string]string{"host": "b"},
+	})
+	require.NoError(err)
+	require.Equal(int64(5), got.GetMetric().GetDelta())
+	require.Equal(map[string]string{"host": "b"}, got.GetMetric().GetLabels())
+
+	_, err = client.Get(ctx, &pb.GetRequest{Id: "Requests", Type: pb.MetricType_COUNTER})
+	require.Equal(codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.Update(ctx, &pb.UpdateRequest{
+		Metric: &pb.Metric{Id: "Alloc", Type: pb.MetricType_GAUGE, Value: 1, Labels: map[string]string{"__name__": "x"}},
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestMetricsServerSign(t *testing.T) {
+	const key = "secret"
+
+	require := require.New(t)
+
+	ms := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	srv := New(ms, key, zap.NewNop())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(err)
+	defer conn.Close()
+
+	client := pb.NewMetricsClient(conn)
+	req := &pb.UpdateRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.MetricType_COUNTER, Delta: 1}}
+
+	_, err = client.Update(context.Background(), req)
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	hash, err := signer.HashMessage(req, "another")
+	require.NoError(err)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), signer.MetadataHash, hash)
+	_, err = client.Update(ctx, req)
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = ms.Get("PollCount", "counter")
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+
+	// агент подписывает запросы тем же ключом.
+	gs, err := sender.NewGRPCSender(lis.Addr().String(), time.Second, key, retry.New())
+	require.NoError(err)
+	defer gs.Close()
+
+	metrics := []metric.NamedMetric{
+		{Name: "PollCount", Value: metric.Counter(2), Labels: metric.Labels{"host": "a", "instance": "agent-1"}},
+		{Name: "Alloc", Value: metric.Gauge(1.5)},
+	}
+	require.NoError(gs.SendMetrics(metrics))
+	require.NoError(gs.SendMetricsBatch(metrics))
+
+	value, err := ms.GetLabeled("PollCount", "counter", metric.Labels{"host": "a", "instance": "agent-1"})
+	require.NoError(err)
+	require.Equal("4", value)
+
+	gs, err = sender.NewGRPCSender(lis.Addr().String(), time.Second, "another", retry.New())
+	require.NoError(err)
+	defer gs.Close()
+
+	err = gs.SendMetricsBatch(metrics)
+	require.Equal(codes.Unauthenticated, status.Code(err))
+}
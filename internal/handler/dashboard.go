@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/a-x-a/go-metric/assets"
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+type (
+	// dashboardPage - данные страницы со списком метрик.
+	dashboardPage struct {
+		// Refresh - интервал обновления страницы в секундах, 0 - без обновления.
+		Refresh int
+		Groups  []dashboardGroup
+	}
+
+	// dashboardGroup - метрики одного типа.
+	dashboardGroup struct {
+		Kind    string
+		Metrics []dashboardMetric
+	}
+
+	// dashboardMetric - строка таблицы метрик.
+	dashboardMetric struct {
+		Name   string
+		Labels string
+		Value  string
+		// Number - числовое значение для сортировки, если Numeric.
+		Number  float64
+		Numeric bool
+		Link    string
+	}
+
+	// metricPage - данные страницы метрики.
+	metricPage struct {
+		Refresh int
+		Name    string
+		Kind    string
+		Labels  string
+		Value   string
+		// Sparkline - координаты точек графика последних значений в формате SVG polyline,
+		// пустая строка, если история значений недоступна.
+		Sparkline string
+		Width     int
+		Height    int
+		Points    int
+		Min, Max  float64
+	}
+)
+
+const (
+	// dashboardRefresh - интервал обновления страниц панели мониторинга по умолчанию в секундах.
+	dashboardRefresh = 10
+	// sparklineWidth, sparklineHeight - размер графика последних значений метрики.
+	sparklineWidth, sparklineHeight = 300, 60
+	// sparklinePoints - максимальное количество значений на графике.
+	sparklinePoints = 100
+)
+
+var (
+	// templates - шаблоны страниц панели мониторинга.
+	templates = template.Must(template.ParseFS(assets.HTML, "html/*.tmpl"))
+)
+
+// List - выводит панель мониторинга с метриками, сгруппированными по типу,
+// или метриками, удовлетворяющими условию отбора в параметре match,
+// например match=PollCount{host="a"}. Параметр refresh задаёт интервал
+// обновления страницы в секундах, значение `0` отключает обновление.
+func (h metricHandlers) List(w http.ResponseWriter, r *http.Request) {
+	refresh, err := parseRefresh(r.URL.Query().Get("refresh"))
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	var selectors []string
+	if selector := r.URL.Query().Get("match"); len(selector) > 0 {
+		selectors = append(selectors, selector)
+	}
+
+	records, err := h.selectRecords(selectors)
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key() < records[j].Key()
+	})
+
+	groups := make(map[string]*dashboardGroup)
+	kinds := make([]string, 0)
+
+	for _, record := range records {
+		value := record.GetValue()
+		if value == nil {
+			continue
+		}
+
+		group, ok := groups[value.Kind()]
+		if !ok {
+			group = &dashboardGroup{Kind: value.Kind()}
+			groups[value.Kind()] = group
+			kinds = append(kinds, value.Kind())
+		}
+
+		row := dashboardMetric{
+			Name:   record.GetName(),
+			Labels: record.GetLabels().String(),
+			Value:  value.String(),
+			Link:   metricPageLink(record),
+		}
+		row.Number, row.Numeric = numericValue(value)
+
+		group.Metrics = append(group.Metrics, row)
+	}
+
+	sort.Strings(kinds)
+
+	page := dashboardPage{Refresh: refresh, Groups: make([]dashboardGroup, 0, len(kinds))}
+	for _, kind := range kinds {
+		page.Groups = append(page.Groups, *groups[kind])
+	}
+
+	h.render(w, "metrics.tmpl", page)
+}
+
+// Metric - выводит страницу метрики с графиком её последних значений.
+// Метки метрики передаются в параметре labels, например labels={host="a"}.
+func (h metricHandlers) Metric(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	name := chi.URLParam(r, "name")
+
+	refresh, err := parseRefresh(r.URL.Query().Get("refresh"))
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	labels, err := parseLabels(r.URL.Query().Get("labels"))
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	record, err := h.service.GetRecord(name, kind, labels)
+	switch {
+	case errors.Is(err, metric.ErrorAmbiguousMetric):
+		responseWithError(w, http.StatusConflict, err, h.logger)
+		return
+	case err != nil:
+		responseWithCode(w, http.StatusNotFound, h.logger)
+		return
+	}
+
+	// метрика без меток могла найтись среди метрик с метками.
+	labels = record.GetLabels()
+
+	page := metricPage{
+		Refresh: refresh,
+		Name:    name,
+		Kind:    kind,
+		Labels:  labels.String(),
+		Value:   record.GetValue().String(),
+		Width:   sparklineWidth,
+		Height:  sparklineHeight,
+	}
+
+	samples, err := h.service.HistoryLabeled(name, kind, labels, time.Time{}, time.Now())
+	switch {
+	case err == nil:
+		page.Sparkline, page.Points, page.Min, page.Max = sparkline(samples)
+	case !errors.Is(err, metricservice.ErrHistoryNotSupported) && !errors.Is(err, metric.ErrorMetricNotFound):
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	h.render(w, "metric.tmpl", page)
+}
+
+// render - выводит страницу по шаблону name.
+func (h metricHandlers) render(w http.ResponseWriter, name string, data interface{}) {
+	buf := bytes.Buffer{}
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Write(buf.Bytes())
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// parseRefresh - разбирает интервал обновления страницы в секундах.
+// Если значение не указано, то возвращает dashboardRefresh.
+func parseRefresh(value string) (int, error) {
+	if len(value) == 0 {
+		return dashboardRefresh, nil
+	}
+
+	refresh, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if refresh < 0 {
+		return 0, fmt.Errorf("invalid refresh interval %d", refresh)
+	}
+
+	return refresh, nil
+}
+
+// parseLabels - разбирает метки в каноническом виде {a="1",b="2"}.
+func parseLabels(value string) (metric.Labels, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(value, "{") {
+		return nil, metric.ErrorInvalidSelector
+	}
+
+	matchers, err := metric.ParseSelector(value)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(metric.Labels, len(matchers))
+	for _, m := range matchers {
+		if m.Type != metric.MatchEqual {
+			return nil, metric.ErrorInvalidSelector
+		}
+
+		labels[m.Name] = m.Value
+	}
+
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// metricPageLink - возвращает адрес страницы метрики record.
+func metricPageLink(record storage.Record) string {
+	link := "/dashboard/" + url.PathEscape(record.GetValue().Kind()) + "/" + url.PathEscape(record.GetName())
+
+	if labels := record.GetLabels(); len(labels) > 0 {
+		link += "?labels=" + url.QueryEscape(labels.String())
+	}
+
+	return link
+}
+
+// numericValue - возвращает числовое значение метрики для сортировки и графика:
+// значение counter и gauge, оценку количества элементов set
+// и количество наблюдений histogram, summary и sketch.
+func numericValue(value metric.Metric) (float64, bool) {
+	switch v := value.(type) {
+	case metric.Counter:
+		return float64(v), true
+	case metric.Gauge:
+		return float64(v), true
+	case metric.Set:
+		return float64(v.Estimate()), true
+	case metric.Histogram:
+		return float64(v.Count), true
+	case metric.Summary:
+		return float64(v.Count), true
+	case metric.Sketch:
+		return float64(v.Count), true
+	}
+
+	return 0, false
+}
+
+// sparkline - возвращает координаты точек графика последних sparklinePoints значений,
+// количество точек и диапазон значений.
+func sparkline(samples []storage.Sample) (string, int, float64, float64) {
+	if len(samples) > sparklinePoints {
+		samples = samples[len(samples)-sparklinePoints:]
+	}
+
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		if v, ok := numericValue(s.Value); ok {
+			values = append(values, v)
+		}
+	}
+
+	if len(values) == 0 {
+		return "", 0, 0, 0
+	}
+
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+
+		if v > max {
+			max = v
+		}
+	}
+
+	n := len(values)
+
+	// единственное значение выводится горизонтальной линией.
+	if n == 1 {
+		values = append(values, values[0])
+	}
+
+	points := make([]string, 0, len(values))
+	for i, v := range values {
+		x := float64(i) * sparklineWidth / float64(len(values)-1)
+		y := float64(sparklineHeight) / 2
+		if max > min {
+			// отступ в 1 точку, чтобы линия не обрезалась по краям.
+			y = 1 + (max-v)/(max-min)*(sparklineHeight-2)
+		}
+
+		points = append(points, strconv.FormatFloat(x, 'f', 1, 64)+","+strconv.FormatFloat(y, 'f', 1, 64))
+	}
+
+	return strings.Join(points, " "), n, min, max
+}
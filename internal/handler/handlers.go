@@ -1,14 +1,16 @@
 package handler
 
 import (
-	"fmt"
-	"io"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
 	"github.com/a-x-a/go-metric/internal/storage"
 )
 
@@ -17,8 +19,17 @@ type (
 		Push(name, kind, value string) error
 		PushCounter(name string, value metric.Counter) (metric.Counter, error)
 		PushGauge(name string, value metric.Gauge) (metric.Gauge, error)
-		Get(name, kind string) (string, error)
+		PushBatch(records []storage.Record) ([]storage.Record, error)
+		GetLabeled(name, kind string, labels metric.Labels) (string, error)
+		GetRecord(name, kind string, labels metric.Labels) (storage.Record, error)
 		GetAll() []storage.Record
+		Select(matchers []metric.Matcher) []storage.Record
+		HistoryLabeled(name, kind string, labels metric.Labels, from, to time.Time) ([]storage.Sample, error)
+		Agents() []metricservice.AgentInfo
+		Quantile(name string, labels metric.Labels, q float64) (float64, error)
+		Delete(name, kind string, labels metric.Labels) (storage.Record, error)
+		Query(q metricservice.Query) (metricservice.Page, error)
+		Subscribe(q metricservice.Query) (*metricservice.Subscription, error)
 	}
 	metricHandlers struct {
 		service metricService
@@ -33,30 +44,94 @@ func newMetricHandlers(s metricService, logger *zap.Logger) metricHandlers {
 	}
 }
 
-func (h metricHandlers) List(w http.ResponseWriter, r *http.Request) {
+// Get - выводит значение метрики типа kind с именем name и метками из параметра
+// labels в каноническом виде {a="1"}. Без параметра labels значение ищется
+// так же, как в metricService.GetLabeled: если метрике соответствует несколько
+// серий с разными метками, то возвращается код 409.
+func (h metricHandlers) Get(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
-	records := h.service.GetAll()
-	for _, v := range records {
-		io.WriteString(w, fmt.Sprintf("%s\t%s\n", v.GetName(), v.GetValue().String()))
+	kind := chi.URLParam(r, "kind")
+	name := chi.URLParam(r, "name")
+
+	labels, err := parseLabels(r.URL.Query().Get("labels"))
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	if q := r.URL.Query().Get("q"); len(q) > 0 && kind == string(metric.KindSketch) {
+		h.quantile(w, name, labels, q)
+		return
 	}
 
+	value, err := h.service.GetLabeled(name, kind, labels)
+	switch {
+	case errors.Is(err, metric.ErrorAmbiguousMetric):
+		responseWithError(w, http.StatusConflict, err, h.logger)
+		return
+	case err != nil:
+		responseWithCode(w, http.StatusNotFound, h.logger)
+		return
+	}
+
+	w.Write([]byte(value))
+
 	responseWithCode(w, http.StatusOK, h.logger)
 }
 
-func (h metricHandlers) Get(w http.ResponseWriter, r *http.Request) {
+// quantile - выводит оценку квантиля уровня q эскиза sketch с именем name и метками labels.
+func (h metricHandlers) quantile(w http.ResponseWriter, name string, labels metric.Labels, q string) {
+	level, err := strconv.ParseFloat(q, 64)
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	value, err := h.service.Quantile(name, labels, level)
+	switch {
+	case errors.Is(err, metric.ErrorMetricNotFound):
+		responseWithCode(w, http.StatusNotFound, h.logger)
+		return
+	case errors.Is(err, metric.ErrorAmbiguousMetric):
+		responseWithError(w, http.StatusConflict, err, h.logger)
+		return
+	case errors.Is(err, metric.ErrorInvalidQuantile):
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	case err != nil:
+		responseWithError(w, http.StatusNotFound, err, h.logger)
+		return
+	}
+
+	w.Write([]byte(strconv.FormatFloat(value, 'g', -1, 64)))
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// Delete - удаляет метрику типа kind с именем name точно с метками из параметра
+// labels в каноническом виде {a="1"}, без параметра - метрику без меток.
+func (h metricHandlers) Delete(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
 	kind := chi.URLParam(r, "kind")
 	name := chi.URLParam(r, "name")
 
-	value, err := h.service.Get(name, kind)
+	labels, err := parseLabels(r.URL.Query().Get("labels"))
 	if err != nil {
-		responseWithCode(w, http.StatusNotFound, h.logger)
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
 		return
 	}
 
-	w.Write([]byte(value))
+	_, err = h.service.Delete(name, kind, labels)
+	switch {
+	case errors.Is(err, metric.ErrorMetricNotFound), errors.Is(err, metric.ErrorInvalidMetricKind):
+		responseWithCode(w, http.StatusNotFound, h.logger)
+		return
+	case err != nil:
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
 
 	responseWithCode(w, http.StatusOK, h.logger)
 }
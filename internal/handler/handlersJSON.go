@@ -2,10 +2,24 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
 
 	"github.com/a-x-a/go-metric/internal/adapter"
 	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+var (
+	// ErrEmptyBatch - передан пустой набор метрик.
+	ErrEmptyBatch = errors.New("handler: передан пустой набор метрик")
 )
 
 func (h metricHandlers) UpdateJSON(w http.ResponseWriter, r *http.Request) {
@@ -21,6 +35,13 @@ func (h metricHandlers) UpdateJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch {
+	case len(data.Labels) > 0, len(data.TTL) > 0,
+		kind == metric.KindHistogram, kind == metric.KindSummary, kind == metric.KindSketch, kind == metric.KindSet:
+		h.updateRecordJSON(w, *data)
+		return
+	}
+
 	switch kind {
 	case metric.KindCounter:
 		val, err := h.service.PushCounter(data.ID, metric.Counter(*data.Delta))
@@ -60,46 +81,446 @@ func (h metricHandlers) GetJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	kind, err := metric.GetKind(data.MType)
-	if err != nil {
+	if _, err := metric.GetKind(data.MType); err != nil {
 		responseWithCode(w, http.StatusBadRequest, h.logger)
 		return
 	}
 
-	value, err := h.service.Get(data.ID, data.MType)
-	if err != nil {
+	if err := metric.Labels(data.Labels).Validate(); err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	record, err := h.service.GetRecord(data.ID, data.MType, data.Labels)
+	switch {
+	case errors.Is(err, metric.ErrorAmbiguousMetric):
+		responseWithError(w, http.StatusConflict, err, h.logger)
+		return
+	case err != nil:
 		responseWithCode(w, http.StatusNotFound, h.logger)
 		return
 	}
 
-	switch kind {
-	case metric.KindCounter:
-		val, err := metric.ToCounter(value)
-		if err != nil {
-			responseWithError(w, http.StatusInternalServerError, err, h.logger)
-			return
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(recordToRequestMetric(record)); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// DeleteJSON - удаляет метрику с именем, типом и метками из тела запроса
+// и возвращает её последнее значение.
+func (h metricHandlers) DeleteJSON(w http.ResponseWriter, r *http.Request) {
+	data := &adapter.RequestMetric{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	if _, err := metric.GetKind(data.MType); err != nil {
+		responseWithCode(w, http.StatusBadRequest, h.logger)
+		return
+	}
+
+	if err := metric.Labels(data.Labels).Validate(); err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	record, err := h.service.Delete(data.ID, data.MType, data.Labels)
+	switch {
+	case errors.Is(err, metric.ErrorMetricNotFound):
+		responseWithCode(w, http.StatusNotFound, h.logger)
+		return
+	case err != nil:
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(recordToRequestMetric(record)); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// updateRecordJSON - сохраняет метрику с метками или метрику histogram и summary.
+// Такие метрики сохраняются как набор из одной метрики, потому что PushCounter
+// и PushGauge идентифицируют метрику только по имени и принимают только counter и gauge.
+func (h metricHandlers) updateRecordJSON(w http.ResponseWriter, data adapter.RequestMetric) {
+	record, err := requestMetricToRecord(data)
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	records, err := h.service.PushBatch([]storage.Record{record})
+	if err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(recordToRequestMetric(records[0])); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+func (h metricHandlers) UpdatesJSON(w http.ResponseWriter, r *http.Request) {
+	data := []adapter.RequestMetric{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	records, err := requestMetricsToRecords(data)
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	records, err = h.service.PushBatch(records)
+	if err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	resp := make([]adapter.RequestMetric, 0, len(records))
+	for _, v := range records {
+		resp = append(resp, recordToRequestMetric(v))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// History - возвращает историю значений метрики типа kind с именем name и метками
+// из параметра labels в каноническом виде {a="1"} за период [from, to].
+// Без параметра labels метрика ищется так же, как в Get.
+func (h metricHandlers) History(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	name := chi.URLParam(r, "name")
+
+	labels, err := parseLabels(r.URL.Query().Get("labels"))
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	from, err := parseTime(r.URL.Query().Get("from"), time.Time{})
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	to, err := parseTime(r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	samples, err := h.service.HistoryLabeled(name, kind, labels, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, metricservice.ErrHistoryNotSupported):
+			responseWithError(w, http.StatusNotImplemented, err, h.logger)
+		case errors.Is(err, metric.ErrorAmbiguousMetric):
+			responseWithError(w, http.StatusConflict, err, h.logger)
+		default:
+			responseWithCode(w, http.StatusNotFound, h.logger)
 		}
 
-		newDelta := int64(val)
-		data.Delta = &newDelta
+		return
+	}
 
-	case metric.KindGauge:
-		val, err := metric.ToGauge(value)
-		if err != nil {
-			responseWithError(w, http.StatusInternalServerError, err, h.logger)
+	resp := adapter.ResponseHistory{
+		ID:     name,
+		MType:  kind,
+		Points: make([]adapter.HistoryPoint, 0, len(samples)),
+	}
+
+	for _, v := range samples {
+		resp.Points = append(resp.Points, adapter.NewHistoryPoint(v.Timestamp, v.Value))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// Query - возвращает страницу метрик в порядке возрастания их идентификаторов.
+// Параметры: match - шаблон имени (например, Heap*), regexp - регулярное выражение
+// для имени, kind - тип метрики, limit - количество метрик на странице,
+// cursor - курсор страницы из поля next_cursor предыдущего ответа.
+func (h metricHandlers) Query(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q := metricservice.Query{
+		Match:  params.Get("match"),
+		Regexp: params.Get("regexp"),
+		Kind:   params.Get("kind"),
+		Cursor: params.Get("cursor"),
+	}
+
+	if limit := params.Get("limit"); len(limit) > 0 {
+		var err error
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			responseWithError(w, http.StatusBadRequest, err, h.logger)
 			return
 		}
+	}
 
-		newValue := float64(val)
-		data.Value = &newValue
+	page, err := h.service.Query(q)
+	if err != nil {
+		switch {
+		case errors.Is(err, metricservice.ErrInvalidQuery),
+			errors.Is(err, metricservice.ErrInvalidCursor),
+			errors.Is(err, metric.ErrorInvalidMetricKind):
+			responseWithError(w, http.StatusBadRequest, err, h.logger)
+		default:
+			responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		}
+
+		return
+	}
+
+	resp := adapter.ResponseMetrics{
+		Metrics:    make([]adapter.RequestMetric, 0, len(page.Records)),
+		NextCursor: page.Next,
+	}
+
+	for _, record := range page.Records {
+		resp.Metrics = append(resp.Metrics, recordToRequestMetric(record))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(w).Encode(data); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		return
+	}
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// Agents - возвращает агентов, отправлявших метрики, и время последнего получения метрик от них.
+func (h metricHandlers) Agents(w http.ResponseWriter, r *http.Request) {
+	agents := h.service.Agents()
+
+	resp := make([]adapter.ResponseAgent, 0, len(agents))
+	for _, v := range agents {
+		resp = append(resp, adapter.ResponseAgent{ID: v.ID, LastSeen: v.LastSeen})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		responseWithError(w, http.StatusInternalServerError, err, h.logger)
 		return
 	}
 
 	responseWithCode(w, http.StatusOK, h.logger)
 }
+
+// parseTime - разбирает время в формате RFC 3339 или Unix time в секундах.
+// Если значение не указано, то возвращает def.
+func parseTime(value string, def time.Time) (time.Time, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// requestMetricsToRecords - проверяет пакет метрик из запроса и преобразует его в записи хранилища.
+// Ошибки всех некорректных метрик пакета объединяются в одну.
+func requestMetricsToRecords(data []adapter.RequestMetric) ([]storage.Record, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	records := make([]storage.Record, 0, len(data))
+	errs := make([]string, 0)
+
+	for i, v := range data {
+		record, err := requestMetricToRecord(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("metric #%d (%q): %s", i, v.ID, err.Error()))
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	return records, nil
+}
+
+// requestMetricToRecord - проверяет метрику из запроса и преобразует её в запись хранилища.
+func requestMetricToRecord(data adapter.RequestMetric) (storage.Record, error) {
+	kind, err := metric.GetKind(data.MType)
+	if err != nil {
+		return storage.Record{}, err
+	}
+
+	record, err := storage.NewRecord(data.ID)
+	if err != nil {
+		return storage.Record{}, err
+	}
+
+	labels := metric.Labels(data.Labels)
+	if err := labels.Validate(); err != nil {
+		return storage.Record{}, err
+	}
+
+	record.SetLabels(labels)
+
+	if len(data.TTL) > 0 {
+		ttl, err := time.ParseDuration(data.TTL)
+		if err != nil {
+			return storage.Record{}, err
+		}
+
+		if err := record.SetTTL(ttl); err != nil {
+			return storage.Record{}, err
+		}
+	}
+
+	switch kind {
+	case metric.KindCounter:
+		if data.Delta == nil {
+			return storage.Record{}, metric.ErrorMetricValueIsNull
+		}
+
+		record.SetValue(metric.Counter(*data.Delta))
+
+	case metric.KindGauge:
+		if data.Value == nil {
+			return storage.Record{}, metric.ErrorMetricValueIsNull
+		}
+
+		record.SetValue(metric.Gauge(*data.Value))
+
+	case metric.KindHistogram:
+		if data.Sum == nil || data.Count == nil || len(data.Buckets) == 0 {
+			return storage.Record{}, metric.ErrorMetricValueIsNull
+		}
+
+		value := metric.Histogram{Bounds: data.Bounds, Buckets: data.Buckets, Sum: *data.Sum, Count: *data.Count}
+		if err := value.Validate(); err != nil {
+			return storage.Record{}, err
+		}
+
+		record.SetValue(value)
+
+	case metric.KindSummary:
+		if data.Sum == nil || data.Count == nil {
+			return storage.Record{}, metric.ErrorMetricValueIsNull
+		}
+
+		value := metric.Summary{Sum: *data.Sum, Count: *data.Count}
+		for _, q := range data.Quantiles {
+			value.Quantiles = append(value.Quantiles, metric.Quantile{Quantile: q.Quantile, Value: q.Value})
+		}
+
+		if err := value.Validate(); err != nil {
+			return storage.Record{}, err
+		}
+
+		record.SetValue(value)
+
+	case metric.KindSketch:
+		if data.Sum == nil || data.Count == nil || data.Sketch == nil {
+			return storage.Record{}, metric.ErrorMetricValueIsNull
+		}
+
+		value, err := data.Sketch.ToMetric(*data.Sum, *data.Count)
+		if err != nil {
+			return storage.Record{}, err
+		}
+
+		if err := value.Validate(); err != nil {
+			return storage.Record{}, err
+		}
+
+		record.SetValue(value)
+
+	case metric.KindSet:
+		if len(data.Registers) == 0 && len(data.Members) == 0 {
+			return storage.Record{}, metric.ErrorMetricValueIsNull
+		}
+
+		value := metric.Set{Registers: append([]uint8(nil), data.Registers...)}
+		if len(value.Registers) > 0 {
+			if err := value.Validate(); err != nil {
+				return storage.Record{}, err
+			}
+		}
+
+		for _, member := range data.Members {
+			value.Add(member)
+		}
+
+		record.SetValue(value)
+	}
+
+	return record, nil
+}
+
+// recordToRequestMetric - преобразует запись хранилища в метрику для ответа.
+func recordToRequestMetric(record storage.Record) adapter.RequestMetric {
+	data := adapter.RequestMetric{ID: record.GetName()}
+
+	switch v := record.GetValue().(type) {
+	case metric.Counter:
+		data = adapter.NewUpdateRequestMetricCounter(record.GetName(), v)
+	case metric.Gauge:
+		data = adapter.NewUpdateRequestMetricGauge(record.GetName(), v)
+	case metric.Histogram:
+		data = adapter.NewUpdateRequestMetricHistogram(record.GetName(), v)
+	case metric.Summary:
+		data = adapter.NewUpdateRequestMetricSummary(record.GetName(), v)
+	case metric.Sketch:
+		data = adapter.NewUpdateRequestMetricSketch(record.GetName(), v)
+	case metric.Set:
+		data = adapter.NewUpdateRequestMetricSet(record.GetName(), v)
+	}
+
+	if labels := record.GetLabels(); len(labels) > 0 {
+		data.Labels = labels
+	}
+
+	if ttl := record.GetTTL(); ttl > 0 {
+		data.TTL = ttl.String()
+	}
+
+	return data
+}
@@ -6,17 +6,22 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/storage"
 )
 
 func sendTestRequest(t *testing.T, method, path string, data []byte) *http.Response {
-	rt := NewRouter(mockService{}, zap.NewNop())
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
 	srv := httptest.NewServer(rt)
 	defer srv.Close()
 
@@ -31,6 +36,11 @@ func sendTestRequest(t *testing.T, method, path string, data []byte) *http.Respo
 	return resp
 }
 
+func labeled(m adapter.RequestMetric, labels map[string]string) adapter.RequestMetric {
+	m.Labels = labels
+	return m
+}
+
 func TestUpdateJSONMetric(t *testing.T) {
 	type result struct {
 		code int
@@ -79,6 +89,131 @@ func TestUpdateJSONMetric(t *testing.T) {
 				code: http.StatusInternalServerError,
 			},
 		},
+		{
+			name: "push labeled counter",
+			req:  labeled(adapter.NewUpdateRequestMetricCounter("Requests", 10), map[string]string{"host": "a"}),
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push invalid label name",
+			req:  labeled(adapter.NewUpdateRequestMetricGauge("Alloc", 1), map[string]string{"__host": "a"}),
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push histogram",
+			req: adapter.NewUpdateRequestMetricHistogram("Latency",
+				metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{1, 0, 2}, Sum: 7.05, Count: 3}),
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push histogram with wrong buckets",
+			req: adapter.NewUpdateRequestMetricHistogram("Latency",
+				metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{1, 2}, Sum: 7.05, Count: 3}),
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push histogram without sum",
+			req:  adapter.RequestMetric{ID: "Latency", MType: "histogram", Buckets: []uint64{1}},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push summary",
+			req: adapter.NewUpdateRequestMetricSummary("Duration",
+				metric.Summary{Quantiles: []metric.Quantile{{Quantile: 0.5, Value: 0.2}}, Sum: 3, Count: 10}),
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push sketch",
+			req: adapter.NewUpdateRequestMetricSketch("Size",
+				metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{0: 1, 50: 2}, Count: 3, Sum: 6.4, Min: 1, Max: 2.7}),
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push sketch with wrong count",
+			req: adapter.NewUpdateRequestMetricSketch("Size",
+				metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{0: 1}, Count: 3, Sum: 6.4, Min: 1, Max: 2.7}),
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push sketch without bins",
+			req:  adapter.RequestMetric{ID: "Size", MType: "sketch"},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push set registers",
+			req:  adapter.NewUpdateRequestMetricSet("Users", metric.Set{Registers: make([]uint8, 16)}),
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push set with invalid registers",
+			req:  adapter.RequestMetric{ID: "Users", MType: "set", Registers: make([]byte, 10), Members: []string{"alice"}},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push empty set",
+			req:  adapter.RequestMetric{ID: "Users", MType: "set"},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push summary with invalid quantile",
+			req: adapter.NewUpdateRequestMetricSummary("Duration",
+				metric.Summary{Quantiles: []metric.Quantile{{Quantile: 2, Value: 0.2}}, Sum: 3, Count: 10}),
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push empty label value",
+			req:  labeled(adapter.NewUpdateRequestMetricGauge("Alloc", 1), map[string]string{"host": ""}),
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push with ttl",
+			req:  adapter.RequestMetric{ID: "Alloc", MType: "gauge", Value: adapter.NewUpdateRequestMetricGauge("Alloc", 1).Value, TTL: "10m0s"},
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push with invalid ttl",
+			req:  adapter.RequestMetric{ID: "Alloc", MType: "gauge", Value: adapter.NewUpdateRequestMetricGauge("Alloc", 1).Value, TTL: "soon"},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push with negative ttl",
+			req:  adapter.RequestMetric{ID: "Alloc", MType: "gauge", Value: adapter.NewUpdateRequestMetricGauge("Alloc", 1).Value, TTL: "-1m"},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -121,6 +256,24 @@ func TestUpdateJSONMetric(t *testing.T) {
 
 		require.Equal(http.StatusBadRequest, resp.StatusCode)
 	})
+
+	t.Run("push set members", func(t *testing.T) {
+		require := require.New(t)
+
+		data, err := json.Marshal(adapter.RequestMetric{ID: "Users", MType: "set", Members: []string{"alice", "bob", "alice"}})
+		require.NoError(err)
+
+		resp := sendTestRequest(t, http.MethodPost, "/update/", data)
+		defer resp.Body.Close()
+
+		require.Equal(http.StatusOK, resp.StatusCode)
+
+		var got adapter.RequestMetric
+		require.NoError(json.NewDecoder(resp.Body).Decode(&got))
+		require.Empty(got.Members)
+		require.Len(got.Registers, 1<<metric.DefaultSetPrecision)
+		require.Equal(uint64(2), *got.Count)
+	})
 }
 
 func TestGetJSONMetric(t *testing.T) {
@@ -147,7 +300,7 @@ func TestGetJSONMetric(t *testing.T) {
 			req:  adapter.NewGetRequestMetricGauge("Alloc"),
 			expected: result{
 				code: http.StatusOK,
-				body: adapter.NewUpdateRequestMetricGauge("Alloc", 12.345),
+				body: adapter.NewUpdateRequestMetricGauge("Alloc", 12.3456),
 			},
 		},
 		{
@@ -171,6 +324,62 @@ func TestGetJSONMetric(t *testing.T) {
 				code: http.StatusNotFound,
 			},
 		},
+		{
+			name: "get labeled counter",
+			req:  labeled(adapter.NewGetRequestMetricCounter("Requests"), map[string]string{"host": "b"}),
+			expected: result{
+				code: http.StatusOK,
+				body: labeled(adapter.NewUpdateRequestMetricCounter("Requests", 3), map[string]string{"host": "b"}),
+			},
+		},
+		{
+			name: "get histogram",
+			req:  adapter.RequestMetric{ID: "Latency", MType: "histogram"},
+			expected: result{
+				code: http.StatusOK,
+				body: adapter.NewUpdateRequestMetricHistogram("Latency",
+					metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{1, 0, 2}, Sum: 7.05, Count: 3}),
+			},
+		},
+		{
+			name: "get sketch",
+			req:  adapter.RequestMetric{ID: "Size", MType: "sketch"},
+			expected: result{
+				code: http.StatusOK,
+				body: adapter.NewUpdateRequestMetricSketch("Size",
+					metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{0: 1, 50: 2}, Count: 3, Sum: 6.4, Min: 1, Max: 2.7}),
+			},
+		},
+		{
+			name: "get set registers",
+			req:  adapter.RequestMetric{ID: "Users", MType: "set"},
+			expected: result{
+				code: http.StatusOK,
+				body: adapter.NewUpdateRequestMetricSet("Users",
+					metric.Set{Registers: []uint8{1, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}),
+			},
+		},
+		{
+			name: "get summary as histogram",
+			req:  labeled(adapter.RequestMetric{ID: "Duration", MType: "histogram"}, map[string]string{"host": "a"}),
+			expected: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "get unknown labels",
+			req:  labeled(adapter.NewGetRequestMetricCounter("Requests"), map[string]string{"host": "c"}),
+			expected: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "get invalid labels",
+			req:  labeled(adapter.NewGetRequestMetricCounter("Requests"), map[string]string{"host-name": "a"}),
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -214,3 +423,540 @@ func TestGetJSONMetric(t *testing.T) {
 		require.Equal(http.StatusBadRequest, resp.StatusCode)
 	})
 }
+
+func TestDeleteJSONMetric(t *testing.T) {
+	tt := []struct {
+		name string
+		body string
+		code int
+		want adapter.RequestMetric
+	}{
+		{
+			name: "delete labeled counter",
+			body: `{"id":"Requests","type":"counter","labels":{"host":"b"}}`,
+			code: http.StatusOK,
+			want: labeled(adapter.NewUpdateRequestMetricCounter("Requests", 3), map[string]string{"host": "b"}),
+		},
+		{name: "delete unknown labels", body: `{"id":"Requests","type":"counter","labels":{"host":"c"}}`, code: http.StatusNotFound},
+		{name: "delete unknown kind", body: `{"id":"Alloc","type":"unknown"}`, code: http.StatusBadRequest},
+		{name: "delete invalid label", body: `{"id":"Alloc","type":"gauge","labels":{"__host":"a"}}`, code: http.StatusBadRequest},
+		{name: "delete invalid body", body: `invalid`, code: http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			resp := sendTestRequest(t, http.MethodDelete, "/value/", []byte(tc.body))
+			defer resp.Body.Close()
+
+			require.Equal(tc.code, resp.StatusCode)
+
+			if tc.code == http.StatusOK {
+				var got adapter.RequestMetric
+				require.NoError(json.NewDecoder(resp.Body).Decode(&got))
+				require.Equal(tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdatesJSONMetric(t *testing.T) {
+	type result struct {
+		code int
+	}
+
+	tt := []struct {
+		name     string
+		req      []adapter.RequestMetric
+		expected result
+	}{
+		{
+			name: "push batch",
+			req: []adapter.RequestMetric{
+				adapter.NewUpdateRequestMetricCounter("PollCount", 10),
+				adapter.NewUpdateRequestMetricGauge("Alloc", 13.123),
+			},
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push empty batch",
+			req:  []adapter.RequestMetric{},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push batch with unknown metric kind",
+			req: []adapter.RequestMetric{
+				adapter.NewUpdateRequestMetricGauge("Alloc", 13.123),
+				{ID: "X", MType: "unknown"},
+			},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push batch with blank name",
+			req: []adapter.RequestMetric{
+				adapter.NewUpdateRequestMetricCounter("", 10),
+			},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push batch without counter delta",
+			req: []adapter.RequestMetric{
+				{ID: "PollCount", MType: "counter"},
+			},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push labeled batch",
+			req: []adapter.RequestMetric{
+				labeled(adapter.NewUpdateRequestMetricCounter("Requests", 1), map[string]string{"host": "a"}),
+				labeled(adapter.NewUpdateRequestMetricCounter("Requests", 2), map[string]string{"host": "b"}),
+			},
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push batch with invalid label",
+			req: []adapter.RequestMetric{
+				labeled(adapter.NewUpdateRequestMetricGauge("Alloc", 1), map[string]string{"1host": "a"}),
+			},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push batch with histogram and summary",
+			req: []adapter.RequestMetric{
+				adapter.NewUpdateRequestMetricHistogram("Latency",
+					metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{1, 0}, Sum: 0.5, Count: 1}),
+				adapter.NewUpdateRequestMetricSummary("Duration", metric.Summary{Sum: 3, Count: 10}),
+			},
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "push batch with inconsistent histogram count",
+			req: []adapter.RequestMetric{
+				adapter.NewUpdateRequestMetricHistogram("Latency",
+					metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{1, 0}, Sum: 0.5, Count: 4}),
+			},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "push batch without gauge value",
+			req: []adapter.RequestMetric{
+				{ID: "Alloc", MType: "gauge"},
+			},
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			data, err := json.Marshal(tc.req)
+			require.NoError(err)
+
+			resp := sendTestRequest(t, http.MethodPost, "/updates/", data)
+
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(err)
+			defer resp.Body.Close()
+
+			assert.Equal(tc.expected.code, resp.StatusCode)
+
+			if tc.expected.code == http.StatusOK {
+				assert.Equal("application/json", resp.Header.Get("Content-Type"))
+
+				var resp []adapter.RequestMetric
+				err = json.Unmarshal(respBody, &resp)
+				require.NoError(err)
+
+				assert.Equal(tc.req, resp)
+			}
+		})
+	}
+
+	t.Run("error decode data", func(t *testing.T) {
+		require := require.New(t)
+
+		data := []byte(`invalid`)
+		resp := sendTestRequest(t, http.MethodPost, "/updates/", data)
+
+		_, err := io.ReadAll(resp.Body)
+		require.NoError(err)
+		defer resp.Body.Close()
+
+		require.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestUpdateJSONSketchBounds(t *testing.T) {
+	require := require.New(t)
+
+	s := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	srv := httptest.NewServer(NewRouter(s, zap.NewNop(), "", nil))
+	defer srv.Close()
+
+	push := func(offset int) int {
+		sum, count := 1.0, uint64(1)
+		data, err := json.Marshal(adapter.RequestMetric{
+			ID: "Size", MType: "sketch", Sum: &sum, Count: &count,
+			Sketch: &adapter.Sketch{
+				RelativeAccuracy: metric.DefaultSketchAccuracy, Min: 1, Max: 1,
+				Positive: &adapter.SketchBins{Offset: offset, Counts: []uint64{1}},
+			},
+		})
+		require.NoError(err)
+
+		resp, err := http.Post(srv.URL+"/update/", "application/json", bytes.NewReader(data))
+		require.NoError(err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	require.Equal(http.StatusOK, push(0))
+
+	// объединение с далёкой корзиной потребовало бы миллиарды корзин в компактном виде.
+	require.Equal(http.StatusBadRequest, push(2000000000))
+
+	record, err := s.GetRecord("Size", "sketch", nil)
+	require.NoError(err)
+	require.Equal(metric.SketchBins{0: 1}, record.GetValue().(metric.Sketch).Positive)
+}
+
+func TestHistoryJSONMetric(t *testing.T) {
+	type result struct {
+		code   int
+		points int
+	}
+
+	tt := []struct {
+		name     string
+		path     string
+		expected result
+	}{
+		{
+			name: "full history",
+			path: "/history/gauge/Alloc",
+			expected: result{
+				code:   http.StatusOK,
+				points: 3,
+			},
+		},
+		{
+			name: "history with unix time range",
+			path: "/history/gauge/Alloc?from=150&to=250",
+			expected: result{
+				code:   http.StatusOK,
+				points: 1,
+			},
+		},
+		{
+			name: "history with RFC 3339 time range",
+			path: "/history/gauge/Alloc?from=" + time.Unix(150, 0).UTC().Format(time.RFC3339),
+			expected: result{
+				code:   http.StatusOK,
+				points: 2,
+			},
+		},
+		{
+			name: "invalid time range",
+			path: "/history/gauge/Alloc?from=yesterday",
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "unknown metric",
+			path: "/history/gauge/unknown",
+			expected: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "unknown metric kind",
+			path: "/history/unknown/Alloc",
+			expected: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "history disabled",
+			path: "/history/gauge/Disabled",
+			expected: result{
+				code: http.StatusNotImplemented,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			resp := sendTestRequest(t, http.MethodGet, tc.path, nil)
+
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(err)
+			defer resp.Body.Close()
+
+			assert.Equal(tc.expected.code, resp.StatusCode)
+
+			if tc.expected.code == http.StatusOK {
+				assert.Equal("application/json", resp.Header.Get("Content-Type"))
+
+				var history adapter.ResponseHistory
+				err = json.Unmarshal(respBody, &history)
+				require.NoError(err)
+
+				assert.Equal("Alloc", history.ID)
+				assert.Equal("gauge", history.MType)
+				assert.Len(history.Points, tc.expected.points)
+			}
+		})
+	}
+}
+
+func TestHistoryJSONLabeledMetric(t *testing.T) {
+	require := require.New(t)
+
+	s := metricservice.New(storage.NewWithHistoryStorage(storage.NewMemStorage(), time.Minute, 10), zap.NewNop())
+	srv := httptest.NewServer(NewRouter(s, zap.NewNop(), "", nil))
+	defer srv.Close()
+
+	push := func(host string, value float64) {
+		data, err := json.Marshal(adapter.RequestMetric{
+			ID: "Alloc", MType: "gauge", Value: &value, Labels: map[string]string{"host": host},
+		})
+		require.NoError(err)
+
+		resp, err := http.Post(srv.URL+"/update/", "application/json", bytes.NewReader(data))
+		require.NoError(err)
+		defer resp.Body.Close()
+
+		require.Equal(http.StatusOK, resp.StatusCode)
+	}
+
+	history := func(path string) (int, adapter.ResponseHistory) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(err)
+		defer resp.Body.Close()
+
+		var history adapter.ResponseHistory
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(json.NewDecoder(resp.Body).Decode(&history))
+		}
+
+		return resp.StatusCode, history
+	}
+
+	push("a", 1.5)
+	push("a", 2.5)
+
+	code, h := history("/history/gauge/Alloc?labels=" + url.QueryEscape(`{host="a"}`))
+	require.Equal(http.StatusOK, code)
+	require.Len(h.Points, 2)
+
+	// единственная серия находится и без меток.
+	code, h = history("/history/gauge/Alloc")
+	require.Equal(http.StatusOK, code)
+	require.Len(h.Points, 2)
+
+	code, _ = history("/history/gauge/Alloc?labels=" + url.QueryEscape(`{host="b"}`))
+	require.Equal(http.StatusNotFound, code)
+
+	code, _ = history("/history/gauge/Alloc?labels=" + url.QueryEscape(`{host=`))
+	require.Equal(http.StatusBadRequest, code)
+
+	// страница метрики без меток строит график по найденной серии.
+	resp, err := http.Get(srv.URL + "/dashboard/gauge/Alloc")
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Contains(string(page), "2 values from 1.5 to 2.5")
+
+	push("b", 3.5)
+
+	code, h = history("/history/gauge/Alloc?labels=" + url.QueryEscape(`{host="b"}`))
+	require.Equal(http.StatusOK, code)
+	require.Len(h.Points, 1)
+
+	code, _ = history("/history/gauge/Alloc")
+	require.Equal(http.StatusConflict, code)
+}
+
+func TestQueryJSON(t *testing.T) {
+	type result struct {
+		code  int
+		names []string
+		next  bool
+	}
+
+	tt := []struct {
+		name     string
+		path     string
+		expected result
+	}{
+		{
+			name: "all metrics",
+			path: "/api/metrics",
+			expected: result{
+				code:  http.StatusOK,
+				names: []string{"Alloc", "Duration", "Latency", "PollCount", "Random", "Requests", "Requests", "Size", "Users"},
+			},
+		},
+		{
+			name: "glob and kind",
+			path: "/api/metrics?match=R*&kind=gauge",
+			expected: result{
+				code:  http.StatusOK,
+				names: []string{"Random"},
+			},
+		},
+		{
+			name: "regexp",
+			path: "/api/metrics?regexp=^(Alloc|Size)$",
+			expected: result{
+				code:  http.StatusOK,
+				names: []string{"Alloc", "Size"},
+			},
+		},
+		{
+			name: "limit",
+			path: "/api/metrics?match=*&limit=2",
+			expected: result{
+				code:  http.StatusOK,
+				names: []string{"Alloc", "Duration"},
+				next:  true,
+			},
+		},
+		{
+			name: "invalid limit",
+			path: "/api/metrics?limit=many",
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "invalid glob",
+			path: "/api/metrics?match=%5B",
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "invalid kind",
+			path: "/api/metrics?kind=unknown",
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "invalid cursor",
+			path: "/api/metrics?cursor=%21",
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			resp := sendTestRequest(t, http.MethodGet, tc.path, nil)
+
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(err)
+			defer resp.Body.Close()
+
+			assert.Equal(tc.expected.code, resp.StatusCode)
+
+			if tc.expected.code == http.StatusOK {
+				assert.Equal("application/json", resp.Header.Get("Content-Type"))
+
+				var page adapter.ResponseMetrics
+				err = json.Unmarshal(respBody, &page)
+				require.NoError(err)
+
+				names := make([]string, 0, len(page.Metrics))
+				for _, m := range page.Metrics {
+					names = append(names, m.ID)
+				}
+
+				assert.Equal(tc.expected.names, names)
+				assert.Equal(tc.expected.next, len(page.NextCursor) > 0)
+			}
+		})
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		require := require.New(t)
+
+		names := make([]string, 0)
+		cursor := ""
+
+		for {
+			resp := sendTestRequest(t, http.MethodGet, "/api/metrics?limit=4&cursor="+cursor, nil)
+
+			var page adapter.ResponseMetrics
+			err := json.NewDecoder(resp.Body).Decode(&page)
+			resp.Body.Close()
+			require.NoError(err)
+			require.Equal(http.StatusOK, resp.StatusCode)
+
+			for _, m := range page.Metrics {
+				names = append(names, m.ID)
+			}
+
+			if len(page.NextCursor) == 0 {
+				break
+			}
+
+			cursor = page.NextCursor
+		}
+
+		require.Equal([]string{"Alloc", "Duration", "Latency", "PollCount", "Random", "Requests", "Requests", "Size", "Users"}, names)
+	})
+}
+
+func TestAgentsJSON(t *testing.T) {
+	require := require.New(t)
+
+	resp := sendTestRequest(t, http.MethodGet, "/agents", nil)
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal("application/json", resp.Header.Get("Content-Type"))
+	require.JSONEq(`[
+		{"id": "agent-1", "last_seen": "1970-01-01T00:01:40Z"},
+		{"id": "agent-2", "last_seen": "1970-01-01T00:03:20Z"}
+	]`, string(respBody))
+}
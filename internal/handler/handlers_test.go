@@ -2,16 +2,20 @@ package handler
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
 	"github.com/a-x-a/go-metric/internal/storage"
 )
 
@@ -57,6 +61,16 @@ func (s mockService) PushGauge(name string, value metric.Gauge) (metric.Gauge, e
 	return value, nil
 }
 
+func (s mockService) PushBatch(records []storage.Record) ([]storage.Record, error) {
+	for _, v := range records {
+		if v.GetName() == "" {
+			return nil, storage.ErrInvalidName
+		}
+	}
+
+	return records, nil
+}
+
 func (s mockService) Get(name, kind string) (string, error) {
 	_, err := metric.GetKind(kind)
 	if err != nil {
@@ -76,6 +90,44 @@ func (s mockService) Get(name, kind string) (string, error) {
 	return value, nil
 }
 
+func (s mockService) GetLabeled(name, kind string, labels metric.Labels) (string, error) {
+	if len(labels) == 0 {
+		return s.Get(name, kind)
+	}
+
+	record, err := s.GetRecord(name, kind, labels)
+	if err != nil {
+		return "", err
+	}
+
+	return record.GetValue().String(), nil
+}
+
+func (s mockService) GetRecord(name, kind string, labels metric.Labels) (storage.Record, error) {
+	if _, err := metric.GetKind(kind); err != nil {
+		return storage.Record{}, err
+	}
+
+	for _, record := range s.GetAll() {
+		if record.Key() == metric.Key(name, labels) && record.GetValue().Kind() == kind {
+			return record, nil
+		}
+	}
+
+	return storage.Record{}, metric.ErrorMetricNotFound
+}
+
+func (s mockService) Select(matchers []metric.Matcher) []storage.Record {
+	records := []storage.Record{}
+	for _, record := range s.GetAll() {
+		if metric.MatchLabels(matchers, record.GetName(), record.GetLabels()) {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
 func (s mockService) GetAll() []storage.Record {
 	records := []storage.Record{}
 	record, _ := storage.NewRecord("Alloc")
@@ -90,11 +142,100 @@ func (s mockService) GetAll() []storage.Record {
 	record.SetValue(metric.Gauge(1313.1313))
 	records = append(records, record)
 
+	record, _ = storage.NewRecord("Requests")
+	record.SetLabels(metric.Labels{"host": "b"})
+	record.SetValue(metric.Counter(3))
+	records = append(records, record)
+
+	record, _ = storage.NewRecord("Requests")
+	record.SetLabels(metric.Labels{"host": "a", "path": "/update/"})
+	record.SetValue(metric.Counter(7))
+	records = append(records, record)
+
+	record, _ = storage.NewRecord("Latency")
+	record.SetValue(metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{1, 0, 2}, Sum: 7.05, Count: 3})
+	records = append(records, record)
+
+	record, _ = storage.NewRecord("Duration")
+	record.SetLabels(metric.Labels{"host": "a"})
+	record.SetValue(metric.Summary{Quantiles: []metric.Quantile{{Quantile: 0.5, Value: 0.2}, {Quantile: 0.99, Value: 0.9}}, Sum: 3, Count: 10})
+	records = append(records, record)
+
+	record, _ = storage.NewRecord("Size")
+	record.SetValue(metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{0: 1, 50: 2}, Count: 3, Sum: 6.4, Min: 1, Max: 2.7})
+	records = append(records, record)
+
+	record, _ = storage.NewRecord("Users")
+	record.SetValue(metric.Set{Registers: []uint8{1, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}})
+	records = append(records, record)
+
 	return records
 }
 
+func (s mockService) Delete(name, kind string, labels metric.Labels) (storage.Record, error) {
+	return s.GetRecord(name, kind, labels)
+}
+
+func (s mockService) Quantile(name string, labels metric.Labels, q float64) (float64, error) {
+	record, err := s.GetRecord(name, string(metric.KindSketch), labels)
+	if err != nil {
+		return 0, err
+	}
+
+	return record.GetValue().(metric.Sketch).Quantile(q)
+}
+
+func (s mockService) Query(q metricservice.Query) (metricservice.Page, error) {
+	stor := storage.NewMemStorage()
+	if err := stor.PushBatch(s.GetAll()); err != nil {
+		return metricservice.Page{}, err
+	}
+
+	return metricservice.New(stor, zap.NewNop()).Query(q)
+}
+
+func (s mockService) Subscribe(q metricservice.Query) (*metricservice.Subscription, error) {
+	return metricservice.New(storage.NewMemStorage(), zap.NewNop()).Subscribe(q)
+}
+
+func (s mockService) Agents() []metricservice.AgentInfo {
+	return []metricservice.AgentInfo{
+		{ID: "agent-1", LastSeen: time.Unix(100, 0).UTC()},
+		{ID: "agent-2", LastSeen: time.Unix(200, 0).UTC()},
+	}
+}
+
+func (s mockService) HistoryLabeled(name, kind string, labels metric.Labels, from, to time.Time) ([]storage.Sample, error) {
+	if _, err := metric.GetKind(kind); err != nil {
+		return nil, err
+	}
+
+	if name == "Disabled" {
+		return nil, metricservice.ErrHistoryNotSupported
+	}
+
+	if name != "Alloc" || len(labels) > 0 {
+		return nil, metric.ErrorMetricNotFound
+	}
+
+	samples := []storage.Sample{
+		{Timestamp: time.Unix(100, 0), Value: metric.Gauge(1.5)},
+		{Timestamp: time.Unix(200, 0), Value: metric.Gauge(2.5)},
+		{Timestamp: time.Unix(300, 0), Value: metric.Gauge(3.5)},
+	}
+
+	result := make([]storage.Sample, 0)
+	for _, v := range samples {
+		if !v.Timestamp.Before(from) && !v.Timestamp.After(to) {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
+
 func TestUpdateHandler(t *testing.T) {
-	rt := NewRouter(mockService{}, zap.NewNop())
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
 	srv := httptest.NewServer(rt)
 	defer srv.Close()
 
@@ -184,7 +325,7 @@ func TestUpdateHandler(t *testing.T) {
 }
 
 func TestGetHandler(t *testing.T) {
-	rt := NewRouter(mockService{}, zap.NewNop())
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
 	srv := httptest.NewServer(rt)
 	defer srv.Close()
 
@@ -237,6 +378,30 @@ func TestGetHandler(t *testing.T) {
 				code: http.StatusNotFound,
 			},
 		},
+		{
+			name:   "get labeled metric",
+			path:   "/value/counter/Requests?labels=" + url.QueryEscape(`{host="b"}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:   "get labeled metric with unknown labels",
+			path:   "/value/counter/Requests?labels=" + url.QueryEscape(`{host="c"}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "get with invalid labels",
+			path:   "/value/counter/Requests?labels=" + url.QueryEscape(`{host=~"b"}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -257,13 +422,87 @@ func TestGetHandler(t *testing.T) {
 	}
 }
 
+func TestGetQuantileHandler(t *testing.T) {
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	tt := []struct {
+		name string
+		path string
+		code int
+		body string
+	}{
+		{name: "median", path: "/value/sketch/Size?q=0.5", code: http.StatusOK, body: "2.691188720352608"},
+		{name: "minimum", path: "/value/sketch/Size?q=0", code: http.StatusOK, body: "1"},
+		{name: "invalid level", path: "/value/sketch/Size?q=1.5", code: http.StatusBadRequest},
+		{name: "not a number", path: "/value/sketch/Size?q=p99", code: http.StatusBadRequest},
+		{name: "unknown sketch", path: "/value/sketch/unknown?q=0.5", code: http.StatusNotFound},
+		{name: "not a sketch", path: "/value/sketch/Latency?q=0.5", code: http.StatusNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tc.path)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.code, resp.StatusCode)
+			if tc.code == http.StatusOK {
+				assert.Equal(t, tc.body, string(body))
+			}
+		})
+	}
+}
+
+func TestDeleteHandler(t *testing.T) {
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	tt := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "delete gauge", path: "/value/gauge/Alloc", code: http.StatusOK},
+		{name: "delete with wrong kind", path: "/value/counter/Alloc", code: http.StatusNotFound},
+		{name: "delete unknown metric", path: "/value/gauge/unknown", code: http.StatusNotFound},
+		{name: "delete unknown kind", path: "/value/unknown/Alloc", code: http.StatusNotFound},
+		{name: "delete labeled", path: "/value/counter/Requests?labels=" + url.QueryEscape(`{host="b"}`), code: http.StatusOK},
+		{name: "delete with unknown labels", path: "/value/counter/Requests?labels=" + url.QueryEscape(`{host="c"}`), code: http.StatusNotFound},
+		{name: "delete without labels", path: "/value/counter/Requests", code: http.StatusNotFound},
+		{name: "delete with invalid labels", path: "/value/counter/Requests?labels=host", code: http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodDelete, srv.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.code, resp.StatusCode)
+		})
+	}
+}
+
 func TestListHandler(t *testing.T) {
-	rt := NewRouter(mockService{}, zap.NewNop())
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
 	srv := httptest.NewServer(rt)
 	defer srv.Close()
 
 	type result struct {
-		code int
+		code     int
+		contains []string
+		excludes []string
 	}
 	tt := []struct {
 		name     string
@@ -277,6 +516,79 @@ func TestListHandler(t *testing.T) {
 			method: http.MethodGet,
 			expected: result{
 				code: http.StatusOK,
+				contains: []string{
+					`<h2>counter</h2>`,
+					`<h2>gauge</h2>`,
+					`<h2>sketch</h2>`,
+					`<td class="value" data-value="1313.1313">1313.1313</td>`,
+					`<a href="/dashboard/gauge/Alloc">Alloc</a>`,
+					`data-refresh="10"`,
+				},
+			},
+		},
+		{
+			name:   "get metrics by selector",
+			path:   "/?refresh=0&match=" + url.QueryEscape(`Requests{host=~"a|c"}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusOK,
+				contains: []string{
+					`<h2>counter</h2>`,
+					`<td class="labels">{host=&#34;a&#34;,path=&#34;/update/&#34;}</td>`,
+					`href="/dashboard/counter/Requests?labels=%7Bhost%3D%22a%22%2Cpath%3D%22%2Fupdate%2F%22%7D"`,
+					`data-refresh="0"`,
+				},
+				excludes: []string{`<h2>gauge</h2>`, `host=&#34;b&#34;`},
+			},
+		},
+		{
+			name:   "invalid selector",
+			path:   "/?match=" + url.QueryEscape(`Requests{host=}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "invalid refresh",
+			path:   "/?refresh=-1",
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "metric page with sparkline",
+			path:   "/dashboard/gauge/Alloc",
+			method: http.MethodGet,
+			expected: result{
+				code:     http.StatusOK,
+				contains: []string{`<h1>Alloc</h1>`, `<polyline class="sparkline" points="0.0,59.0 150.0,30.0 300.0,1.0"/>`, `3 values from 1.5 to 3.5`},
+			},
+		},
+		{
+			name:   "labeled metric page without history",
+			path:   "/dashboard/counter/Requests?labels=" + url.QueryEscape(`{host="b"}`),
+			method: http.MethodGet,
+			expected: result{
+				code:     http.StatusOK,
+				contains: []string{`<td class="value">3</td>`, `History is not available`},
+			},
+		},
+		{
+			name:   "unknown metric page",
+			path:   "/dashboard/counter/Requests?labels=" + url.QueryEscape(`{host="c"}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "metric page with invalid labels",
+			path:   "/dashboard/counter/Requests?labels=" + url.QueryEscape(`{host=~"b"}`),
+			method: http.MethodGet,
+			expected: result{
+				code: http.StatusBadRequest,
 			},
 		},
 	}
@@ -295,6 +607,23 @@ func TestListHandler(t *testing.T) {
 			defer resp.Body.Close()
 
 			assert.Equal(t, tc.expected.code, resp.StatusCode, "Код ответа не совпадает с ожидаемым")
+
+			if tc.expected.code != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "text/html; charset=UTF-8", resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			for _, s := range tc.expected.contains {
+				assert.Contains(t, string(body), s)
+			}
+
+			for _, s := range tc.expected.excludes {
+				assert.NotContains(t, string(body), s)
+			}
 		})
 	}
 }
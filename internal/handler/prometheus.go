@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+const (
+	// contentTypePrometheus - формат Prometheus text exposition.
+	contentTypePrometheus = "text/plain; version=0.0.4; charset=utf-8"
+	// contentTypeOpenMetrics - формат OpenMetrics text.
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	// mediaTypeOpenMetrics - тип содержимого OpenMetrics в заголовке Accept.
+	mediaTypeOpenMetrics = "application/openmetrics-text"
+	// counterSuffix - обязательный в OpenMetrics суффикс значений счётчиков.
+	counterSuffix = "_total"
+)
+
+var (
+	// sketchQuantiles - квантили эскизов sketch, выводимые в формате Prometheus.
+	sketchQuantiles = []float64{0.5, 0.9, 0.99}
+)
+
+// Prometheus - отдаёт метрики в формате Prometheus text exposition
+// или OpenMetrics, если клиент запросил его в заголовке Accept.
+// Параметры match[] ограничивают вывод метриками, удовлетворяющими
+// хотя бы одному из условий отбора.
+func (h metricHandlers) Prometheus(w http.ResponseWriter, r *http.Request) {
+	openMetrics := acceptOpenMetrics(r.Header.Get("Accept"))
+
+	records, err := h.selectRecords(r.URL.Query()["match[]"])
+	if err != nil {
+		responseWithError(w, http.StatusBadRequest, err, h.logger)
+		return
+	}
+
+	// серии одного семейства выводятся подряд после одного комментария TYPE.
+	sort.Slice(records, func(i, j int) bool {
+		ni, nj := sanitizeMetricName(records[i].GetName()), sanitizeMetricName(records[j].GetName())
+		if ni != nj {
+			return ni < nj
+		}
+
+		return records[i].GetLabels().String() < records[j].GetLabels().String()
+	})
+
+	buf := bytes.Buffer{}
+	families := make(map[string]string, len(records))
+	series := make(map[string]struct{}, len(records))
+
+	for _, record := range records {
+		value := record.GetValue()
+		if value == nil {
+			continue
+		}
+
+		name := sanitizeMetricName(record.GetName())
+		sample := name
+
+		if value.IsCounter() && openMetrics {
+			name = strings.TrimSuffix(name, counterSuffix)
+			sample = name + counterSuffix
+		}
+
+		labels := record.GetLabels().String()
+
+		if kind, ok := families[name]; ok && kind != value.Kind() {
+			h.logger.Warn("metric family has different kinds",
+				zap.String("name", record.GetName()), zap.String("family", name))
+			continue
+		}
+
+		if _, ok := series[name+labels]; ok {
+			h.logger.Warn("duplicate metric name after sanitizing",
+				zap.String("name", record.GetName()), zap.String("sanitized", name))
+			continue
+		}
+
+		series[name+labels] = struct{}{}
+
+		if _, ok := families[name]; !ok {
+			families[name] = value.Kind()
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", name, familyType(value))
+		}
+
+		writeSamples(&buf, sample, record.GetLabels(), value)
+	}
+
+	contentType := contentTypePrometheus
+	if openMetrics {
+		contentType = contentTypeOpenMetrics
+		buf.WriteString("# EOF\n")
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+
+	responseWithCode(w, http.StatusOK, h.logger)
+}
+
+// selectRecords - возвращает метрики, удовлетворяющие хотя бы одному
+// из условий отбора selectors, или все метрики, если условия не заданы.
+func (h metricHandlers) selectRecords(selectors []string) ([]storage.Record, error) {
+	if len(selectors) == 0 {
+		return h.service.GetAll(), nil
+	}
+
+	records := make([]storage.Record, 0)
+	seen := make(map[string]struct{})
+
+	for _, selector := range selectors {
+		matchers, err := metric.ParseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range h.service.Select(matchers) {
+			if _, ok := seen[record.Key()]; ok {
+				continue
+			}
+
+			seen[record.Key()] = struct{}{}
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// acceptOpenMetrics - проверяет, готов ли клиент принять ответ в формате OpenMetrics.
+func acceptOpenMetrics(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != mediaTypeOpenMetrics {
+			continue
+		}
+
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+				continue
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// sanitizeMetricName - приводит имя метрики к виду [a-zA-Z_:][a-zA-Z0-9_:]*,
+// заменяя недопустимые символы на '_'.
+func sanitizeMetricName(name string) string {
+	b := strings.Builder{}
+	b.Grow(len(name) + 1)
+
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
+
+// writeSamples - выводит значения метрики: одно значение для counter и gauge,
+// корзины, сумму и количество наблюдений для histogram, квантили, сумму
+// и количество наблюдений для summary и sketch.
+func writeSamples(buf *bytes.Buffer, name string, labels metric.Labels, value metric.Metric) {
+	switch v := value.(type) {
+	case metric.Histogram:
+		for i, count := range v.Cumulative() {
+			le := "+Inf"
+			if i < len(v.Bounds) {
+				le = strconv.FormatFloat(v.Bounds[i], 'g', -1, 64)
+			}
+
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", name, withLabel(labels, "le", le), count)
+		}
+
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(v.Sum, 'g', -1, 64))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, labels, v.Count)
+
+	case metric.Summary:
+		for _, q := range v.Quantiles {
+			quantile := strconv.FormatFloat(q.Quantile, 'g', -1, 64)
+			fmt.Fprintf(buf, "%s%s %s\n", name, withLabel(labels, "quantile", quantile), strconv.FormatFloat(q.Value, 'g', -1, 64))
+		}
+
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(v.Sum, 'g', -1, 64))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, labels, v.Count)
+
+	case metric.Sketch:
+		for _, q := range sketchQuantiles {
+			value, err := v.Quantile(q)
+			if err != nil {
+				break
+			}
+
+			quantile := strconv.FormatFloat(q, 'g', -1, 64)
+			fmt.Fprintf(buf, "%s%s %s\n", name, withLabel(labels, "quantile", quantile), strconv.FormatFloat(value, 'g', -1, 64))
+		}
+
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(v.Sum, 'g', -1, 64))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, labels, v.Count)
+
+	default:
+		fmt.Fprintf(buf, "%s%s %s\n", name, labels, formatSampleValue(value))
+	}
+}
+
+// familyType - возвращает тип семейства метрик в формате Prometheus.
+// Эскиз sketch выводится как summary с оценками квантилей sketchQuantiles,
+// множество set - как gauge с оценкой количества уникальных элементов.
+func familyType(value metric.Metric) string {
+	switch value.Kind() {
+	case string(metric.KindSketch):
+		return string(metric.KindSummary)
+	case string(metric.KindSet):
+		return string(metric.KindGauge)
+	}
+
+	return value.Kind()
+}
+
+// withLabel - возвращает метки labels с добавленной меткой name.
+func withLabel(labels metric.Labels, name, value string) metric.Labels {
+	result := make(metric.Labels, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	result[name] = value
+
+	return result
+}
+
+func formatSampleValue(value metric.Metric) string {
+	switch v := value.(type) {
+	case metric.Counter:
+		return strconv.FormatInt(int64(v), 10)
+	case metric.Gauge:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	}
+
+	return value.String()
+}
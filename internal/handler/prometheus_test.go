@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	type result struct {
+		contentType string
+		body        string
+	}
+	tt := []struct {
+		name     string
+		accept   string
+		query    string
+		expected result
+	}{
+		{
+			name:   "prometheus text format",
+			accept: "",
+			expected: result{
+				contentType: contentTypePrometheus,
+				body: "# TYPE Alloc gauge\nAlloc 12.3456\n" +
+					"# TYPE Duration summary\n" +
+					"Duration{host=\"a\",quantile=\"0.5\"} 0.2\nDuration{host=\"a\",quantile=\"0.99\"} 0.9\n" +
+					"Duration_sum{host=\"a\"} 3\nDuration_count{host=\"a\"} 10\n" +
+					"# TYPE Latency histogram\n" +
+					"Latency_bucket{le=\"0.1\"} 1\nLatency_bucket{le=\"1\"} 1\nLatency_bucket{le=\"+Inf\"} 3\n" +
+					"Latency_sum 7.05\nLatency_count 3\n" +
+					"# TYPE PollCount counter\nPollCount 123\n" +
+					"# TYPE Random gauge\nRandom 1313.1313\n" +
+					"# TYPE Requests counter\n" +
+					"Requests{host=\"a\",path=\"/update/\"} 7\nRequests{host=\"b\"} 3\n" +
+					"# TYPE Size summary\n" +
+					"Size{quantile=\"0.5\"} 2.691188720352608\nSize{quantile=\"0.9\"} 2.691188720352608\nSize{quantile=\"0.99\"} 2.691188720352608\n" +
+					"Size_sum 6.4\nSize_count 3\n" +
+					"# TYPE Users gauge\nUsers 2\n",
+			},
+		},
+		{
+			name:   "openmetrics format",
+			accept: "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5",
+			expected: result{
+				contentType: contentTypeOpenMetrics,
+				body: "# TYPE Alloc gauge\nAlloc 12.3456\n" +
+					"# TYPE Duration summary\n" +
+					"Duration{host=\"a\",quantile=\"0.5\"} 0.2\nDuration{host=\"a\",quantile=\"0.99\"} 0.9\n" +
+					"Duration_sum{host=\"a\"} 3\nDuration_count{host=\"a\"} 10\n" +
+					"# TYPE Latency histogram\n" +
+					"Latency_bucket{le=\"0.1\"} 1\nLatency_bucket{le=\"1\"} 1\nLatency_bucket{le=\"+Inf\"} 3\n" +
+					"Latency_sum 7.05\nLatency_count 3\n" +
+					"# TYPE PollCount counter\nPollCount_total 123\n" +
+					"# TYPE Random gauge\nRandom 1313.1313\n" +
+					"# TYPE Requests counter\n" +
+					"Requests_total{host=\"a\",path=\"/update/\"} 7\nRequests_total{host=\"b\"} 3\n" +
+					"# TYPE Size summary\n" +
+					"Size{quantile=\"0.5\"} 2.691188720352608\nSize{quantile=\"0.9\"} 2.691188720352608\nSize{quantile=\"0.99\"} 2.691188720352608\n" +
+					"Size_sum 6.4\nSize_count 3\n" +
+					"# TYPE Users gauge\nUsers 2\n" +
+					"# EOF\n",
+			},
+		},
+		{
+			name:   "openmetrics refused",
+			accept: "application/openmetrics-text;q=0,text/plain",
+			expected: result{
+				contentType: contentTypePrometheus,
+				body: "# TYPE Alloc gauge\nAlloc 12.3456\n" +
+					"# TYPE Duration summary\n" +
+					"Duration{host=\"a\",quantile=\"0.5\"} 0.2\nDuration{host=\"a\",quantile=\"0.99\"} 0.9\n" +
+					"Duration_sum{host=\"a\"} 3\nDuration_count{host=\"a\"} 10\n" +
+					"# TYPE Latency histogram\n" +
+					"Latency_bucket{le=\"0.1\"} 1\nLatency_bucket{le=\"1\"} 1\nLatency_bucket{le=\"+Inf\"} 3\n" +
+					"Latency_sum 7.05\nLatency_count 3\n" +
+					"# TYPE PollCount counter\nPollCount 123\n" +
+					"# TYPE Random gauge\nRandom 1313.1313\n" +
+					"# TYPE Requests counter\n" +
+					"Requests{host=\"a\",path=\"/update/\"} 7\nRequests{host=\"b\"} 3\n" +
+					"# TYPE Size summary\n" +
+					"Size{quantile=\"0.5\"} 2.691188720352608\nSize{quantile=\"0.9\"} 2.691188720352608\nSize{quantile=\"0.99\"} 2.691188720352608\n" +
+					"Size_sum 6.4\nSize_count 3\n" +
+					"# TYPE Users gauge\nUsers 2\n",
+			},
+		},
+		{
+			name:  "match selectors",
+			query: "?match[]=" + url.QueryEscape(`Requests{host="b"}`) + "&match[]=Alloc&match[]=" + url.QueryEscape(`{host="b"}`),
+			expected: result{
+				contentType: contentTypePrometheus,
+				body: "# TYPE Alloc gauge\nAlloc 12.3456\n" +
+					"# TYPE Requests counter\nRequests{host=\"b\"} 3\n",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/metrics"+tc.query, nil)
+			require.NoError(t, err)
+
+			req.Header.Set("Accept", tc.accept)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, tc.expected.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tc.expected.body, string(body))
+		})
+	}
+}
+
+func TestPrometheusHandlerInvalidSelector(t *testing.T) {
+	rt := NewRouter(mockService{}, zap.NewNop(), "", nil)
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics?match[]=" + url.QueryEscape(`{host~"a"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test_sanitizeMetricName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "valid name", in: "HeapAlloc", want: "HeapAlloc"},
+		{name: "colon and underscore", in: "http:requests_total", want: "http:requests_total"},
+		{name: "invalid characters", in: "cpu.usage-percent", want: "cpu_usage_percent"},
+		{name: "leading digit", in: "1minute", want: "_1minute"},
+		{name: "unicode", in: "память", want: "______"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeMetricName(tt.in))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"net/http"
 
@@ -8,29 +9,76 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/encoder"
+	"github.com/a-x-a/go-metric/internal/encryptor"
 	"github.com/a-x-a/go-metric/internal/logger"
+	"github.com/a-x-a/go-metric/internal/signer"
 )
 
-func NewRouter(s metricService, log *zap.Logger) http.Handler {
+// RouterOption - дополнительная настройка маршрутизатора.
+type RouterOption func(*routerOptions)
+
+// routerOptions - дополнительные настройки маршрутизатора.
+type routerOptions struct {
+	webSocket WebSocketConfig
+}
+
+// WithWebSocketConfig - задаёт ограничения соединений WebSocket /ws.
+func WithWebSocketConfig(cfg WebSocketConfig) RouterOption {
+	return func(o *routerOptions) {
+		o.webSocket = cfg
+	}
+}
+
+// NewRouter - создаёт маршрутизатор сервера сбора метрик.
+// Если задан ключ key, то запросы и ответы подписываются HMAC-SHA256.
+// Если задан закрытый ключ privateKey, то зашифрованные запросы расшифровываются.
+func NewRouter(s metricService, log *zap.Logger, key string, privateKey *rsa.PrivateKey, opts ...RouterOption) http.Handler {
+	options := routerOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	metricHendlers := newMetricHandlers(s, log)
+	wsHendler := newWSHandler(s, key, options.webSocket, log)
 	// mw := middlewarewithlogger.New(log)
 
 	r := chi.NewRouter()
 
 	r.Use(logger.LoggerMiddleware(log))
-	r.Use(encoder.DecompressMiddleware(log))
-	r.Use(encoder.CompressMiddleware(log))
-	// r.Use(mw.Logger)
-	// r.Use(mw.Decompress)
-	// r.Use(mw.Compress)
 
-	r.Get("/", metricHendlers.List)
+	// поток событий передаётся без сжатия и подписи:
+	// они требуют буферизации ответа целиком.
+	r.Get("/stream", metricHendlers.Stream)
+	// сообщения WebSocket подписываются по отдельности.
+	r.Get("/ws", wsHendler.ServeHTTP)
+
+	r.Group(func(r chi.Router) {
+		r.Use(encryptor.DecryptMiddleware(privateKey, log))
+		r.Use(encoder.DecompressMiddleware(log))
+		r.Use(encoder.CompressMiddleware(log))
+		r.Use(signer.SignMiddleware(key, log))
+		// r.Use(mw.Logger)
+		// r.Use(mw.Decompress)
+		// r.Use(mw.Compress)
+
+		r.Get("/", metricHendlers.List)
+		r.Get("/metrics", metricHendlers.Prometheus)
+		r.Get("/dashboard/{kind}/{name}", metricHendlers.Metric)
+
+		r.Post("/value/", metricHendlers.GetJSON)
+		r.Get("/value/{kind}/{name}", metricHendlers.Get)
+		r.Delete("/value/", metricHendlers.DeleteJSON)
+		r.Delete("/value/{kind}/{name}", metricHendlers.Delete)
+
+		r.Get("/history/{kind}/{name}", metricHendlers.History)
+		r.Get("/agents", metricHendlers.Agents)
+		r.Get("/api/metrics", metricHendlers.Query)
 
-	r.Post("/value/", metricHendlers.GetJSON)
-	r.Get("/value/{kind}/{name}", metricHendlers.Get)
+		r.Post("/update/", metricHendlers.UpdateJSON)
+		r.Post("/update/{kind}/{name}/{value}", metricHendlers.Update)
 
-	r.Post("/update/", metricHendlers.UpdateJSON)
-	r.Post("/update/{kind}/{name}/{value}", metricHendlers.Update)
+		r.Post("/updates/", metricHendlers.UpdatesJSON)
+	})
 
 	return r
 }
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+)
+
+const (
+	// streamKeepAlive - интервал отправки комментариев, поддерживающих соединение
+	// потока событий при отсутствии обновлений.
+	streamKeepAlive = 15 * time.Second
+)
+
+// Stream - отправляет обновления метрик, принятые сервисом, в формате
+// server-sent events: событие update с метрикой в формате JSON.
+// Параметры match, regexp и kind отбирают метрики так же, как в Query.
+// Если клиент не успевает читать события, то лишние обновления отбрасываются,
+// а клиент получает событие dropped с количеством отброшенных обновлений.
+func (h metricHandlers) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		responseWithError(w, http.StatusInternalServerError, errors.New("streaming is not supported"), h.logger)
+		return
+	}
+
+	params := r.URL.Query()
+
+	sub, err := h.service.Subscribe(metricservice.Query{
+		Match:  params.Get("match"),
+		Regexp: params.Get("regexp"),
+		Kind:   params.Get("kind"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, metricservice.ErrInvalidQuery), errors.Is(err, metric.ErrorInvalidMetricKind):
+			responseWithError(w, http.StatusBadRequest, err, h.logger)
+		default:
+			responseWithError(w, http.StatusInternalServerError, err, h.logger)
+		}
+
+		return
+	}
+
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// отключает буферизацию ответа в обратном прокси nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+		case record, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+
+			if dropped := sub.Dropped(); dropped > 0 {
+				if _, err := fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped); err != nil {
+					return
+				}
+			}
+
+			data, err := json.Marshal(recordToRequestMetric(record))
+			if err != nil {
+				h.logger.Error("encode metric update", zap.String("key", record.Key()), zap.Error(err))
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: update\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+func TestStreamHandler(t *testing.T) {
+	require := require.New(t)
+
+	s := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	srv := httptest.NewServer(NewRouter(s, zap.NewNop(), "secret", nil))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream?match=Heap*&kind=gauge", nil)
+	require.NoError(err)
+	// поток событий не сжимается, даже если клиент поддерживает сжатие.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	require.Empty(resp.Header.Get("Content-Encoding"))
+
+	_, err = s.PushCounter("HeapAlloc", 1)
+	require.NoError(err)
+	_, err = s.PushGauge("Alloc", 1)
+	require.NoError(err)
+	_, err = s.PushGauge("HeapAlloc", 2.5)
+	require.NoError(err)
+
+	reader := bufio.NewReader(resp.Body)
+	event := make([]string, 0, 2)
+
+	for len(event) < 2 {
+		line, err := reader.ReadString('\n')
+		require.NoError(err)
+
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			event = append(event, line)
+		}
+	}
+
+	require.Equal("event: update", event[0])
+	require.JSONEq(`{"id":"HeapAlloc","type":"gauge","value":2.5}`, strings.TrimPrefix(event[1], "data: "))
+}
+
+func TestStreamHandlerInvalidQuery(t *testing.T) {
+	for _, path := range []string{"/stream?kind=unknown", "/stream?regexp=(", "/stream?match=%5B"} {
+		resp := sendTestRequest(t, http.MethodGet, path, nil)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+	}
+}
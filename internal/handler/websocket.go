@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/signer"
+)
+
+type (
+	// WebSocketConfig - ограничения соединений WebSocket для приёма метрик.
+	WebSocketConfig struct {
+		// MaxFrameSize - максимальный размер сообщения в байтах,
+		// для сжатого сообщения - и до, и после распаковки.
+		MaxFrameSize int64
+		// IdleTimeout - время ожидания сообщения или ping от агента,
+		// после которого соединение закрывается.
+		IdleTimeout time.Duration
+	}
+
+	// wsHandler - принимает метрики от агентов через постоянное соединение WebSocket.
+	wsHandler struct {
+		service metricService
+		// key - ключ подписи сообщений, если пустой, то подпись не проверяется.
+		key      string
+		config   WebSocketConfig
+		upgrader websocket.Upgrader
+		logger   *zap.Logger
+	}
+)
+
+const (
+	// DefaultWebSocketMaxFrameSize - максимальный размер сообщения WebSocket по умолчанию.
+	DefaultWebSocketMaxFrameSize = 1 << 20
+	// DefaultWebSocketIdleTimeout - время ожидания сообщения WebSocket по умолчанию.
+	DefaultWebSocketIdleTimeout = time.Minute
+	// wsWriteTimeout - максимальное время отправки подтверждения агенту.
+	wsWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrFrameTooLarge - размер распакованного сообщения превышает MaxFrameSize.
+	ErrFrameTooLarge = errors.New("frame too large")
+)
+
+func newWSHandler(s metricService, key string, cfg WebSocketConfig, logger *zap.Logger) wsHandler {
+	if cfg.MaxFrameSize <= 0 {
+		cfg.MaxFrameSize = DefaultWebSocketMaxFrameSize
+	}
+
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultWebSocketIdleTimeout
+	}
+
+	return wsHandler{
+		service: s,
+		key:     key,
+		config:  cfg,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP - принимает сообщения с метриками через соединение WebSocket
+// и отвечает на каждое сообщение подтверждением adapter.Ack с его номером.
+// Текстовое сообщение - adapter.Frame в формате JSON, двоичное - тот же
+// adapter.Frame, сжатый gzip. Поле metrics сообщения содержит метрики
+// в формате /updates/ и подписывается HMAC-SHA256, если задан ключ подписи.
+// Сообщение обрабатывается так же, как запрос к /updates/: код подтверждения
+// 400 означает ошибку в сообщении, 500 - ошибку сохранения метрик.
+func (h wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// клиенту уже отправлен ответ с ошибкой.
+		h.logger.Info("websocket upgrade", zap.Error(err))
+		return
+	}
+
+	defer conn.Close()
+
+	conn.SetReadLimit(h.config.MaxFrameSize)
+
+	extendDeadline := func() {
+		conn.SetReadDeadline(time.Now().Add(h.config.IdleTimeout))
+	}
+
+	extendDeadline()
+
+	conn.SetPingHandler(func(data string) error {
+		extendDeadline()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(wsWriteTimeout))
+	})
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			h.closeWithError(conn, err)
+			return
+		}
+
+		extendDeadline()
+
+		ack := h.handleFrame(messageType, data)
+		if ack.Code != http.StatusOK {
+			h.logger.Info("websocket frame rejected",
+				zap.Uint64("seq", ack.Seq), zap.Int("code", ack.Code), zap.String("error", ack.Error))
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+		if err := conn.WriteJSON(ack); err != nil {
+			h.logger.Info("websocket write ack", zap.Error(err))
+			return
+		}
+	}
+}
+
+// handleFrame - сохраняет метрики сообщения и возвращает подтверждение.
+func (h wsHandler) handleFrame(messageType int, data []byte) adapter.Ack {
+	if messageType == websocket.BinaryMessage {
+		var err error
+		if data, err = h.decompress(data); err != nil {
+			return adapter.Ack{Code: http.StatusBadRequest, Error: err.Error()}
+		}
+	}
+
+	var frame adapter.Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return adapter.Ack{Code: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	ack := adapter.Ack{Seq: frame.Seq, Code: http.StatusOK}
+
+	if len(h.key) > 0 {
+		if err := signer.Verify(frame.Metrics, h.key, frame.Hash); err != nil {
+			ack.Code, ack.Error = http.StatusBadRequest, err.Error()
+			return ack
+		}
+	}
+
+	metrics := []adapter.RequestMetric{}
+	if err := json.Unmarshal(frame.Metrics, &metrics); err != nil {
+		ack.Code, ack.Error = http.StatusBadRequest, err.Error()
+		return ack
+	}
+
+	records, err := requestMetricsToRecords(metrics)
+	if err != nil {
+		ack.Code, ack.Error = http.StatusBadRequest, err.Error()
+		return ack
+	}
+
+	if _, err := h.service.PushBatch(records); err != nil {
+		ack.Code, ack.Error = http.StatusInternalServerError, err.Error()
+	}
+
+	return ack
+}
+
+// decompress - распаковывает сжатое gzip сообщение не больше MaxFrameSize байт.
+func (h wsHandler) decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer zr.Close()
+
+	result, err := io.ReadAll(io.LimitReader(zr, h.config.MaxFrameSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(result)) > h.config.MaxFrameSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrFrameTooLarge, h.config.MaxFrameSize)
+	}
+
+	return result, nil
+}
+
+// closeWithError - завершает соединение после ошибки чтения сообщения.
+// При истечении времени ожидания агенту отправляется сообщение о закрытии соединения.
+func (h wsHandler) closeWithError(conn *websocket.Conn, err error) {
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		h.logger.Info("websocket idle timeout", zap.String("remote", conn.RemoteAddr().String()))
+
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout")
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+
+		return
+	}
+
+	if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		h.logger.Info("websocket read", zap.Error(err))
+	}
+}
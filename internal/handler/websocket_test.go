@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/service/metricservice"
+	"github.com/a-x-a/go-metric/internal/signer"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	const key = "secret"
+
+	require := require.New(t)
+
+	s := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	rt := NewRouter(s, zap.NewNop(), key, nil, WithWebSocketConfig(WebSocketConfig{
+		MaxFrameSize: 1024,
+		IdleTimeout:  200 * time.Millisecond,
+	}))
+
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	require.NoError(err)
+	defer conn.Close()
+
+	frame := func(seq uint64, metrics, hash string) []byte {
+		data, err := json.Marshal(adapter.Frame{Seq: seq, Hash: hash, Metrics: json.RawMessage(metrics)})
+		require.NoError(err)
+
+		return data
+	}
+
+	send := func(messageType int, data []byte) adapter.Ack {
+		require.NoError(conn.WriteMessage(messageType, data))
+
+		var ack adapter.Ack
+		require.NoError(conn.ReadJSON(&ack))
+
+		return ack
+	}
+
+	metrics := `[{"id":"PollCount","type":"counter","delta":2},{"id":"Alloc","type":"gauge","value":1.5}]`
+	require.Equal(adapter.Ack{Seq: 1, Code: http.StatusOK}, send(websocket.TextMessage, frame(1, metrics, signer.Hash([]byte(metrics), key))))
+
+	value, err := s.Get("PollCount", "counter")
+	require.NoError(err)
+	require.Equal("2", value)
+
+	// сжатое сообщение.
+	buf := bytes.Buffer{}
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(frame(2, metrics, signer.Hash([]byte(metrics), key)))
+	require.NoError(err)
+	require.NoError(zw.Close())
+	require.Equal(adapter.Ack{Seq: 2, Code: http.StatusOK}, send(websocket.BinaryMessage, buf.Bytes()))
+
+	value, err = s.Get("PollCount", "counter")
+	require.NoError(err)
+	require.Equal("4", value)
+
+	ack := send(websocket.TextMessage, frame(3, metrics, signer.Hash([]byte(metrics), "another")))
+	require.Equal(uint64(3), ack.Seq)
+	require.Equal(http.StatusBadRequest, ack.Code)
+
+	invalid := `[{"id":"PollCount","type":"unknown"}]`
+	ack = send(websocket.TextMessage, frame(4, invalid, signer.Hash([]byte(invalid), key)))
+	require.Equal(http.StatusBadRequest, ack.Code)
+	require.Contains(ack.Error, "PollCount")
+
+	ack = send(websocket.TextMessage, []byte("not a frame"))
+	require.Equal(http.StatusBadRequest, ack.Code)
+
+	// распакованное сообщение больше MaxFrameSize.
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	_, err = zw.Write(bytes.Repeat([]byte(" "), 2048))
+	require.NoError(err)
+	require.NoError(zw.Close())
+	ack = send(websocket.BinaryMessage, buf.Bytes())
+	require.Equal(http.StatusBadRequest, ack.Code)
+	require.Contains(ack.Error, ErrFrameTooLarge.Error())
+
+	// сообщение больше MaxFrameSize закрывает соединение.
+	require.NoError(conn.WriteMessage(websocket.TextMessage, bytes.Repeat([]byte(" "), 2048)))
+	_, _, err = conn.ReadMessage()
+	require.True(websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+}
+
+func TestWebSocketHandlerIdleTimeout(t *testing.T) {
+	require := require.New(t)
+
+	s := metricservice.New(storage.NewMemStorage(), zap.NewNop())
+	rt := NewRouter(s, zap.NewNop(), "", nil, WithWebSocketConfig(WebSocketConfig{IdleTimeout: 100 * time.Millisecond}))
+
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	require.NoError(err)
+	defer conn.Close()
+
+	start := time.Now()
+
+	_, _, err = conn.ReadMessage()
+	require.True(websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+	require.Contains(err.Error(), "idle timeout")
+	require.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+}
@@ -0,0 +1,103 @@
+package identity
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// fileName - имя файла с идентификатором агента в каталоге по умолчанию.
+	fileName = "agent-id"
+	// appDir - каталог приложения в каталоге пользовательских настроек.
+	appDir = "go-metric"
+)
+
+var (
+	// ErrInvalidUUID - файл идентификатора содержит не корректный UUID.
+	ErrInvalidUUID = errors.New("identity: invalid uuid")
+
+	uuidRe = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// DefaultPath - возвращает путь к файлу идентификатора агента по умолчанию
+// в каталоге пользовательских настроек или во временном каталоге, если он не определён.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, appDir, fileName)
+}
+
+// Resolve - возвращает идентификатор агента. Если id не задан, то идентификатор
+// составляется из имени хоста и UUID, сохранённого в файле path, чтобы агент
+// сохранял идентификатор после перезапуска.
+func Resolve(id, path string) (string, error) {
+	if len(id) > 0 {
+		return id, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	uuid, err := LoadOrCreate(path)
+	if err != nil {
+		return "", err
+	}
+
+	return hostname + "-" + uuid, nil
+}
+
+// LoadOrCreate - читает UUID из файла path. Если файла нет, то создаёт
+// новый UUID и сохраняет его в файл.
+func LoadOrCreate(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		uuid := strings.TrimSpace(string(data))
+		if !uuidRe.MatchString(uuid) {
+			return "", fmt.Errorf("%w in %s", ErrInvalidUUID, path)
+		}
+
+		return uuid, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	uuid, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, []byte(uuid+"\n"), 0600); err != nil {
+		return "", err
+	}
+
+	return uuid, nil
+}
+
+// NewUUID - создаёт случайный UUID версии 4.
+func NewUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
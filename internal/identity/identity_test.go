@@ -0,0 +1,50 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUUID(t *testing.T) {
+	a, err := NewUUID()
+	require.NoError(t, err)
+	require.Regexp(t, uuidRe, a)
+	require.Equal(t, byte('4'), a[14])
+
+	b, err := NewUUID()
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+}
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", fileName)
+
+	id, err := Resolve("agent-1", path)
+	require.NoError(t, err)
+	require.Equal(t, "agent-1", id)
+	require.NoFileExists(t, path)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	first, err := Resolve("", path)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(first, hostname+"-"))
+	require.FileExists(t, path)
+
+	second, err := Resolve("", path)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+}
+
+func TestLoadOrCreateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), fileName)
+	require.NoError(t, os.WriteFile(path, []byte("not-a-uuid"), 0600))
+
+	_, err := LoadOrCreate(path)
+	require.ErrorIs(t, err, ErrInvalidUUID)
+}
@@ -0,0 +1,113 @@
+package metric
+
+import (
+	"math/rand"
+	"sync"
+)
+
+type (
+	// NamedMetric - значение метрики с её именем и метками.
+	NamedMetric struct {
+		Name   string
+		Value  Metric
+		Labels Labels
+	}
+
+	// Collector - источник метрик агента.
+	Collector interface {
+		// Poll - обновляет значения метрик.
+		Poll()
+		// Collect - возвращает текущие значения метрик.
+		Collect() []NamedMetric
+	}
+
+	// Registry - реестр источников метрик агента.
+	// Метрики возвращаются в порядке регистрации источников.
+	Registry struct {
+		sync.Mutex
+		collectors []Collector
+	}
+
+	// pollCountCollector - счётчик, увеличивающийся на 1 при каждом обновлении метрик.
+	pollCountCollector struct {
+		count Counter
+	}
+
+	// randomValueCollector - обновляемое произвольное значение.
+	randomValueCollector struct {
+		value Gauge
+	}
+)
+
+// NewRegistry - создаёт реестр с источниками метрик collectors.
+func NewRegistry(collectors ...Collector) *Registry {
+	return &Registry{collectors: collectors}
+}
+
+// NewDefaultRegistry - создаёт реестр со стандартными источниками метрик агента:
+// метрики пакета runtime, RandomValue, PollCount и метрики системы из /proc.
+func NewDefaultRegistry() *Registry {
+	return NewRegistry(
+		NewRuntimeCollector(),
+		NewRandomValueCollector(),
+		NewPollCountCollector(),
+		NewHostCollector(ProcRoot),
+	)
+}
+
+// Register - добавляет источник метрик в реестр.
+func (r *Registry) Register(c Collector) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// Poll - обновляет значения метрик всех источников.
+func (r *Registry) Poll() {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, c := range r.collectors {
+		c.Poll()
+	}
+}
+
+// Collect - возвращает текущие значения метрик всех источников.
+func (r *Registry) Collect() []NamedMetric {
+	r.Lock()
+	defer r.Unlock()
+
+	metrics := make([]NamedMetric, 0)
+	for _, c := range r.collectors {
+		metrics = append(metrics, c.Collect()...)
+	}
+
+	return metrics
+}
+
+// NewPollCountCollector - создаёт источник метрики PollCount.
+func NewPollCountCollector() *pollCountCollector {
+	return &pollCountCollector{}
+}
+
+func (pc *pollCountCollector) Poll() {
+	pc.count++
+}
+
+func (pc *pollCountCollector) Collect() []NamedMetric {
+	return []NamedMetric{{Name: "PollCount", Value: pc.count}}
+}
+
+// NewRandomValueCollector - создаёт источник метрики RandomValue.
+func NewRandomValueCollector() *randomValueCollector {
+	return &randomValueCollector{}
+}
+
+func (rv *randomValueCollector) Poll() {
+	rv.value = Gauge(rand.Float64())
+}
+
+func (rv *randomValueCollector) Collect() []NamedMetric {
+	return []NamedMetric{{Name: "RandomValue", Value: rv.value}}
+}
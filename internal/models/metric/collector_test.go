@@ -0,0 +1,79 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collected(r *Registry) map[string]Metric {
+	values := make(map[string]Metric)
+	for _, m := range r.Collect() {
+		values[m.Name] = m.Value
+	}
+
+	return values
+}
+
+func TestRegistry_Poll(t *testing.T) {
+	registry := NewDefaultRegistry()
+	tests := []struct {
+		name  string
+		count Counter
+	}{
+		{
+			name:  "poll 1",
+			count: Counter(1),
+		},
+		{
+			name:  "poll 2",
+			count: Counter(2),
+		},
+		{
+			name:  "poll 3",
+			count: Counter(3),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry.Poll()
+			assert.Equal(t, tt.count, collected(registry)["PollCount"])
+		})
+	}
+}
+
+func TestRegistry_Collect(t *testing.T) {
+	registry := NewDefaultRegistry()
+	registry.Poll()
+
+	values := collected(registry)
+	for _, name := range runtimeGauges {
+		require.Contains(t, values, name)
+		require.True(t, values[name].IsGauge())
+	}
+
+	require.NotZero(t, values["Alloc"])
+	require.True(t, values["RandomValue"].IsGauge())
+	require.True(t, values["PollCount"].IsCounter())
+}
+
+type staticCollector struct {
+	metrics []NamedMetric
+}
+
+func (s staticCollector) Poll() {}
+
+func (s staticCollector) Collect() []NamedMetric {
+	return s.metrics
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry(NewPollCountCollector())
+	registry.Register(staticCollector{metrics: []NamedMetric{{Name: "Custom", Value: Gauge(1.5)}}})
+
+	require.Equal(t, []NamedMetric{
+		{Name: "PollCount", Value: Counter(0)},
+		{Name: "Custom", Value: Gauge(1.5)},
+	}, registry.Collect())
+}
@@ -0,0 +1,168 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Histogram - распределение наблюдений по корзинам с фиксированными границами.
+	Histogram struct {
+		// Bounds - верхние границы корзин в порядке возрастания без корзины +Inf.
+		Bounds []float64
+		// Buckets - количество наблюдений в каждой корзине, последняя корзина - +Inf.
+		// Наблюдение попадает в первую корзину, граница которой не меньше его значения.
+		Buckets []uint64
+		// Sum - сумма наблюдений.
+		Sum float64
+		// Count - количество наблюдений.
+		Count uint64
+	}
+)
+
+var (
+	// ErrorInvalidHistogram - не корректная гистограмма.
+	ErrorInvalidHistogram = errors.New("metrics: не корректная гистограмма")
+)
+
+// NewHistogram - создаёт пустую гистограмму с верхними границами корзин bounds.
+func NewHistogram(bounds ...float64) (Histogram, error) {
+	h := Histogram{
+		Bounds:  append([]float64(nil), bounds...),
+		Buckets: make([]uint64, len(bounds)+1),
+	}
+
+	if err := h.Validate(); err != nil {
+		return Histogram{}, err
+	}
+
+	return h, nil
+}
+
+// Observe - добавляет наблюдение v в гистограмму.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Buckets[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// Validate - проверяет гистограмму: границы корзин конечны и строго возрастают,
+// корзин на одну больше, чем границ, а количество наблюдений равно сумме корзин.
+func (h Histogram) Validate() error {
+	if len(h.Buckets) != len(h.Bounds)+1 {
+		return fmt.Errorf("%w: %d границ и %d корзин", ErrorInvalidHistogram, len(h.Bounds), len(h.Buckets))
+	}
+
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) || i > 0 && b <= h.Bounds[i-1] {
+			return fmt.Errorf("%w: границы корзин должны быть конечны и возрастать", ErrorInvalidHistogram)
+		}
+	}
+
+	var count uint64
+	for _, c := range h.Buckets {
+		count += c
+	}
+
+	if count != h.Count {
+		return fmt.Errorf("%w: количество наблюдений не равно сумме корзин", ErrorInvalidHistogram)
+	}
+
+	if math.IsNaN(h.Sum) || math.IsInf(h.Sum, 0) {
+		return fmt.Errorf("%w: сумма наблюдений должна быть конечна", ErrorInvalidHistogram)
+	}
+
+	return nil
+}
+
+// Merge - объединяет гистограмму с наблюдениями other. Если границы корзин
+// отличаются, то гистограмма заменяется other: набор корзин изменился,
+// и прежние наблюдения нельзя распределить по новым корзинам.
+func (h Histogram) Merge(other Histogram) Histogram {
+	if !equalBounds(h.Bounds, other.Bounds) || len(h.Buckets) != len(other.Buckets) {
+		return other.clone()
+	}
+
+	merged := h.clone()
+	for i, c := range other.Buckets {
+		merged.Buckets[i] += c
+	}
+
+	merged.Sum += other.Sum
+	merged.Count += other.Count
+
+	return merged
+}
+
+// Cumulative - возвращает количество наблюдений не больше границы каждой корзины,
+// последний элемент - общее количество наблюдений.
+func (h Histogram) Cumulative() []uint64 {
+	result := make([]uint64, len(h.Buckets))
+
+	var total uint64
+	for i, c := range h.Buckets {
+		total += c
+		result[i] = total
+	}
+
+	return result
+}
+
+func (h Histogram) Kind() string {
+	return string(KindHistogram)
+}
+
+// String - возвращает гистограмму в виде "count=3 sum=1.5 buckets=0.5:1,1:1,+Inf:1".
+func (h Histogram) String() string {
+	buckets := make([]string, 0, len(h.Buckets))
+	for i, c := range h.Buckets {
+		bound := "+Inf"
+		if i < len(h.Bounds) {
+			bound = formatFloat(h.Bounds[i])
+		}
+
+		buckets = append(buckets, bound+":"+strconv.FormatUint(c, 10))
+	}
+
+	return fmt.Sprintf("count=%d sum=%s buckets=%s", h.Count, formatFloat(h.Sum), strings.Join(buckets, ","))
+}
+
+func (h Histogram) IsCounter() bool {
+	return false
+}
+
+func (h Histogram) IsGauge() bool {
+	return false
+}
+
+func (h Histogram) clone() Histogram {
+	return Histogram{
+		Bounds:  append([]float64(nil), h.Bounds...),
+		Buckets: append([]uint64(nil), h.Buckets...),
+		Sum:     h.Sum,
+		Count:   h.Count,
+	}
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,98 @@
+package metric
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	h, err := NewHistogram(0.1, 0.5, 1)
+	require.NoError(t, err)
+
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(v)
+	}
+
+	require.NoError(t, h.Validate())
+	assert.Equal(t, []uint64{2, 1, 1, 1}, h.Buckets)
+	assert.Equal(t, []uint64{2, 3, 4, 5}, h.Cumulative())
+	assert.Equal(t, uint64(5), h.Count)
+	assert.InDelta(t, 3.15, h.Sum, 1e-9)
+	assert.Equal(t, "histogram", h.Kind())
+	assert.Equal(t, "count=5 sum=3.15 buckets=0.1:2,0.5:1,1:1,+Inf:1", h.String())
+}
+
+func TestHistogram_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		h       Histogram
+		wantErr bool
+	}{
+		{name: "valid", h: Histogram{Bounds: []float64{1, 2}, Buckets: []uint64{1, 0, 2}, Sum: 5, Count: 3}},
+		{name: "only +Inf bucket", h: Histogram{Buckets: []uint64{0}}},
+		{name: "missing +Inf bucket", h: Histogram{Bounds: []float64{1, 2}, Buckets: []uint64{1, 2}, Count: 3}, wantErr: true},
+		{name: "unsorted bounds", h: Histogram{Bounds: []float64{2, 1}, Buckets: []uint64{0, 0, 0}}, wantErr: true},
+		{name: "infinite bound", h: Histogram{Bounds: []float64{math.Inf(1)}, Buckets: []uint64{0, 0}}, wantErr: true},
+		{name: "wrong count", h: Histogram{Bounds: []float64{1}, Buckets: []uint64{1, 1}, Count: 3}, wantErr: true},
+		{name: "NaN sum", h: Histogram{Bounds: []float64{1}, Buckets: []uint64{0, 0}, Sum: math.NaN()}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.h.Validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrorInvalidHistogram)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a := Histogram{Bounds: []float64{1, 2}, Buckets: []uint64{1, 0, 2}, Sum: 5, Count: 3}
+	b := Histogram{Bounds: []float64{1, 2}, Buckets: []uint64{0, 1, 1}, Sum: 4.5, Count: 2}
+
+	merged := a.Merge(b)
+	assert.Equal(t, Histogram{Bounds: []float64{1, 2}, Buckets: []uint64{1, 1, 3}, Sum: 9.5, Count: 5}, merged)
+	// исходные гистограммы не изменяются.
+	assert.Equal(t, []uint64{1, 0, 2}, a.Buckets)
+
+	c := Histogram{Bounds: []float64{5}, Buckets: []uint64{1, 0}, Sum: 3, Count: 1}
+	assert.Equal(t, c, a.Merge(c))
+}
+
+func TestSummary(t *testing.T) {
+	a := Summary{Quantiles: []Quantile{{0.5, 0.2}, {0.99, 0.9}}, Sum: 3, Count: 10}
+	b := Summary{Quantiles: []Quantile{{0.5, 0.3}, {0.99, 1.1}}, Sum: 2, Count: 5}
+
+	require.NoError(t, a.Validate())
+	assert.Equal(t, "summary", a.Kind())
+	assert.Equal(t, "count=10 sum=3 quantiles=0.5:0.2,0.99:0.9", a.String())
+	assert.Equal(t, Summary{Quantiles: b.Quantiles, Sum: 5, Count: 15}, a.Merge(b))
+	assert.Equal(t, Summary{Quantiles: a.Quantiles, Sum: 5, Count: 15}, a.Merge(Summary{Sum: 2, Count: 5}))
+
+	invalid := []Summary{
+		{Quantiles: []Quantile{{1.5, 1}}},
+		{Quantiles: []Quantile{{0.9, 1}, {0.5, 1}}},
+		{Quantiles: []Quantile{{0.5, math.Inf(1)}}},
+		{Sum: math.NaN()},
+	}
+	for _, s := range invalid {
+		require.ErrorIs(t, s.Validate(), ErrorInvalidSummary)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	h := Histogram{Bounds: []float64{1}, Buckets: []uint64{1, 0}, Sum: 0.5, Count: 1}
+
+	assert.Equal(t, Counter(5), Merge(Counter(2), Counter(3)))
+	assert.Equal(t, Gauge(3), Merge(Gauge(2), Gauge(3)))
+	assert.Equal(t, Counter(3), Merge(Gauge(2), Counter(3)))
+	assert.Equal(t, h, Merge(nil, h))
+	assert.Equal(t, uint64(2), Merge(h, h).(Histogram).Count)
+	assert.Equal(t, Summary{Sum: 2, Count: 2}, Merge(Summary{Sum: 1, Count: 1}, Summary{Sum: 1, Count: 1}))
+}
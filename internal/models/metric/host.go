@@ -0,0 +1,362 @@
+package metric
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// hostCollector - метрики системы, на которой запущен агент,
+	// по данным файловой системы /proc.
+	// Пропускные способности сети и дисков вычисляются как разница
+	// показаний между двумя обновлениями, поэтому появляются со второго обновления.
+	hostCollector struct {
+		root    string
+		now     func() time.Time
+		metrics []NamedMetric
+		// показания предыдущего обновления.
+		cpu      []cpuTimes
+		counters ioCounters
+		polledAt time.Time
+	}
+
+	// cpuTimes - время работы процессора из /proc/stat.
+	cpuTimes struct {
+		idle  uint64
+		total uint64
+	}
+
+	// ioCounters - суммарные счётчики сетевых интерфейсов и дисков в байтах.
+	ioCounters struct {
+		netReceive  uint64
+		netTransmit uint64
+		diskRead    uint64
+		diskWrite   uint64
+	}
+
+	// diskCounters - прочитанные и записанные диском байты.
+	diskCounters struct {
+		read  uint64
+		write uint64
+	}
+)
+
+const (
+	// ProcRoot - путь к файловой системе /proc.
+	ProcRoot = "/proc"
+	// diskSectorSize - размер сектора в /proc/diskstats.
+	diskSectorSize = 512
+	// kilobyte - единица измерения объёмов в /proc/meminfo.
+	kilobyte = 1024
+)
+
+// NewHostCollector - создаёт источник метрик системы по данным файловой системы /proc,
+// смонтированной в root.
+func NewHostCollector(root string) *hostCollector {
+	return &hostCollector{root: root, now: time.Now}
+}
+
+// Poll - обновляет значения метрик системы.
+// Метрики из недоступных или повреждённых файлов пропускаются.
+func (hc *hostCollector) Poll() {
+	now := hc.now()
+	metrics := make([]NamedMetric, 0)
+
+	if cpu, err := readCPUTimes(hc.path("stat")); err == nil {
+		for i, times := range cpu {
+			prev := cpuTimes{}
+			if i < len(hc.cpu) {
+				prev = hc.cpu[i]
+			}
+
+			metrics = append(metrics, NamedMetric{
+				Name:  fmt.Sprintf("CPUutilization%d", i+1),
+				Value: cpuUtilization(prev, times),
+			})
+		}
+
+		hc.cpu = cpu
+	}
+
+	if memory, err := readMemInfo(hc.path("meminfo")); err == nil {
+		metrics = append(metrics,
+			NamedMetric{Name: "TotalMemory", Value: Gauge(memory["MemTotal"] * kilobyte)},
+			NamedMetric{Name: "FreeMemory", Value: Gauge(memory["MemFree"] * kilobyte)},
+		)
+	}
+
+	if load, err := readLoadAvg(hc.path("loadavg")); err == nil {
+		metrics = append(metrics,
+			NamedMetric{Name: "LoadAverage1", Value: load[0]},
+			NamedMetric{Name: "LoadAverage5", Value: load[1]},
+			NamedMetric{Name: "LoadAverage15", Value: load[2]},
+		)
+	}
+
+	counters := ioCounters{}
+	netErr := readNetDev(hc.path("net", "dev"), &counters)
+	diskErr := readDiskStats(hc.path("diskstats"), &counters)
+
+	if elapsed := now.Sub(hc.polledAt).Seconds(); !hc.polledAt.IsZero() && elapsed > 0 {
+		if netErr == nil {
+			metrics = append(metrics,
+				NamedMetric{Name: "NetworkReceiveRate", Value: rate(hc.counters.netReceive, counters.netReceive, elapsed)},
+				NamedMetric{Name: "NetworkTransmitRate", Value: rate(hc.counters.netTransmit, counters.netTransmit, elapsed)},
+			)
+		}
+
+		if diskErr == nil {
+			metrics = append(metrics,
+				NamedMetric{Name: "DiskReadRate", Value: rate(hc.counters.diskRead, counters.diskRead, elapsed)},
+				NamedMetric{Name: "DiskWriteRate", Value: rate(hc.counters.diskWrite, counters.diskWrite, elapsed)},
+			)
+		}
+	}
+
+	hc.counters = counters
+	hc.polledAt = now
+	hc.metrics = metrics
+}
+
+func (hc *hostCollector) Collect() []NamedMetric {
+	metrics := make([]NamedMetric, len(hc.metrics))
+	copy(metrics, hc.metrics)
+
+	return metrics
+}
+
+func (hc *hostCollector) path(elem ...string) string {
+	return filepath.Join(append([]string{hc.root}, elem...)...)
+}
+
+// cpuUtilization - загрузка процессора в процентах между двумя показаниями.
+func cpuUtilization(prev, cur cpuTimes) Gauge {
+	if cur.total <= prev.total || cur.idle < prev.idle {
+		return 0
+	}
+
+	total := float64(cur.total - prev.total)
+	idle := float64(cur.idle - prev.idle)
+
+	return Gauge(100 * (total - idle) / total)
+}
+
+// rate - скорость изменения счётчика в секунду.
+// Если счётчик сбросился, то скорость считается нулевой.
+func rate(prev, cur uint64, seconds float64) Gauge {
+	if cur < prev {
+		return 0
+	}
+
+	return Gauge(float64(cur-prev) / seconds)
+}
+
+// readCPUTimes - читает время работы каждого процессора из /proc/stat.
+func readCPUTimes(path string) ([]cpuTimes, error) {
+	cpu := make([]cpuTimes, 0)
+
+	err := scanLines(path, func(fields []string) error {
+		// строка "cpu" содержит суммарное время всех процессоров.
+		if !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			return nil
+		}
+
+		values, err := parseUints(fields[1:])
+		if err != nil || len(values) < 4 {
+			return fmt.Errorf("metric: invalid cpu line %q", fields[0])
+		}
+
+		times := cpuTimes{}
+		for _, v := range values {
+			times.total += v
+		}
+
+		// idle и iowait.
+		times.idle = values[3]
+		if len(values) > 4 {
+			times.idle += values[4]
+		}
+
+		cpu = append(cpu, times)
+
+		return nil
+	})
+
+	return cpu, err
+}
+
+// readMemInfo - читает объёмы памяти в килобайтах из /proc/meminfo.
+func readMemInfo(path string) (map[string]uint64, error) {
+	memory := make(map[string]uint64)
+
+	err := scanLines(path, func(fields []string) error {
+		if len(fields) < 2 {
+			return nil
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		memory[strings.TrimSuffix(fields[0], ":")] = value
+
+		return nil
+	})
+
+	return memory, err
+}
+
+// readLoadAvg - читает средние значения загрузки системы за 1, 5 и 15 минут из /proc/loadavg.
+func readLoadAvg(path string) ([3]Gauge, error) {
+	load := [3]Gauge{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return load, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < len(load) {
+		return load, fmt.Errorf("metric: invalid loadavg %q", data)
+	}
+
+	for i := range load {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return load, err
+		}
+
+		load[i] = Gauge(v)
+	}
+
+	return load, nil
+}
+
+// readNetDev - суммирует принятые и переданные байты всех сетевых интерфейсов,
+// кроме loopback, из /proc/net/dev.
+func readNetDev(path string, counters *ioCounters) error {
+	return scanLines(path, func(fields []string) error {
+		// заголовок таблицы не содержит имени интерфейса с двоеточием.
+		name, rest, ok := strings.Cut(strings.Join(fields, " "), ":")
+		if !ok || strings.Contains(name, "|") {
+			return nil
+		}
+
+		if strings.TrimSpace(name) == "lo" {
+			return nil
+		}
+
+		values, err := parseUints(strings.Fields(rest))
+		if err != nil || len(values) < 9 {
+			return fmt.Errorf("metric: invalid net device %q", name)
+		}
+
+		counters.netReceive += values[0]
+		counters.netTransmit += values[8]
+
+		return nil
+	})
+}
+
+// readDiskStats - суммирует прочитанные и записанные байты всех дисков из /proc/diskstats.
+// Разделы дисков и виртуальные устройства loop и ram не учитываются,
+// чтобы не считать одни и те же операции дважды.
+func readDiskStats(path string, counters *ioCounters) error {
+	disks := make(map[string]diskCounters)
+
+	err := scanLines(path, func(fields []string) error {
+		if len(fields) < 10 {
+			return fmt.Errorf("metric: invalid diskstats line %q", strings.Join(fields, " "))
+		}
+
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			return nil
+		}
+
+		values, err := parseUints([]string{fields[5], fields[9]})
+		if err != nil {
+			return err
+		}
+
+		disks[name] = diskCounters{read: values[0] * diskSectorSize, write: values[1] * diskSectorSize}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, d := range disks {
+		if isPartition(name, disks) {
+			continue
+		}
+
+		counters.diskRead += d.read
+		counters.diskWrite += d.write
+	}
+
+	return nil
+}
+
+// isPartition - проверяет, является ли устройство name разделом другого устройства,
+// например sda1 для sda или nvme0n1p1 для nvme0n1.
+func isPartition(name string, devices map[string]diskCounters) bool {
+	for device := range devices {
+		if device == name || !strings.HasPrefix(name, device) {
+			continue
+		}
+
+		suffix := strings.TrimPrefix(strings.TrimPrefix(name, device), "p")
+		if _, err := strconv.ParseUint(suffix, 10, 64); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanLines - вызывает fn для полей каждой непустой строки файла.
+func scanLines(path string, fn func(fields []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err := fn(fields); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseUints(fields []string) ([]uint64, error) {
+	values := make([]uint64, 0, len(fields))
+
+	for _, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,74 @@
+package metric
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func hostMetrics(hc *hostCollector) map[string]Gauge {
+	values := make(map[string]Gauge)
+	for _, m := range hc.Collect() {
+		values[m.Name] = m.Value.(Gauge)
+	}
+
+	return values
+}
+
+func TestHostCollector(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Unix(1700000000, 0)
+	hc := NewHostCollector(filepath.Join("testdata", "proc"))
+	hc.now = func() time.Time { return now }
+
+	hc.Poll()
+
+	values := hostMetrics(hc)
+	require.Equal(map[string]Gauge{
+		"CPUutilization1": 20,
+		"CPUutilization2": 30,
+		"TotalMemory":     8000000 * 1024,
+		"FreeMemory":      2000000 * 1024,
+		"LoadAverage1":    0.52,
+		"LoadAverage5":    0.41,
+		"LoadAverage15":   0.30,
+	}, values)
+
+	now = now.Add(10 * time.Second)
+	hc.root = filepath.Join("testdata", "proc_next")
+
+	hc.Poll()
+
+	values = hostMetrics(hc)
+	require.InDelta(200.0/3, float64(values["CPUutilization1"]), 1e-9)
+	require.InDelta(80, float64(values["CPUutilization2"]), 1e-9)
+	require.Equal(Gauge(1.5), values["LoadAverage1"])
+	// loopback не учитывается.
+	require.Equal(Gauge(32000), values["NetworkReceiveRate"])
+	require.Equal(Gauge(8000), values["NetworkTransmitRate"])
+	// разделы и loop устройства не учитываются.
+	require.Equal(Gauge(4000*512/10), values["DiskReadRate"])
+	require.Equal(Gauge(5000*512/10), values["DiskWriteRate"])
+}
+
+func TestHostCollectorMissingProc(t *testing.T) {
+	hc := NewHostCollector(filepath.Join("testdata", "missing"))
+
+	hc.Poll()
+	hc.Poll()
+
+	require.Empty(t, hc.Collect())
+}
+
+func Test_isPartition(t *testing.T) {
+	devices := map[string]diskCounters{"sda": {}, "sda1": {}, "nvme0n1": {}, "nvme0n1p2": {}, "sdb": {}}
+
+	require.True(t, isPartition("sda1", devices))
+	require.True(t, isPartition("nvme0n1p2", devices))
+	require.False(t, isPartition("sda", devices))
+	require.False(t, isPartition("nvme0n1", devices))
+	require.False(t, isPartition("sdb", devices))
+}
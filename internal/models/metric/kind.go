@@ -11,11 +11,24 @@ type (
 const (
 	// типы метрик.
 	KindGauge, KindCounter MetricKind = "gauge", "counter"
+	// типы метрик распределения значений.
+	KindHistogram, KindSummary MetricKind = "histogram", "summary"
+	// KindSketch - квантильный эскиз DDSketch.
+	KindSketch MetricKind = "sketch"
+	// KindSet - количество уникальных элементов HyperLogLog.
+	KindSet MetricKind = "set"
 )
 
 var (
 	// metricTypes - строковое представление допустимых типов метрик.
-	metricKinds = map[string]MetricKind{"gauge": KindGauge, "counter": KindCounter}
+	metricKinds = map[string]MetricKind{
+		"gauge":     KindGauge,
+		"counter":   KindCounter,
+		"histogram": KindHistogram,
+		"summary":   KindSummary,
+		"sketch":    KindSketch,
+		"set":       KindSet,
+	}
 	// ErrorInvalidMetricType - не корректный тип метрики.
 	ErrorInvalidMetricKind = errors.New("model: не корректный тип метрики")
 )
@@ -0,0 +1,275 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// Labels - набор меток метрики key=value.
+	Labels map[string]string
+
+	// MatchType - тип сравнения метки.
+	MatchType string
+
+	// Matcher - условие отбора метрик по значению метки.
+	Matcher struct {
+		Name  string
+		Type  MatchType
+		Value string
+		re    *regexp.Regexp
+	}
+)
+
+const (
+	// типы сравнения меток.
+	MatchEqual, MatchNotEqual, MatchRegexp, MatchNotRegexp MatchType = "=", "!=", "=~", "!~"
+
+	// NameLabel - псевдометка, содержащая имя метрики, для отбора метрик по имени.
+	NameLabel = "__name__"
+	// InstanceLabel - метка с идентификатором агента, отправившего метрику.
+	InstanceLabel = "instance"
+	// HostLabel - метка с именем хоста агента, отправившего метрику.
+	HostLabel = "host"
+	// MaxLabels - максимальное количество меток метрики.
+	MaxLabels = 16
+	// MaxLabelValueLength - максимальная длина значения метки в байтах.
+	MaxLabelValueLength = 256
+
+	// reservedLabelPrefix - префикс служебных меток.
+	reservedLabelPrefix = "__"
+)
+
+var (
+	// ErrorInvalidLabel - не корректная метка.
+	ErrorInvalidLabel = errors.New("metrics: не корректная метка")
+	// ErrorInvalidSelector - не корректное условие отбора метрик.
+	ErrorInvalidSelector = errors.New("metrics: не корректное условие отбора метрик")
+
+	labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Validate - проверяет набор меток: имя метки должно соответствовать
+// [a-zA-Z_][a-zA-Z0-9_]* и не начинаться с "__", значение должно быть
+// непустой строкой UTF-8 длиной не более MaxLabelValueLength байт,
+// а меток должно быть не более MaxLabels.
+func (l Labels) Validate() error {
+	if len(l) > MaxLabels {
+		return fmt.Errorf("%w: больше %d меток", ErrorInvalidLabel, MaxLabels)
+	}
+
+	for name, value := range l {
+		if !labelNameRe.MatchString(name) || strings.HasPrefix(name, reservedLabelPrefix) {
+			return fmt.Errorf("%w: имя %q", ErrorInvalidLabel, name)
+		}
+
+		if len(value) == 0 || len(value) > MaxLabelValueLength || !utf8.ValidString(value) {
+			return fmt.Errorf("%w: значение метки %q", ErrorInvalidLabel, name)
+		}
+	}
+
+	return nil
+}
+
+// String - возвращает метки в каноническом виде {a="1",b="2"}
+// с упорядоченными по имени метками или пустую строку, если меток нет.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	b := strings.Builder{}
+	b.WriteByte('{')
+
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quoteLabelValue(l[name]))
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// Key - возвращает идентификатор метрики с именем name и метками l.
+// Для метрики без меток идентификатор совпадает с именем.
+func Key(name string, l Labels) string {
+	return name + l.String()
+}
+
+// NewMatcher - создаёт условие отбора метрик по метке name.
+func NewMatcher(name string, t MatchType, value string) (Matcher, error) {
+	m := Matcher{Name: name, Type: t, Value: value}
+
+	switch t {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return Matcher{}, fmt.Errorf("%w: %s", ErrorInvalidSelector, err)
+		}
+
+		m.re = re
+	default:
+		return Matcher{}, ErrorInvalidSelector
+	}
+
+	return m, nil
+}
+
+// Matches - проверяет значение метки. Отсутствующая метка имеет пустое значение.
+func (m Matcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+
+	return false
+}
+
+// MatchLabels - проверяет, удовлетворяет ли метрика с именем name и метками l
+// всем условиям отбора.
+func MatchLabels(matchers []Matcher, name string, l Labels) bool {
+	for _, m := range matchers {
+		value := l[m.Name]
+		if m.Name == NameLabel {
+			value = name
+		}
+
+		if !m.Matches(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseSelector - разбирает условие отбора метрик в формате Prometheus:
+// name{label="value",label!="value",label=~"regexp",label!~"regexp"},
+// где имя метрики и набор условий по меткам не обязательны.
+func ParseSelector(s string) ([]Matcher, error) {
+	s = strings.TrimSpace(s)
+	matchers := make([]Matcher, 0)
+
+	name := s
+	rest := ""
+	if i := strings.IndexByte(s, '{'); i >= 0 {
+		name, rest = strings.TrimSpace(s[:i]), s[i:]
+	}
+
+	if len(name) > 0 {
+		matchers = append(matchers, Matcher{Name: NameLabel, Type: MatchEqual, Value: name})
+	}
+
+	if len(rest) == 0 {
+		if len(matchers) == 0 {
+			return nil, ErrorInvalidSelector
+		}
+
+		return matchers, nil
+	}
+
+	if !strings.HasSuffix(rest, "}") {
+		return nil, ErrorInvalidSelector
+	}
+
+	body := strings.TrimSpace(rest[1 : len(rest)-1])
+	for len(body) > 0 {
+		m, tail, err := parseMatcher(body)
+		if err != nil {
+			return nil, err
+		}
+
+		matchers = append(matchers, m)
+
+		body = strings.TrimSpace(tail)
+		if len(body) == 0 {
+			break
+		}
+
+		if body[0] != ',' {
+			return nil, ErrorInvalidSelector
+		}
+
+		body = strings.TrimSpace(body[1:])
+	}
+
+	return matchers, nil
+}
+
+// parseMatcher - разбирает первое условие отбора строки s и возвращает остаток строки.
+func parseMatcher(s string) (Matcher, string, error) {
+	i := 0
+	for i < len(s) && (s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || i > 0 && s[i] >= '0' && s[i] <= '9') {
+		i++
+	}
+
+	name := s[:i]
+	s = strings.TrimSpace(s[i:])
+
+	if len(name) == 0 {
+		return Matcher{}, "", ErrorInvalidSelector
+	}
+
+	var t MatchType
+	for _, op := range []MatchType{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+		if strings.HasPrefix(s, string(op)) {
+			t = op
+			break
+		}
+	}
+
+	if len(t) == 0 {
+		return Matcher{}, "", ErrorInvalidSelector
+	}
+
+	s = strings.TrimSpace(s[len(t):])
+
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil || quoted[0] != '"' {
+		return Matcher{}, "", ErrorInvalidSelector
+	}
+
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return Matcher{}, "", ErrorInvalidSelector
+	}
+
+	m, err := NewMatcher(name, t, value)
+	if err != nil {
+		return Matcher{}, "", err
+	}
+
+	return m, s[len(quoted):], nil
+}
+
+// quoteLabelValue - заключает значение метки в кавычки, экранируя
+// обратную косую черту, кавычку и перевод строки.
+func quoteLabelValue(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	return `"` + r.Replace(value) + `"`
+}
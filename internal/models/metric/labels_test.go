@@ -0,0 +1,88 @@
+package metric
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabels_Validate(t *testing.T) {
+	tooMany := Labels{}
+	for i := 0; i <= MaxLabels; i++ {
+		tooMany[string(rune('a'+i))] = "v"
+	}
+
+	tests := []struct {
+		name    string
+		labels  Labels
+		wantErr bool
+	}{
+		{name: "nil", labels: nil},
+		{name: "valid", labels: Labels{"host": "a", "_region2": "eu-west"}},
+		{name: "name starts with digit", labels: Labels{"1host": "a"}, wantErr: true},
+		{name: "name with dash", labels: Labels{"host-name": "a"}, wantErr: true},
+		{name: "reserved name", labels: Labels{"__host": "a"}, wantErr: true},
+		{name: "empty value", labels: Labels{"host": ""}, wantErr: true},
+		{name: "long value", labels: Labels{"host": strings.Repeat("a", MaxLabelValueLength+1)}, wantErr: true},
+		{name: "invalid utf-8 value", labels: Labels{"host": "\xff"}, wantErr: true},
+		{name: "too many labels", labels: tooMany, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.labels.Validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrorInvalidLabel)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "Alloc", Key("Alloc", nil))
+	assert.Equal(t, `Alloc{host="a",region="eu"}`, Key("Alloc", Labels{"region": "eu", "host": "a"}))
+	assert.Equal(t, `Alloc{path="C:\\tmp \"x\"\n"}`, Key("Alloc", Labels{"path": "C:\\tmp \"x\"\n"}))
+}
+
+func TestParseSelector(t *testing.T) {
+	labels := Labels{"host": "web-1", "region": "eu"}
+
+	tests := []struct {
+		name     string
+		selector string
+		match    bool
+		wantErr  bool
+	}{
+		{name: "name only", selector: "Alloc", match: true},
+		{name: "other name", selector: "HeapAlloc", match: false},
+		{name: "equal", selector: `Alloc{host="web-1"}`, match: true},
+		{name: "labels only", selector: `{region="eu"}`, match: true},
+		{name: "not equal", selector: `{host!="web-1"}`, match: false},
+		{name: "regexp", selector: `Alloc{ host =~ "web-.*" , region="eu" }`, match: true},
+		{name: "regexp is anchored", selector: `{host=~"web"}`, match: false},
+		{name: "not regexp", selector: `{host!~"db-.*"}`, match: true},
+		{name: "missing label", selector: `{zone=""}`, match: true},
+		{name: "empty", selector: "", wantErr: true},
+		{name: "unclosed", selector: `Alloc{host="a"`, wantErr: true},
+		{name: "unquoted value", selector: `{host=a}`, wantErr: true},
+		{name: "unknown operator", selector: `{host~"a"}`, wantErr: true},
+		{name: "invalid regexp", selector: `{host=~"("}`, wantErr: true},
+		{name: "missing comma", selector: `{host="a" region="eu"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchers, err := ParseSelector(tt.selector)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrorInvalidSelector)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.match, MatchLabels(matchers, "Alloc", labels))
+		})
+	}
+}
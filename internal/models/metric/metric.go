@@ -2,7 +2,6 @@ package metric
 
 import (
 	"errors"
-	"math/rand"
 )
 
 type (
@@ -12,27 +11,46 @@ type (
 		IsCounter() bool
 		IsGauge() bool
 	}
-
-	Metrics struct {
-		// метрики пакета runtime.
-		Memory MemoryMetrics
-		// дополнительные метрики.
-		// PollCount - счётчик, увеличивающийся на 1 при каждом обновлении метрики из пакета runtime.
-		PollCount Counter
-		// RandomValue - обновляемое произвольное значение.
-		RandomValue Gauge
-	}
 )
 
 var (
 	// ErrorMetricNameIsNull - не указано имя метрики.
 	ErrorMetricNameIsNull = errors.New("metrics: ошибка cоздания метрики, не указано име метрики")
+	// ErrorMetricValueIsNull - не указано значение метрики.
+	ErrorMetricValueIsNull = errors.New("metrics: не указано значение метрики")
 	// ErrorMetricNotFound - метрика не найдена.
 	ErrorMetricNotFound = errors.New("metrics: метрика не найдена")
+	// ErrorAmbiguousMetric - метрике без меток соответствует несколько серий с разными метками.
+	ErrorAmbiguousMetric = errors.New("metrics: найдено несколько метрик с разными метками, укажите метки")
 )
 
-func (m *Metrics) Poll() {
-	m.PollCount += 1
-	m.RandomValue = Gauge(rand.Float64())
-	m.Memory.Poll()
+// Merge - возвращает значение метрики после получения нового значения update
+// для сохранённого значения stored. Значения counter суммируются, значения
+// histogram, summary, sketch и set объединяются, остальные значения и значения другого типа
+// заменяются новым значением.
+func Merge(stored, update Metric) Metric {
+	switch u := update.(type) {
+	case Counter:
+		if s, ok := stored.(Counter); ok {
+			return s + u
+		}
+	case Histogram:
+		if s, ok := stored.(Histogram); ok {
+			return s.Merge(u)
+		}
+	case Summary:
+		if s, ok := stored.(Summary); ok {
+			return s.Merge(u)
+		}
+	case Sketch:
+		if s, ok := stored.(Sketch); ok {
+			return s.Merge(u)
+		}
+	case Set:
+		if s, ok := stored.(Set); ok {
+			return s.Merge(u)
+		}
+	}
+
+	return update
 }
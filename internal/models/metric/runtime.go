@@ -1,72 +1,76 @@
 package metric
 
 import (
+	"reflect"
 	"runtime"
 )
 
 type (
-	MemoryMetrics struct {
-		// метрики пакета runtime.
-		Alloc         Gauge
-		BuckHashSys   Gauge
-		Frees         Gauge
-		GCCPUFraction Gauge
-		GCSys         Gauge
-		HeapAlloc     Gauge
-		HeapIdle      Gauge
-		HeapInuse     Gauge
-		HeapObjects   Gauge
-		HeapReleased  Gauge
-		HeapSys       Gauge
-		LastGC        Gauge
-		Lookups       Gauge
-		MCacheInuse   Gauge
-		MCacheSys     Gauge
-		MSpanInuse    Gauge
-		MSpanSys      Gauge
-		Mallocs       Gauge
-		NextGC        Gauge
-		NumForcedGC   Gauge
-		NumGC         Gauge
-		OtherSys      Gauge
-		PauseTotalNs  Gauge
-		StackInuse    Gauge
-		StackSys      Gauge
-		Sys           Gauge
-		TotalAlloc    Gauge
+	// runtimeCollector - метрики пакета runtime.
+	runtimeCollector struct {
+		stats runtime.MemStats
 	}
 )
 
+// runtimeGauges - имена полей runtime.MemStats, значения которых собираются как gauge.
+var runtimeGauges = []string{
+	"Alloc",
+	"BuckHashSys",
+	"Frees",
+	"GCCPUFraction",
+	"GCSys",
+	"HeapAlloc",
+	"HeapIdle",
+	"HeapInuse",
+	"HeapObjects",
+	"HeapReleased",
+	"HeapSys",
+	"LastGC",
+	"Lookups",
+	"MCacheInuse",
+	"MCacheSys",
+	"MSpanInuse",
+	"MSpanSys",
+	"Mallocs",
+	"NextGC",
+	"NumForcedGC",
+	"NumGC",
+	"OtherSys",
+	"PauseTotalNs",
+	"StackInuse",
+	"StackSys",
+	"Sys",
+	"TotalAlloc",
+}
+
+// NewRuntimeCollector - создаёт источник метрик пакета runtime.
+func NewRuntimeCollector() *runtimeCollector {
+	return &runtimeCollector{}
+}
+
 // Poll - обновляет значения показателей метрик.
-func (rm *MemoryMetrics) Poll() {
-	stats := runtime.MemStats{}
-	runtime.ReadMemStats(&stats)
+func (rc *runtimeCollector) Poll() {
+	runtime.ReadMemStats(&rc.stats)
+}
+
+func (rc *runtimeCollector) Collect() []NamedMetric {
+	stats := reflect.ValueOf(rc.stats)
+	metrics := make([]NamedMetric, 0, len(runtimeGauges))
+
+	for _, name := range runtimeGauges {
+		var value Gauge
+
+		switch field := stats.FieldByName(name); field.Kind() {
+		case reflect.Uint32, reflect.Uint64:
+			value = Gauge(field.Uint())
+		case reflect.Float64:
+			value = Gauge(field.Float())
+		default:
+			continue
+		}
+
+		metrics = append(metrics, NamedMetric{Name: name, Value: value})
+	}
 
-	rm.Alloc = Gauge(stats.Alloc)
-	rm.BuckHashSys = Gauge(stats.BuckHashSys)
-	rm.Frees = Gauge(stats.Frees)
-	rm.GCCPUFraction = Gauge(stats.GCCPUFraction)
-	rm.GCSys = Gauge(stats.GCSys)
-	rm.HeapAlloc = Gauge(stats.HeapAlloc)
-	rm.HeapIdle = Gauge(stats.HeapIdle)
-	rm.HeapInuse = Gauge(stats.HeapInuse)
-	rm.HeapObjects = Gauge(stats.HeapObjects)
-	rm.HeapReleased = Gauge(stats.HeapReleased)
-	rm.HeapSys = Gauge(stats.HeapSys)
-	rm.LastGC = Gauge(stats.LastGC)
-	rm.Lookups = Gauge(stats.Lookups)
-	rm.MCacheInuse = Gauge(stats.MCacheInuse)
-	rm.MCacheSys = Gauge(stats.MCacheSys)
-	rm.MSpanInuse = Gauge(stats.MSpanInuse)
-	rm.MSpanSys = Gauge(stats.MSpanSys)
-	rm.Mallocs = Gauge(stats.Mallocs)
-	rm.NextGC = Gauge(stats.NextGC)
-	rm.NumForcedGC = Gauge(stats.NumForcedGC)
-	rm.NumGC = Gauge(stats.NumGC)
-	rm.OtherSys = Gauge(stats.OtherSys)
-	rm.PauseTotalNs = Gauge(stats.PauseTotalNs)
-	rm.StackInuse = Gauge(stats.StackInuse)
-	rm.StackSys = Gauge(stats.StackSys)
-	rm.Sys = Gauge(stats.Sys)
-	rm.TotalAlloc = Gauge(stats.TotalAlloc)
+	return metrics
 }
@@ -0,0 +1,179 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+type (
+	// Set - количество уникальных элементов, оцениваемое HyperLogLog.
+	// Сами элементы не хранятся: каждый элемент хешируется, и в регистре,
+	// выбранном старшими битами хеша, сохраняется наибольшая позиция первой
+	// единицы в остальных битах. Наборы регистров одного размера объединяются
+	// поэлементным максимумом, поэтому оценка не зависит от того, сколько
+	// агентов и отправок участвовали в подсчёте.
+	Set struct {
+		// Registers - регистры HyperLogLog, количество регистров - степень двойки.
+		Registers []uint8
+	}
+)
+
+const (
+	// DefaultSetPrecision - количество бит хеша, выбирающих регистр, по умолчанию.
+	// Стандартная погрешность оценки 1.04/sqrt(2^14) - около 0.8%.
+	DefaultSetPrecision = 14
+	// MinSetPrecision, MaxSetPrecision - допустимое количество бит хеша, выбирающих регистр.
+	MinSetPrecision, MaxSetPrecision = 4, 16
+)
+
+var (
+	// ErrorInvalidSet - не корректные регистры HyperLogLog.
+	ErrorInvalidSet = errors.New("metrics: не корректные регистры множества")
+)
+
+// NewSet - создаёт пустое множество с 2^precision регистрами.
+func NewSet(precision int) (Set, error) {
+	if precision < MinSetPrecision || precision > MaxSetPrecision {
+		return Set{}, fmt.Errorf("%w: точность должна лежать в [%d, %d]", ErrorInvalidSet, MinSetPrecision, MaxSetPrecision)
+	}
+
+	return Set{Registers: make([]uint8, 1<<precision)}, nil
+}
+
+// Add - добавляет элемент member в множество. В множество без регистров
+// предварительно добавляются 2^DefaultSetPrecision регистров.
+func (s *Set) Add(member string) {
+	if len(s.Registers) == 0 {
+		s.Registers = make([]uint8, 1<<DefaultSetPrecision)
+	}
+
+	precision := s.precision()
+
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	x := mix64(h.Sum64())
+
+	index := x >> (64 - precision)
+	rank := uint8(bits.LeadingZeros64(x<<precision) + 1)
+
+	if limit := uint8(64 - precision + 1); rank > limit {
+		rank = limit
+	}
+
+	if rank > s.Registers[index] {
+		s.Registers[index] = rank
+	}
+}
+
+// Estimate - возвращает оценку количества уникальных элементов множества.
+func (s Set) Estimate() uint64 {
+	m := float64(len(s.Registers))
+	if m == 0 {
+		return 0
+	}
+
+	sum, zeros := 0.0, 0
+	for _, r := range s.Registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := s.alpha() * m * m / sum
+
+	// для малых множеств точнее линейный подсчёт по пустым регистрам.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+// Validate - проверяет, что количество регистров - степень двойки в допустимых
+// пределах, а значения регистров не превышают возможную позицию первой единицы.
+func (s Set) Validate() error {
+	n := len(s.Registers)
+	if n < 1<<MinSetPrecision || n > 1<<MaxSetPrecision || n&(n-1) != 0 {
+		return fmt.Errorf("%w: количество регистров должно быть степенью двойки от %d до %d",
+			ErrorInvalidSet, 1<<MinSetPrecision, 1<<MaxSetPrecision)
+	}
+
+	limit := uint8(64 - s.precision() + 1)
+	for _, r := range s.Registers {
+		if r > limit {
+			return fmt.Errorf("%w: значение регистра больше %d", ErrorInvalidSet, limit)
+		}
+	}
+
+	return nil
+}
+
+// Merge - объединяет множество с элементами other. Регистры разного размера
+// объединить нельзя, поэтому в этом случае множество заменяется other.
+func (s Set) Merge(other Set) Set {
+	merged := Set{Registers: append([]uint8(nil), other.Registers...)}
+	if len(s.Registers) != len(other.Registers) {
+		return merged
+	}
+
+	for i, r := range s.Registers {
+		if r > merged.Registers[i] {
+			merged.Registers[i] = r
+		}
+	}
+
+	return merged
+}
+
+func (s Set) Kind() string {
+	return string(KindSet)
+}
+
+// String - возвращает оценку количества уникальных элементов.
+func (s Set) String() string {
+	return strconv.FormatUint(s.Estimate(), 10)
+}
+
+func (s Set) IsCounter() bool {
+	return false
+}
+
+func (s Set) IsGauge() bool {
+	return false
+}
+
+// precision - возвращает количество бит хеша, выбирающих регистр.
+func (s Set) precision() int {
+	return bits.TrailingZeros(uint(len(s.Registers)))
+}
+
+// alpha - возвращает поправочный коэффициент оценки для количества регистров.
+func (s Set) alpha() float64 {
+	switch m := float64(len(s.Registers)); {
+	case m <= 16:
+		return 0.673
+	case m <= 32:
+		return 0.697
+	case m <= 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/m)
+	}
+}
+
+// mix64 - перемешивает биты хеша (финализатор SplitMix64), чтобы старшие
+// биты, выбирающие регистр, зависели от всех байт элемента.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
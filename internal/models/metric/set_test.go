@@ -0,0 +1,92 @@
+package metric
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet_Estimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+	}{
+		{name: "empty", distinct: 0},
+		{name: "small", distinct: 100},
+		{name: "medium", distinct: 10000},
+		{name: "large", distinct: 200000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSet(DefaultSetPrecision)
+			require.NoError(t, err)
+
+			// повторы элементов не влияют на оценку.
+			for repeat := 0; repeat < 2; repeat++ {
+				for i := 0; i < tt.distinct; i++ {
+					s.Add(fmt.Sprintf("user-%d", i))
+				}
+			}
+
+			require.NoError(t, s.Validate())
+			assert.InDelta(t, tt.distinct, s.Estimate(), float64(tt.distinct)*0.03)
+			assert.Equal(t, "set", s.Kind())
+		})
+	}
+}
+
+func TestSet_Merge(t *testing.T) {
+	a, _ := NewSet(DefaultSetPrecision)
+	b, _ := NewSet(DefaultSetPrecision)
+	all, _ := NewSet(DefaultSetPrecision)
+
+	// множества двух агентов пересекаются наполовину.
+	for i := 0; i < 10000; i++ {
+		a.Add(fmt.Sprintf("10.0.0.%d", i))
+		all.Add(fmt.Sprintf("10.0.0.%d", i))
+	}
+
+	for i := 5000; i < 15000; i++ {
+		b.Add(fmt.Sprintf("10.0.0.%d", i))
+		all.Add(fmt.Sprintf("10.0.0.%d", i))
+	}
+
+	merged := a.Merge(b)
+	assert.Equal(t, all, merged)
+	assert.InDelta(t, 15000, merged.Estimate(), 15000*0.03)
+	assert.NotEqual(t, all, a, "исходное множество не изменилось")
+
+	other, _ := NewSet(MinSetPrecision)
+	other.Add("x")
+	assert.Equal(t, other, merged.Merge(other))
+}
+
+func TestSet_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Set
+		wantErr bool
+	}{
+		{name: "valid", s: Set{Registers: make([]uint8, 16)}},
+		{name: "no registers", s: Set{}, wantErr: true},
+		{name: "not a power of two", s: Set{Registers: make([]uint8, 24)}, wantErr: true},
+		{name: "too many registers", s: Set{Registers: make([]uint8, 1<<17)}, wantErr: true},
+		{name: "register overflow", s: Set{Registers: append(make([]uint8, 15), 62)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrorInvalidSet)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+
+	_, err := NewSet(MaxSetPrecision + 1)
+	require.ErrorIs(t, err, ErrorInvalidSet)
+}
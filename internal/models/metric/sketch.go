@@ -0,0 +1,387 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type (
+	// Sketch - квантильный эскиз DDSketch. Наблюдения распределяются по корзинам
+	// с логарифмически растущими границами, поэтому любой квантиль оценивается
+	// с относительной погрешностью не больше RelativeAccuracy, а эскизы
+	// с одинаковой точностью объединяются без потери точности.
+	Sketch struct {
+		// RelativeAccuracy - допустимая относительная погрешность квантилей от 0 до 1.
+		RelativeAccuracy float64
+		// Positive - количество положительных наблюдений по индексам корзин.
+		Positive SketchBins
+		// Negative - количество отрицательных наблюдений по индексам корзин их модулей.
+		Negative SketchBins
+		// Zero - количество наблюдений, по модулю меньших SketchMinValue.
+		Zero uint64
+		// Count - количество наблюдений.
+		Count uint64
+		// Sum - сумма наблюдений.
+		Sum float64
+		// Min, Max - минимальное и максимальное наблюдения.
+		Min, Max float64
+	}
+
+	// SketchBins - количество наблюдений в корзинах эскиза по индексам корзин.
+	// Корзина с индексом i содержит значения из (gamma^(i-1), gamma^i].
+	SketchBins map[int]uint64
+)
+
+const (
+	// DefaultSketchAccuracy - относительная погрешность квантилей эскиза по умолчанию.
+	DefaultSketchAccuracy = 0.01
+	// MinSketchAccuracy - наименьшая допустимая относительная погрешность квантилей:
+	// от неё зависит количество возможных корзин эскиза и размер его компактного вида.
+	MinSketchAccuracy = 0.001
+	// SketchMinValue - наименьший по модулю элемент, попадающий в корзины;
+	// меньшие по модулю наблюдения учитываются как нулевые.
+	SketchMinValue = 1e-9
+	// MaxSketchBins - максимальное количество корзин положительных или отрицательных
+	// наблюдений. При превышении самые нижние корзины объединяются, и погрешность
+	// гарантируется только для квантилей, не попадающих в объединённые корзины.
+	MaxSketchBins = 2048
+)
+
+var (
+	// ErrorInvalidSketch - не корректный квантильный эскиз.
+	ErrorInvalidSketch = errors.New("metrics: не корректный квантильный эскиз")
+	// ErrorInvalidQuantile - уровень квантиля не лежит в [0, 1].
+	ErrorInvalidQuantile = errors.New("metrics: уровень квантиля должен лежать в [0, 1]")
+	// ErrorEmptySketch - эскиз не содержит наблюдений.
+	ErrorEmptySketch = errors.New("metrics: эскиз не содержит наблюдений")
+
+	// sketchQuantiles - квантили, выводимые в строковом представлении эскиза.
+	sketchQuantiles = []float64{0.5, 0.9, 0.99}
+)
+
+// NewSketch - создаёт пустой эскиз с относительной погрешностью квантилей relativeAccuracy.
+func NewSketch(relativeAccuracy float64) (Sketch, error) {
+	s := Sketch{RelativeAccuracy: relativeAccuracy}
+	if err := s.Validate(); err != nil {
+		return Sketch{}, err
+	}
+
+	return s, nil
+}
+
+// Add - добавляет наблюдение v в эскиз.
+func (s *Sketch) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	switch {
+	case v >= SketchMinValue:
+		if s.Positive == nil {
+			s.Positive = SketchBins{}
+		}
+		s.Positive[s.index(v)]++
+		s.Positive.collapse()
+	case v <= -SketchMinValue:
+		if s.Negative == nil {
+			s.Negative = SketchBins{}
+		}
+		s.Negative[s.index(-v)]++
+		s.Negative.collapse()
+	default:
+		s.Zero++
+	}
+
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+
+	s.Count++
+	s.Sum += v
+}
+
+// Quantile - возвращает оценку квантиля уровня q.
+func (s Sketch) Quantile(q float64) (float64, error) {
+	if math.IsNaN(q) || q < 0 || q > 1 {
+		return 0, ErrorInvalidQuantile
+	}
+
+	if s.Count == 0 {
+		return 0, ErrorEmptySketch
+	}
+
+	rank := uint64(q * float64(s.Count-1))
+
+	var seen uint64
+
+	// отрицательные наблюдения упорядочены по убыванию модуля.
+	negative := s.Negative.indexes()
+	for i := len(negative) - 1; i >= 0; i-- {
+		seen += s.Negative[negative[i]]
+		if seen > rank {
+			return s.clamp(-s.value(negative[i])), nil
+		}
+	}
+
+	seen += s.Zero
+	if seen > rank {
+		return s.clamp(0), nil
+	}
+
+	for _, i := range s.Positive.indexes() {
+		seen += s.Positive[i]
+		if seen > rank {
+			return s.clamp(s.value(i)), nil
+		}
+	}
+
+	return s.Max, nil
+}
+
+// Validate - проверяет эскиз: погрешность лежит в [MinSketchAccuracy, 1), индексы
+// корзин допустимы для этой погрешности, количество наблюдений равно сумме корзин,
+// а сумма и крайние наблюдения конечны.
+func (s Sketch) Validate() error {
+	if err := validateSketchAccuracy(s.RelativeAccuracy); err != nil {
+		return err
+	}
+
+	if len(s.Positive) > MaxSketchBins || len(s.Negative) > MaxSketchBins {
+		return fmt.Errorf("%w: больше %d корзин", ErrorInvalidSketch, MaxSketchBins)
+	}
+
+	low, high := sketchIndexBounds(s.RelativeAccuracy)
+	for _, bins := range []SketchBins{s.Positive, s.Negative} {
+		for i := range bins {
+			if i < low || i > high {
+				return fmt.Errorf("%w: индекс корзины %d вне [%d, %d]", ErrorInvalidSketch, i, low, high)
+			}
+		}
+	}
+
+	count := s.Zero
+	for _, c := range s.Positive {
+		count += c
+	}
+
+	for _, c := range s.Negative {
+		count += c
+	}
+
+	if count != s.Count {
+		return fmt.Errorf("%w: количество наблюдений не равно сумме корзин", ErrorInvalidSketch)
+	}
+
+	for _, v := range []float64{s.Sum, s.Min, s.Max} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%w: сумма и крайние наблюдения должны быть конечны", ErrorInvalidSketch)
+		}
+	}
+
+	if s.Count > 0 && s.Min > s.Max {
+		return fmt.Errorf("%w: минимальное наблюдение больше максимального", ErrorInvalidSketch)
+	}
+
+	return nil
+}
+
+// Merge - объединяет эскиз с наблюдениями other. Эскизы с разной погрешностью
+// нельзя объединить без потери гарантии точности, поэтому в этом случае
+// эскиз заменяется other.
+func (s Sketch) Merge(other Sketch) Sketch {
+	if s.RelativeAccuracy != other.RelativeAccuracy || s.Count == 0 {
+		return other.clone()
+	}
+
+	if other.Count == 0 {
+		return s.clone()
+	}
+
+	merged := s.clone()
+	merged.Positive = merged.Positive.merge(other.Positive)
+	merged.Negative = merged.Negative.merge(other.Negative)
+	merged.Zero += other.Zero
+	merged.Count += other.Count
+	merged.Sum += other.Sum
+	merged.Min = math.Min(s.Min, other.Min)
+	merged.Max = math.Max(s.Max, other.Max)
+
+	return merged
+}
+
+func (s Sketch) Kind() string {
+	return string(KindSketch)
+}
+
+// String - возвращает эскиз в виде "count=3 sum=1.5 p50=0.5 p90=0.9 p99=0.99".
+func (s Sketch) String() string {
+	parts := []string{fmt.Sprintf("count=%d sum=%s", s.Count, formatFloat(s.Sum))}
+
+	for _, q := range sketchQuantiles {
+		if v, err := s.Quantile(q); err == nil {
+			parts = append(parts, fmt.Sprintf("p%s=%s", formatFloat(q*100), formatFloat(v)))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (s Sketch) IsCounter() bool {
+	return false
+}
+
+func (s Sketch) IsGauge() bool {
+	return false
+}
+
+// gamma - отношение границ соседних корзин.
+func (s Sketch) gamma() float64 {
+	return sketchGamma(s.RelativeAccuracy)
+}
+
+func sketchGamma(relativeAccuracy float64) float64 {
+	return (1 + relativeAccuracy) / (1 - relativeAccuracy)
+}
+
+// validateSketchAccuracy - проверяет, что погрешность лежит в [MinSketchAccuracy, 1).
+func validateSketchAccuracy(relativeAccuracy float64) error {
+	if math.IsNaN(relativeAccuracy) || relativeAccuracy < MinSketchAccuracy || relativeAccuracy >= 1 {
+		return fmt.Errorf("%w: погрешность должна лежать в [%v, 1)", ErrorInvalidSketch, MinSketchAccuracy)
+	}
+
+	return nil
+}
+
+// sketchIndexBounds - возвращает наименьший и наибольший индексы корзин
+// наблюдений от SketchMinValue до math.MaxFloat64 для погрешности relativeAccuracy.
+func sketchIndexBounds(relativeAccuracy float64) (int, int) {
+	logGamma := math.Log(sketchGamma(relativeAccuracy))
+
+	return int(math.Floor(math.Log(SketchMinValue) / logGamma)), int(math.Ceil(math.Log(math.MaxFloat64) / logGamma))
+}
+
+// index - возвращает индекс корзины положительного значения v.
+func (s Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / math.Log(s.gamma())))
+}
+
+// value - возвращает оценку значений корзины index с относительной
+// погрешностью не больше RelativeAccuracy.
+func (s Sketch) value(index int) float64 {
+	g := s.gamma()
+
+	return 2 * math.Pow(g, float64(index)) / (g + 1)
+}
+
+// clamp - ограничивает оценку квантиля крайними наблюдениями.
+func (s Sketch) clamp(v float64) float64 {
+	return math.Max(s.Min, math.Min(s.Max, v))
+}
+
+func (s Sketch) clone() Sketch {
+	c := s
+	c.Positive = s.Positive.merge(nil)
+	c.Negative = s.Negative.merge(nil)
+
+	return c
+}
+
+// Dense - возвращает корзины в компактном виде: индекс первой корзины
+// и количество наблюдений в корзинах подряд начиная с неё.
+func (b SketchBins) Dense() (int, []uint64) {
+	indexes := b.indexes()
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+
+	offset := indexes[0]
+	counts := make([]uint64, indexes[len(indexes)-1]-offset+1)
+
+	for _, i := range indexes {
+		counts[i-offset] = b[i]
+	}
+
+	return offset, counts
+}
+
+// NewSketchBins - создаёт корзины эскиза с погрешностью relativeAccuracy
+// из компактного вида, полученного Dense. Если корзины выходят за допустимые
+// для погрешности индексы, то возвращается ошибка ErrorInvalidSketch.
+func NewSketchBins(relativeAccuracy float64, offset int, counts []uint64) (SketchBins, error) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+
+	if err := validateSketchAccuracy(relativeAccuracy); err != nil {
+		return nil, err
+	}
+
+	low, high := sketchIndexBounds(relativeAccuracy)
+	if offset < low || offset > high || len(counts) > high-offset+1 {
+		return nil, fmt.Errorf("%w: %d корзин с индекса %d выходят за [%d, %d]",
+			ErrorInvalidSketch, len(counts), offset, low, high)
+	}
+
+	b := SketchBins{}
+	for i, c := range counts {
+		if c > 0 {
+			b[offset+i] = c
+		}
+	}
+
+	return b, nil
+}
+
+// indexes - возвращает индексы непустых корзин в порядке возрастания.
+func (b SketchBins) indexes() []int {
+	indexes := make([]int, 0, len(b))
+	for i := range b {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	return indexes
+}
+
+// merge - возвращает новые корзины с суммой наблюдений b и other.
+func (b SketchBins) merge(other SketchBins) SketchBins {
+	if len(b) == 0 && len(other) == 0 {
+		return nil
+	}
+
+	merged := make(SketchBins, len(b)+len(other))
+	for i, c := range b {
+		merged[i] += c
+	}
+
+	for i, c := range other {
+		merged[i] += c
+	}
+
+	merged.collapse()
+
+	return merged
+}
+
+// collapse - объединяет самые нижние корзины, если их больше MaxSketchBins.
+func (b SketchBins) collapse() {
+	if len(b) <= MaxSketchBins {
+		return
+	}
+
+	indexes := b.indexes()
+	target := indexes[len(indexes)-MaxSketchBins]
+
+	for _, i := range indexes[:len(indexes)-MaxSketchBins] {
+		b[target] += b[i]
+		delete(b, i)
+	}
+}
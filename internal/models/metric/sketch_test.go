@@ -0,0 +1,171 @@
+package metric
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch_Quantile(t *testing.T) {
+	s, err := NewSketch(DefaultSketchAccuracy)
+	require.NoError(t, err)
+
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+
+	require.NoError(t, s.Validate())
+	assert.Equal(t, uint64(1000), s.Count)
+	assert.Equal(t, "sketch", s.Kind())
+
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		got, err := s.Quantile(q)
+		require.NoError(t, err)
+
+		want := 1 + math.Floor(q*999)
+		assert.InEpsilon(t, want, got, DefaultSketchAccuracy, "q=%v", q)
+	}
+
+	_, err = s.Quantile(1.5)
+	require.ErrorIs(t, err, ErrorInvalidQuantile)
+
+	_, err = Sketch{RelativeAccuracy: DefaultSketchAccuracy}.Quantile(0.5)
+	require.ErrorIs(t, err, ErrorEmptySketch)
+}
+
+func TestSketch_NegativeAndZero(t *testing.T) {
+	s, err := NewSketch(0.02)
+	require.NoError(t, err)
+
+	for _, v := range []float64{-100, -10, 0, 10, 100} {
+		s.Add(v)
+	}
+
+	require.NoError(t, s.Validate())
+	assert.Equal(t, uint64(1), s.Zero)
+
+	for q, want := range map[float64]float64{0: -100, 0.25: -10, 0.5: 0, 0.75: 10, 1: 100} {
+		got, err := s.Quantile(q)
+		require.NoError(t, err)
+		assert.InDelta(t, want, got, math.Abs(want)*0.02, "q=%v", q)
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, _ := NewSketch(DefaultSketchAccuracy)
+	b, _ := NewSketch(DefaultSketchAccuracy)
+	all, _ := NewSketch(DefaultSketchAccuracy)
+
+	// агенты с разными диапазонами значений.
+	for i := 1; i <= 500; i++ {
+		a.Add(float64(i) / 1000)
+		all.Add(float64(i) / 1000)
+	}
+
+	for i := 1; i <= 500; i++ {
+		b.Add(float64(i) * 10)
+		all.Add(float64(i) * 10)
+	}
+
+	merged := a.Merge(b)
+	require.NoError(t, merged.Validate())
+	assert.Equal(t, all, merged)
+	assert.Equal(t, uint64(500), a.Count, "исходный эскиз не изменился")
+
+	other, _ := NewSketch(0.05)
+	other.Add(1)
+	assert.Equal(t, other, merged.Merge(other))
+}
+
+func TestSketch_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Sketch
+		wantErr bool
+	}{
+		{name: "valid", s: Sketch{RelativeAccuracy: 0.01, Positive: SketchBins{10: 2}, Zero: 1, Count: 3, Sum: 2, Max: 1}},
+		{name: "empty", s: Sketch{RelativeAccuracy: 0.01}},
+		{name: "zero accuracy", s: Sketch{}, wantErr: true},
+		{name: "accuracy below minimum", s: Sketch{RelativeAccuracy: MinSketchAccuracy / 10}, wantErr: true},
+		{name: "bin index too large", s: Sketch{RelativeAccuracy: 0.01, Positive: SketchBins{2000000000: 1}, Count: 1, Sum: 1, Min: 1, Max: 1}, wantErr: true},
+		{name: "bin index too small", s: Sketch{RelativeAccuracy: 0.01, Negative: SketchBins{-2000000000: 1}, Count: 1, Sum: -1, Min: -1, Max: -1}, wantErr: true},
+		{name: "wrong count", s: Sketch{RelativeAccuracy: 0.01, Positive: SketchBins{1: 2}, Count: 3}, wantErr: true},
+		{name: "NaN sum", s: Sketch{RelativeAccuracy: 0.01, Sum: math.NaN()}, wantErr: true},
+		{name: "min greater than max", s: Sketch{RelativeAccuracy: 0.01, Zero: 1, Count: 1, Min: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrorInvalidSketch)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSketchBins_Dense(t *testing.T) {
+	b := SketchBins{-2: 1, 0: 3, 1: 2}
+
+	offset, counts := b.Dense()
+	assert.Equal(t, -2, offset)
+	assert.Equal(t, []uint64{1, 0, 3, 2}, counts)
+	bins, err := NewSketchBins(DefaultSketchAccuracy, offset, counts)
+	require.NoError(t, err)
+	assert.Equal(t, b, bins)
+
+	offset, counts = SketchBins(nil).Dense()
+	assert.Zero(t, offset)
+	assert.Nil(t, counts)
+
+	bins, err = NewSketchBins(DefaultSketchAccuracy, 0, nil)
+	require.NoError(t, err)
+	assert.Nil(t, bins)
+}
+
+func TestNewSketchBins_bounds(t *testing.T) {
+	// крайние наблюдения попадают в допустимые корзины.
+	s, _ := NewSketch(DefaultSketchAccuracy)
+	s.Add(SketchMinValue)
+	s.Add(math.MaxFloat64)
+	s.Add(-math.MaxFloat64)
+	require.NoError(t, s.Validate())
+
+	low, high := sketchIndexBounds(DefaultSketchAccuracy)
+
+	_, err := NewSketchBins(DefaultSketchAccuracy, low, make([]uint64, high-low+1))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		accuracy float64
+		offset   int
+		counts   int
+	}{
+		{name: "offset too large", accuracy: DefaultSketchAccuracy, offset: 2000000000, counts: 1},
+		{name: "offset too small", accuracy: DefaultSketchAccuracy, offset: low - 1, counts: 1},
+		{name: "too many counts", accuracy: DefaultSketchAccuracy, offset: low, counts: high - low + 2},
+		{name: "index overflow", accuracy: DefaultSketchAccuracy, offset: math.MaxInt, counts: 2},
+		{name: "invalid accuracy", accuracy: 0, offset: 0, counts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSketchBins(tt.accuracy, tt.offset, make([]uint64, tt.counts))
+			require.ErrorIs(t, err, ErrorInvalidSketch)
+		})
+	}
+}
+
+func TestSketchBins_collapse(t *testing.T) {
+	s, _ := NewSketch(DefaultSketchAccuracy)
+	for i := 0; i < MaxSketchBins+10; i++ {
+		s.Add(math.Pow(s.gamma(), float64(i)))
+	}
+
+	require.NoError(t, s.Validate())
+	assert.Len(t, s.Positive, MaxSketchBins)
+}
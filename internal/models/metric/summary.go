@@ -0,0 +1,92 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type (
+	// Quantile - значение квантиля распределения наблюдений.
+	Quantile struct {
+		// Quantile - уровень квантиля от 0 до 1.
+		Quantile float64
+		// Value - значение квантиля.
+		Value float64
+	}
+
+	// Summary - квантили, сумма и количество наблюдений, рассчитанные на стороне клиента.
+	Summary struct {
+		// Quantiles - квантили в порядке возрастания уровня.
+		Quantiles []Quantile
+		// Sum - сумма наблюдений.
+		Sum float64
+		// Count - количество наблюдений.
+		Count uint64
+	}
+)
+
+var (
+	// ErrorInvalidSummary - не корректная сводка квантилей.
+	ErrorInvalidSummary = errors.New("metrics: не корректная сводка квантилей")
+)
+
+// Validate - проверяет сводку: уровни квантилей лежат в [0, 1] и строго возрастают,
+// значения квантилей и сумма наблюдений конечны.
+func (s Summary) Validate() error {
+	for i, q := range s.Quantiles {
+		if math.IsNaN(q.Quantile) || q.Quantile < 0 || q.Quantile > 1 || i > 0 && q.Quantile <= s.Quantiles[i-1].Quantile {
+			return fmt.Errorf("%w: уровни квантилей должны лежать в [0, 1] и возрастать", ErrorInvalidSummary)
+		}
+
+		if math.IsNaN(q.Value) || math.IsInf(q.Value, 0) {
+			return fmt.Errorf("%w: значение квантиля %s должно быть конечно", ErrorInvalidSummary, formatFloat(q.Quantile))
+		}
+	}
+
+	if math.IsNaN(s.Sum) || math.IsInf(s.Sum, 0) {
+		return fmt.Errorf("%w: сумма наблюдений должна быть конечна", ErrorInvalidSummary)
+	}
+
+	return nil
+}
+
+// Merge - объединяет сводку с наблюдениями other. Сумма и количество наблюдений
+// суммируются. Квантили разных наборов наблюдений нельзя объединить точно,
+// поэтому сохраняются последние полученные квантили.
+func (s Summary) Merge(other Summary) Summary {
+	merged := Summary{
+		Quantiles: append([]Quantile(nil), s.Quantiles...),
+		Sum:       s.Sum + other.Sum,
+		Count:     s.Count + other.Count,
+	}
+
+	if len(other.Quantiles) > 0 {
+		merged.Quantiles = append([]Quantile(nil), other.Quantiles...)
+	}
+
+	return merged
+}
+
+func (s Summary) Kind() string {
+	return string(KindSummary)
+}
+
+// String - возвращает сводку в виде "count=3 sum=1.5 quantiles=0.5:0.4,0.99:0.9".
+func (s Summary) String() string {
+	quantiles := make([]string, 0, len(s.Quantiles))
+	for _, q := range s.Quantiles {
+		quantiles = append(quantiles, formatFloat(q.Quantile)+":"+formatFloat(q.Value))
+	}
+
+	return fmt.Sprintf("count=%d sum=%s quantiles=%s", s.Count, formatFloat(s.Sum), strings.Join(quantiles, ","))
+}
+
+func (s Summary) IsCounter() bool {
+	return false
+}
+
+func (s Summary) IsGauge() bool {
+	return false
+}
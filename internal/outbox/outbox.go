@@ -0,0 +1,424 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+)
+
+type (
+	// Outbox - очередь неотправленных снимков метрик агента в каталоге на диске.
+	// Каждый снимок хранится в отдельном файле, имя которого - порядковый номер
+	// снимка, поэтому очередь переживает перезапуск агента и отправляется
+	// в порядке поступления снимков.
+	//
+	// Очередь ограничена количеством снимков и их возрастом. При превышении
+	// количества два самых старых снимка объединяются в один, а у снимков старше
+	// допустимого возраста значения gauge отбрасываются. Значения counter при этом
+	// не теряются: они суммируются со следующим снимком очереди.
+	Outbox struct {
+		mu         sync.Mutex
+		dir        string
+		maxBatches int
+		maxAge     time.Duration
+		now        func() time.Time
+		// seq - номер последнего добавленного снимка.
+		seq uint64
+		// inflight - номер отправляемого снимка, который нельзя изменять.
+		inflight uint64
+		// replaying - признак выполняющейся отправки очереди.
+		replaying bool
+	}
+
+	// batch - снимок метрик в файле очереди.
+	batch struct {
+		Created time.Time               `json:"created"`
+		Metrics []adapter.RequestMetric `json:"metrics"`
+	}
+)
+
+const (
+	// batchExt - расширение файлов снимков.
+	batchExt = ".json"
+	// seqWidth - ширина порядкового номера в имени файла снимка.
+	seqWidth = 20
+)
+
+var (
+	// ErrInvalidMaxBatches - не корректное максимальное количество снимков в очереди.
+	ErrInvalidMaxBatches = errors.New("outbox: max batches has to be positive")
+	// ErrCorruptedBatch - повреждённый снимок в очереди.
+	ErrCorruptedBatch = errors.New("outbox: corrupted batch")
+	// ErrBatchDropped - снимок удалён из очереди после окончательной ошибки отправки.
+	ErrBatchDropped = errors.New("outbox: batch dropped")
+)
+
+// New - открывает очередь в каталоге dir, создавая его при необходимости.
+// Снимки, оставшиеся в каталоге после предыдущего запуска, сохраняются в очереди.
+// Значение maxAge равное 0 отключает ограничение возраста снимков.
+func New(dir string, maxBatches int, maxAge time.Duration) (*Outbox, error) {
+	if maxBatches < 1 {
+		return nil, ErrInvalidMaxBatches
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	// временные файлы остаются после аварийного завершения во время записи снимка.
+	tmp, err := filepath.Glob(filepath.Join(dir, "*"+batchExt+".tmp"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range tmp {
+		os.Remove(name)
+	}
+
+	o := &Outbox{dir: dir, maxBatches: maxBatches, maxAge: maxAge, now: time.Now}
+
+	seqs, err := o.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(seqs) > 0 {
+		o.seq = seqs[len(seqs)-1]
+	}
+
+	return o, nil
+}
+
+// Push - добавляет снимок метрик в конец очереди.
+func (o *Outbox) Push(metrics []metric.NamedMetric) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	b := batch{Created: o.now(), Metrics: adapter.NewUpdateRequestMetrics(metrics)}
+	if err := o.write(o.seq+1, b); err != nil {
+		return err
+	}
+
+	o.seq++
+
+	return o.compact()
+}
+
+// Replay - отправляет снимки очереди функцией send в порядке их поступления,
+// удаляя успешно отправленные. Функция send возвращает количество доставленных
+// метрик снимка. Replay останавливается на первой ошибке отправки, допускающей
+// повторную попытку (retry.Retriable), оставляя в начале очереди недоставленные
+// метрики снимка. Снимок с другой ошибкой, например отклонённый сервером с кодом 400,
+// не будет доставлен и повторной отправкой, поэтому он удаляется из очереди,
+// а отправка продолжается со следующего снимка. Ошибка последнего удалённого
+// снимка ErrBatchDropped возвращается после отправки очереди.
+// Если очередь уже отправляется, то сразу возвращает nil: снимки, добавленные
+// во время отправки, будут отправлены той же отправкой.
+func (o *Outbox) Replay(send func(metrics []metric.NamedMetric) (int, error)) error {
+	o.mu.Lock()
+	if o.replaying {
+		o.mu.Unlock()
+		return nil
+	}
+
+	o.replaying = true
+	o.mu.Unlock()
+
+	defer func() {
+		o.mu.Lock()
+		o.replaying = false
+		o.inflight = 0
+		o.mu.Unlock()
+	}()
+
+	// dropped - ошибка отправки последнего удалённого из очереди снимка.
+	var dropped error
+
+	for {
+		seq, b, err := o.next()
+		if err != nil {
+			return withDropped(err, dropped)
+		}
+
+		if seq == 0 {
+			return dropped
+		}
+
+		metrics, err := adapter.ToNamedMetrics(b.Metrics)
+		if err != nil {
+			return withDropped(err, dropped)
+		}
+
+		if n, err := send(metrics); err != nil {
+			if retry.IsRetriable(err) {
+				if n > 0 {
+					b.Metrics = b.Metrics[n:]
+					if err := o.rewrite(seq, b); err != nil {
+						return withDropped(err, dropped)
+					}
+				}
+
+				return withDropped(err, dropped)
+			}
+
+			dropped = fmt.Errorf("%w %d: %s", ErrBatchDropped, seq, err)
+		}
+
+		if err := o.remove(seq); err != nil {
+			return withDropped(err, dropped)
+		}
+	}
+}
+
+// withDropped - дополняет ошибку err сообщением об удалённом снимке dropped.
+func withDropped(err, dropped error) error {
+	if dropped == nil {
+		return err
+	}
+
+	return fmt.Errorf("%w (%s)", err, dropped)
+}
+
+// Len - возвращает количество снимков в очереди.
+func (o *Outbox) Len() (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	seqs, err := o.list()
+
+	return len(seqs), err
+}
+
+// next - возвращает первый снимок очереди и помечает его отправляемым.
+// Если очередь пуста, то возвращает нулевой номер.
+func (o *Outbox) next() (uint64, batch, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	seqs, err := o.list()
+	if err != nil || len(seqs) == 0 {
+		return 0, batch{}, err
+	}
+
+	b, err := o.read(seqs[0])
+	if err != nil {
+		return 0, batch{}, err
+	}
+
+	o.inflight = seqs[0]
+
+	return seqs[0], b, nil
+}
+
+func (o *Outbox) remove(seq uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.inflight = 0
+
+	return os.Remove(o.path(seq))
+}
+
+// rewrite - заменяет отправляемый снимок его недоставленной частью.
+func (o *Outbox) rewrite(seq uint64, b batch) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.write(seq, b)
+}
+
+// compact - приводит очередь к ограничениям количества и возраста снимков.
+// Отправляемый снимок не изменяется.
+func (o *Outbox) compact() error {
+	seqs, err := o.list()
+	if err != nil {
+		return err
+	}
+
+	if len(seqs) > 0 && seqs[0] == o.inflight {
+		seqs = seqs[1:]
+	}
+
+	deadline := o.now().Add(-o.maxAge)
+
+	// у устаревших снимков отбрасываются gauge, а counter переносятся в следующий снимок.
+	for o.maxAge > 0 && len(seqs) > 1 {
+		b, err := o.read(seqs[0])
+		if err != nil {
+			return err
+		}
+
+		if !b.Created.Before(deadline) {
+			break
+		}
+
+		if err := o.merge(seqs[0], seqs[1], true); err != nil {
+			return err
+		}
+
+		seqs = seqs[1:]
+	}
+
+	for len(seqs) > 1 && len(seqs) > o.maxBatches {
+		if err := o.merge(seqs[0], seqs[1], false); err != nil {
+			return err
+		}
+
+		seqs = seqs[1:]
+	}
+
+	return nil
+}
+
+// merge - объединяет снимок older со следующим снимком newer и удаляет older.
+// Значения counter суммируются, значения gauge берутся из более нового снимка.
+// Если counterOnly, то значения gauge снимка older отбрасываются.
+func (o *Outbox) merge(older, newer uint64, counterOnly bool) error {
+	ob, err := o.read(older)
+	if err != nil {
+		return err
+	}
+
+	nb, err := o.read(newer)
+	if err != nil {
+		return err
+	}
+
+	merged := make([]adapter.RequestMetric, 0, len(ob.Metrics)+len(nb.Metrics))
+	counters := make(map[string]int)
+	gauges := make(map[string]struct{})
+
+	for _, m := range nb.Metrics {
+		switch metric.MetricKind(m.MType) {
+		case metric.KindCounter:
+			counters[metric.Key(m.ID, m.Labels)] = len(merged)
+		case metric.KindGauge:
+			gauges[metric.Key(m.ID, m.Labels)] = struct{}{}
+		}
+
+		merged = append(merged, m)
+	}
+
+	for _, m := range ob.Metrics {
+		switch metric.MetricKind(m.MType) {
+		case metric.KindCounter:
+			i, ok := counters[metric.Key(m.ID, m.Labels)]
+			if !ok {
+				merged = append(merged, m)
+				continue
+			}
+
+			if m.Delta == nil || merged[i].Delta == nil {
+				continue
+			}
+
+			delta := *merged[i].Delta + *m.Delta
+			merged[i].Delta = &delta
+
+		case metric.KindGauge:
+			if _, ok := gauges[metric.Key(m.ID, m.Labels)]; ok || counterOnly {
+				continue
+			}
+
+			merged = append(merged, m)
+		}
+	}
+
+	nb.Metrics = merged
+
+	if err := o.write(newer, nb); err != nil {
+		return err
+	}
+
+	return os.Remove(o.path(older))
+}
+
+// list - возвращает номера снимков очереди в порядке возрастания.
+func (o *Outbox) list() ([]uint64, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seqs := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, batchExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, batchExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	return seqs, nil
+}
+
+func (o *Outbox) read(seq uint64) (batch, error) {
+	b := batch{}
+
+	data, err := os.ReadFile(o.path(seq))
+	if err != nil {
+		return b, err
+	}
+
+	// повреждённый снимок удаляется, чтобы не останавливать очередь.
+	if err := json.Unmarshal(data, &b); err != nil {
+		os.Remove(o.path(seq))
+		return b, fmt.Errorf("%w %d: %s", ErrCorruptedBatch, seq, err)
+	}
+
+	return b, nil
+}
+
+// write - атомарно записывает снимок через временный файл.
+func (o *Outbox) write(seq uint64, b batch) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	tmp := o.path(seq) + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, o.path(seq))
+}
+
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%0*d%s", seqWidth, seq, batchExt))
+}
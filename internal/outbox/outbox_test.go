@@ -0,0 +1,205 @@
+package outbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+)
+
+func snapshot(count int64, alloc float64) []metric.NamedMetric {
+	return []metric.NamedMetric{
+		{Name: "Alloc", Value: metric.Gauge(alloc)},
+		{Name: "PollCount", Value: metric.Counter(count)},
+	}
+}
+
+func collect(t *testing.T, o *Outbox) [][]metric.NamedMetric {
+	sent := make([][]metric.NamedMetric, 0)
+	err := o.Replay(func(metrics []metric.NamedMetric) (int, error) {
+		sent = append(sent, metrics)
+		return len(metrics), nil
+	})
+	require.NoError(t, err)
+
+	return sent
+}
+
+func TestOutbox_ReplayInOrder(t *testing.T) {
+	require := require.New(t)
+
+	o, err := New(t.TempDir(), 10, 0)
+	require.NoError(err)
+
+	require.NoError(o.Push(snapshot(1, 1.5)))
+	require.NoError(o.Push(snapshot(2, 2.5)))
+
+	errUnavailable := errors.New("server unavailable")
+	err = o.Replay(func(metrics []metric.NamedMetric) (int, error) {
+		return 0, retry.Retriable(errUnavailable)
+	})
+	require.ErrorIs(err, errUnavailable)
+
+	n, err := o.Len()
+	require.NoError(err)
+	require.Equal(2, n)
+
+	require.NoError(o.Push(snapshot(3, 3.5)))
+
+	require.Equal([][]metric.NamedMetric{snapshot(1, 1.5), snapshot(2, 2.5), snapshot(3, 3.5)}, collect(t, o))
+
+	n, err = o.Len()
+	require.NoError(err)
+	require.Zero(n)
+}
+
+func TestOutbox_ReplayPartial(t *testing.T) {
+	require := require.New(t)
+
+	o, err := New(t.TempDir(), 10, 0)
+	require.NoError(err)
+
+	require.NoError(o.Push(snapshot(1, 1.5)))
+
+	errUnavailable := errors.New("server unavailable")
+	err = o.Replay(func(metrics []metric.NamedMetric) (int, error) {
+		return 1, retry.Retriable(errUnavailable)
+	})
+	require.ErrorIs(err, errUnavailable)
+
+	// доставленная часть снимка не отправляется повторно.
+	require.Equal([][]metric.NamedMetric{{{Name: "PollCount", Value: metric.Counter(1)}}}, collect(t, o))
+}
+
+func TestOutbox_ReplayDropsRejected(t *testing.T) {
+	require := require.New(t)
+
+	o, err := New(t.TempDir(), 10, 0)
+	require.NoError(err)
+
+	require.NoError(o.Push(snapshot(1, 1.5)))
+	require.NoError(o.Push(snapshot(2, 2.5)))
+
+	// сервер отклоняет первый снимок с кодом 400, повторная отправка его не доставит.
+	errRejected := errors.New("metrics send failed: (400)")
+	rejected := false
+	sent := make([][]metric.NamedMetric, 0)
+
+	err = o.Replay(func(metrics []metric.NamedMetric) (int, error) {
+		if !rejected {
+			rejected = true
+			return 0, errRejected
+		}
+
+		sent = append(sent, metrics)
+
+		return len(metrics), nil
+	})
+	require.ErrorIs(err, ErrBatchDropped)
+	require.Contains(err.Error(), errRejected.Error())
+
+	// следующий снимок доставлен, очередь не остановлена.
+	require.Equal([][]metric.NamedMetric{snapshot(2, 2.5)}, sent)
+
+	n, err := o.Len()
+	require.NoError(err)
+	require.Zero(n)
+}
+
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+
+	o, err := New(dir, 10, 0)
+	require.NoError(err)
+	require.NoError(o.Push(snapshot(1, 1.5)))
+	require.NoError(o.Push(snapshot(2, 2.5)))
+
+	// недописанный снимок после аварийного завершения.
+	tmp := filepath.Join(dir, "00000000000000000003.json.tmp")
+	require.NoError(os.WriteFile(tmp, []byte(`{"created":`), 0600))
+
+	o, err = New(dir, 10, 0)
+	require.NoError(err)
+	require.NoFileExists(tmp)
+	require.NoError(o.Push(snapshot(3, 3.5)))
+
+	require.Equal([][]metric.NamedMetric{snapshot(1, 1.5), snapshot(2, 2.5), snapshot(3, 3.5)}, collect(t, o))
+}
+
+func TestOutbox_MaxBatches(t *testing.T) {
+	require := require.New(t)
+
+	o, err := New(t.TempDir(), 2, 0)
+	require.NoError(err)
+
+	require.NoError(o.Push(snapshot(1, 1.5)))
+	require.NoError(o.Push(snapshot(2, 2.5)))
+	require.NoError(o.Push(snapshot(3, 3.5)))
+	require.NoError(o.Push([]metric.NamedMetric{{Name: "Other", Value: metric.Counter(7)}}))
+
+	n, err := o.Len()
+	require.NoError(err)
+	require.Equal(2, n)
+
+	// значения counter суммируются, значения gauge берутся из более нового снимка.
+	require.Equal([][]metric.NamedMetric{
+		{{Name: "Alloc", Value: metric.Gauge(3.5)}, {Name: "PollCount", Value: metric.Counter(6)}},
+		{{Name: "Other", Value: metric.Counter(7)}},
+	}, collect(t, o))
+}
+
+func TestOutbox_MaxAge(t *testing.T) {
+	require := require.New(t)
+
+	o, err := New(t.TempDir(), 10, time.Minute)
+	require.NoError(err)
+
+	now := time.Now()
+	o.now = func() time.Time { return now }
+
+	require.NoError(o.Push(snapshot(1, 1.5)))
+	require.NoError(o.Push([]metric.NamedMetric{
+		{Name: "Sys", Value: metric.Gauge(10)},
+		{Name: "Errors", Value: metric.Counter(1)},
+	}))
+
+	now = now.Add(2 * time.Minute)
+	require.NoError(o.Push(snapshot(3, 3.5)))
+
+	// устаревшие gauge отброшены, counter перенесены в последний снимок.
+	require.Equal([][]metric.NamedMetric{
+		{
+			{Name: "Alloc", Value: metric.Gauge(3.5)},
+			{Name: "PollCount", Value: metric.Counter(4)},
+			{Name: "Errors", Value: metric.Counter(1)},
+		},
+	}, collect(t, o))
+}
+
+func TestOutbox_Corrupted(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+
+	o, err := New(dir, 10, 0)
+	require.NoError(err)
+	require.NoError(o.Push(snapshot(1, 1.5)))
+	require.NoError(os.WriteFile(o.path(1), []byte("{"), 0600))
+	require.NoError(o.Push(snapshot(2, 2.5)))
+
+	err = o.Replay(func(metrics []metric.NamedMetric) (int, error) { return len(metrics), nil })
+	require.ErrorIs(err, ErrCorruptedBatch)
+
+	require.Equal([][]metric.NamedMetric{snapshot(2, 2.5)}, collect(t, o))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(t.TempDir(), 0, 0)
+	require.ErrorIs(t, err, ErrInvalidMaxBatches)
+}
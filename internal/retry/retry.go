@@ -0,0 +1,151 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type (
+	// Backoff - политика повторных попыток: задержки перед каждой повторной
+	// попыткой и доля случайного отклонения задержки.
+	Backoff struct {
+		Delays []time.Duration
+		Jitter float64
+	}
+
+	// retriableError - ошибка, после которой операцию можно повторить.
+	retriableError struct {
+		err error
+	}
+)
+
+const (
+	// DefaultJitter - доля случайного отклонения задержки по умолчанию.
+	DefaultJitter = 0.2
+)
+
+var (
+	// DefaultDelays - задержки перед повторными попытками по умолчанию.
+	DefaultDelays = []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}
+
+	// ErrInvalidDelays - не корректный список задержек.
+	ErrInvalidDelays = errors.New("retry: invalid delays")
+)
+
+// New - создаёт политику повторных попыток с задержками delays и отклонением DefaultJitter.
+func New(delays ...time.Duration) Backoff {
+	return Backoff{Delays: delays, Jitter: DefaultJitter}
+}
+
+// Default - возвращает политику повторных попыток по умолчанию.
+func Default() Backoff {
+	return New(DefaultDelays...)
+}
+
+// ParseDelays - разбирает список задержек, разделённых запятыми, например "1s,3s,5s".
+// Пустая строка означает отсутствие повторных попыток.
+func ParseDelays(s string) ([]time.Duration, error) {
+	delays := make([]time.Duration, 0)
+	if len(strings.TrimSpace(s)) == 0 {
+		return delays, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d < 0 {
+			return nil, ErrInvalidDelays
+		}
+
+		delays = append(delays, d)
+	}
+
+	return delays, nil
+}
+
+// Retriable - помечает ошибку err как допускающую повторную попытку.
+func Retriable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return retriableError{err: err}
+}
+
+// IsRetriable - проверяет, допускает ли ошибка повторную попытку.
+func IsRetriable(err error) bool {
+	return errors.As(err, &retriableError{})
+}
+
+// IsNetworkError - проверяет, вызвана ли ошибка сбоем сети:
+// отказом в соединении, разрывом соединения или превышением времени ожидания.
+func IsNetworkError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Do - выполняет fn и повторяет её после задержек политики, пока fn возвращает
+// ошибку, допускающую повторную попытку. Возвращает ошибку последней попытки
+// без пометки Retriable или ошибку контекста, если он завершился во время ожидания.
+func (b Backoff) Do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if !IsRetriable(err) || attempt >= len(b.Delays) {
+			return unwrap(err)
+		}
+
+		timer := time.NewTimer(b.delay(attempt))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// delay - задержка перед повторной попыткой attempt со случайным отклонением.
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Delays[attempt]
+	if b.Jitter <= 0 {
+		return d
+	}
+
+	return time.Duration(float64(d) * (1 + b.Jitter*(2*rand.Float64()-1)))
+}
+
+func (e retriableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retriableError) Unwrap() error {
+	return e.err
+}
+
+func unwrap(err error) error {
+	if re, ok := err.(retriableError); ok {
+		return re.err
+	}
+
+	return err
+}
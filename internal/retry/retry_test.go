@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff_Do(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+
+	t.Run("success after retries", func(t *testing.T) {
+		attempts := 0
+		err := New(time.Millisecond, time.Millisecond).Do(context.Background(), func() error {
+			attempts++
+			if attempts < 3 {
+				return Retriable(errTransient)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		attempts := 0
+		err := New(time.Millisecond, time.Millisecond).Do(context.Background(), func() error {
+			attempts++
+			return Retriable(errTransient)
+		})
+		require.Equal(t, errTransient, err)
+		require.False(t, IsRetriable(err))
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		attempts := 0
+		err := New(time.Millisecond).Do(context.Background(), func() error {
+			attempts++
+			return errFatal
+		})
+		require.Equal(t, errFatal, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := New(time.Hour).Do(ctx, func() error {
+			return Retriable(errTransient)
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestBackoff_delay(t *testing.T) {
+	b := Backoff{Delays: []time.Duration{time.Second}, Jitter: 0.2}
+
+	for i := 0; i < 100; i++ {
+		d := b.delay(0)
+		require.GreaterOrEqual(t, d, 800*time.Millisecond)
+		require.LessOrEqual(t, d, 1200*time.Millisecond)
+	}
+}
+
+func TestParseDelays(t *testing.T) {
+	delays, err := ParseDelays("1s, 3s,5s")
+	require.NoError(t, err)
+	require.Equal(t, DefaultDelays, delays)
+
+	delays, err = ParseDelays("")
+	require.NoError(t, err)
+	require.Empty(t, delays)
+
+	_, err = ParseDelays("1s,soon")
+	require.ErrorIs(t, err, ErrInvalidDelays)
+}
+
+func TestIsNetworkError(t *testing.T) {
+	_, err := net.Dial("tcp", "127.0.0.1:1")
+	require.Error(t, err)
+	require.True(t, IsNetworkError(err))
+
+	require.False(t, IsNetworkError(errors.New("bad request")))
+}
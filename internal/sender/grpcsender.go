@@ -0,0 +1,142 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	pb "github.com/a-x-a/go-metric/internal/proto"
+	"github.com/a-x-a/go-metric/internal/retry"
+	"github.com/a-x-a/go-metric/internal/signer"
+)
+
+type grpcSender struct {
+	conn    *grpc.ClientConn
+	client  pb.MetricsClient
+	timeout time.Duration
+	// backoff - политика повторных попыток отправки.
+	backoff retry.Backoff
+}
+
+// NewGRPCSender - создаёт отправителя метрик на gRPC сервер serverAddress.
+// Подключение к серверу устанавливается при первой отправке. Если задан ключ key,
+// то сообщения запросов подписываются, подпись передаётся в метаданных.
+func NewGRPCSender(serverAddress string, timeout time.Duration, key string, backoff retry.Backoff) (grpcSender, error) {
+	conn, err := grpc.Dial(serverAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(signInterceptor(key)),
+	)
+	if err != nil {
+		return grpcSender{}, err
+	}
+
+	return grpcSender{conn: conn, client: pb.NewMetricsClient(conn), timeout: timeout, backoff: backoff}, nil
+}
+
+// SendMetrics - отправляет метрики по одной вызовом Update.
+func (gs grpcSender) SendMetrics(metrics []metric.NamedMetric) error {
+	for _, requestMetric := range adapter.NewUpdateRequestMetrics(metrics) {
+		req := &pb.UpdateRequest{Metric: requestMetricToProto(requestMetric)}
+
+		err := withRetry(gs.backoff, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), gs.timeout)
+			defer cancel()
+
+			_, err := gs.client.Update(ctx, req)
+
+			return classify(err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendMetricsBatch - отправляет все метрики одним вызовом BatchUpdate.
+func (gs grpcSender) SendMetricsBatch(metrics []metric.NamedMetric) error {
+	requestMetrics := adapter.NewUpdateRequestMetrics(metrics)
+
+	req := &pb.BatchUpdateRequest{Metrics: make([]*pb.Metric, 0, len(requestMetrics))}
+	for _, requestMetric := range requestMetrics {
+		req.Metrics = append(req.Metrics, requestMetricToProto(requestMetric))
+	}
+
+	return withRetry(gs.backoff, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), gs.timeout)
+		defer cancel()
+
+		_, err := gs.client.BatchUpdate(ctx, req)
+
+		return classify(err)
+	})
+}
+
+// Close - закрывает подключение к серверу.
+func (gs grpcSender) Close() error {
+	return gs.conn.Close()
+}
+
+// signInterceptor - добавляет в метаданные запроса подпись его сообщения signer.HashMessage.
+// Если ключ не задан, то запросы не подписываются.
+func signInterceptor(key string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if len(key) == 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return fmt.Errorf("request %T is not a protobuf message", req)
+		}
+
+		hash, err := signer.HashMessage(msg, key)
+		if err != nil {
+			return err
+		}
+
+		ctx = metadata.AppendToOutgoingContext(ctx, signer.MetadataHash, hash)
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// classify - помечает ошибки недоступности сервера и превышения времени ожидания
+// как допускающие повторную попытку.
+func classify(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return retry.Retriable(err)
+	}
+
+	return err
+}
+
+func requestMetricToProto(requestMetric adapter.RequestMetric) *pb.Metric {
+	m := &pb.Metric{Id: requestMetric.ID, Labels: requestMetric.Labels}
+
+	switch metric.MetricKind(requestMetric.MType) {
+	case metric.KindCounter:
+		m.Type = pb.MetricType_COUNTER
+		if requestMetric.Delta != nil {
+			m.Delta = *requestMetric.Delta
+		}
+	case metric.KindGauge:
+		m.Type = pb.MetricType_GAUGE
+		if requestMetric.Value != nil {
+			m.Value = *requestMetric.Value
+		}
+	}
+
+	return m
+}
@@ -2,6 +2,7 @@ package sender
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,51 +10,111 @@ import (
 	"time"
 
 	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/encryptor"
 	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+	"github.com/a-x-a/go-metric/internal/signer"
 )
 
 type httpSender struct {
 	baseURL string
 	client  *http.Client
+	// key - ключ подписи запросов, если пустой, то запросы не подписываются.
+	key string
+	// publicKey - открытый ключ шифрования запросов, если nil, то запросы не шифруются.
+	publicKey *rsa.PublicKey
+	// backoff - политика повторных попыток отправки.
+	backoff retry.Backoff
 	err     error
+	// batch - накопленные для пакетной отправки метрики,
+	// если nil, то метрики отправляются по одной.
+	batch []adapter.RequestMetric
 }
 
-func NewSender(serverAddress string, timeout time.Duration) httpSender {
+func NewSender(serverAddress string, timeout time.Duration, key string, publicKey *rsa.PublicKey, backoff retry.Backoff) httpSender {
 	baseURL := fmt.Sprintf("http://%s", serverAddress)
 	client := &http.Client{Timeout: timeout}
 
-	return httpSender{baseURL: baseURL, client: client, err: nil}
+	return httpSender{baseURL: baseURL, client: client, key: key, publicKey: publicKey, backoff: backoff, err: nil}
 }
 
-func (hs *httpSender) doSend(req string, data []byte) *httpSender {
-	resp, err := hs.client.Post(req, "Content-Type: application/json", bytes.NewReader(data))
+func (hs *httpSender) doSend(url string, data []byte) *httpSender {
+	hash := ""
+	if len(hs.key) > 0 {
+		hash = signer.Hash(data, hs.key)
+	}
+
+	if hs.publicKey != nil {
+		msg, err := encryptor.Encrypt(data, hs.publicKey)
+		if err != nil {
+			hs.err = err
+			return hs
+		}
+
+		data = msg
+	}
+
+	hs.err = withRetry(hs.backoff, func() error {
+		return hs.post(url, data, hash)
+	})
+
+	return hs
+}
+
+// post - выполняет одну попытку отправки запроса.
+// Сетевые ошибки и ответы 502, 503 и 504 допускают повторную попытку.
+func (hs *httpSender) post(url string, data []byte, hash string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
-		hs.err = err
-		return hs
+		return err
 	}
 
-	defer resp.Body.Close()
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(hash) > 0 {
+		req.Header.Set(signer.HeaderHash, hash)
+	}
+
+	if hs.publicKey != nil {
+		req.Header.Set(encryptor.HeaderEncryption, encryptor.EncryptionHybrid)
+	}
 
-	_, err = io.ReadAll(resp.Body)
+	resp, err := hs.client.Do(req)
 	if err != nil {
-		hs.err = err
-		return hs
+		if retry.IsNetworkError(err) {
+			return retry.Retriable(err)
+		}
+
+		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		hs.err = fmt.Errorf("metrics send failed: (%d)", resp.StatusCode)
-		return hs
+	defer resp.Body.Close()
+
+	if _, err = io.ReadAll(resp.Body); err != nil {
+		return retry.Retriable(err)
 	}
 
-	return hs
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return retry.Retriable(fmt.Errorf("metrics send failed: (%d)", resp.StatusCode))
+	}
+
+	return fmt.Errorf("metrics send failed: (%d)", resp.StatusCode)
 }
 
-func (hs *httpSender) exportGauge(name string, value metric.Gauge) *httpSender {
+// export - отправляет метрику на /update/ или добавляет её в пакет для пакетной отправки.
+func (hs *httpSender) export(requestMetric adapter.RequestMetric) *httpSender {
 	if hs.err != nil {
 		return hs
 	}
 
-	requestMetric := adapter.NewUpdateRequestMetricGauge(name, value)
+	if hs.batch != nil {
+		hs.batch = append(hs.batch, requestMetric)
+		return hs
+	}
+
 	data, err := json.Marshal(requestMetric)
 	if err != nil {
 		hs.err = err
@@ -65,60 +126,45 @@ func (hs *httpSender) exportGauge(name string, value metric.Gauge) *httpSender {
 	return hs.doSend(req, data)
 }
 
-func (hs *httpSender) exportCounter(name string, value metric.Counter) *httpSender {
-	if hs.err != nil {
+// flush - отправляет накопленные метрики одним запросом.
+func (hs *httpSender) flush() *httpSender {
+	if hs.err != nil || len(hs.batch) == 0 {
 		return hs
 	}
 
-	requestMetric := adapter.NewUpdateRequestMetricCounter(name, value)
-	data, err := json.Marshal(requestMetric)
+	data, err := json.Marshal(hs.batch)
 	if err != nil {
 		hs.err = err
 		return hs
 	}
 
-	req := fmt.Sprintf("%s/update/", hs.baseURL)
+	hs.batch = hs.batch[:0]
+
+	req := fmt.Sprintf("%s/updates/", hs.baseURL)
 
 	return hs.doSend(req, data)
 }
 
-func SendMetrics(serverAddress string, timeout time.Duration, stats metric.Metrics) error {
-	sender := NewSender(serverAddress, timeout)
-
-	// отправляем метрики пакета runtime
-	sender.
-		exportGauge("Alloc", stats.Memory.Alloc).
-		exportGauge("BuckHashSys", stats.Memory.BuckHashSys).
-		exportGauge("Frees", stats.Memory.Frees).
-		exportGauge("GCCPUFraction", stats.Memory.GCCPUFraction).
-		exportGauge("GCSys", stats.Memory.GCSys).
-		exportGauge("HeapAlloc", stats.Memory.HeapAlloc).
-		exportGauge("HeapIdle", stats.Memory.HeapIdle).
-		exportGauge("HeapInuse", stats.Memory.HeapInuse).
-		exportGauge("HeapObjects", stats.Memory.HeapObjects).
-		exportGauge("HeapReleased", stats.Memory.HeapReleased).
-		exportGauge("HeapSys", stats.Memory.HeapSys).
-		exportGauge("LastGC", stats.Memory.LastGC).
-		exportGauge("Lookups", stats.Memory.Lookups).
-		exportGauge("MCacheInuse", stats.Memory.MCacheInuse).
-		exportGauge("MCacheSys", stats.Memory.MCacheSys).
-		exportGauge("MSpanInuse", stats.Memory.MSpanInuse).
-		exportGauge("MSpanSys", stats.Memory.MSpanSys).
-		exportGauge("Mallocs", stats.Memory.Mallocs).
-		exportGauge("NextGC", stats.Memory.NextGC).
-		exportGauge("NumForcedGC", stats.Memory.NumForcedGC).
-		exportGauge("NumGC", stats.Memory.NumGC).
-		exportGauge("OtherSys", stats.Memory.OtherSys).
-		exportGauge("PauseTotalNs", stats.Memory.PauseTotalNs).
-		exportGauge("StackInuse", stats.Memory.StackInuse).
-		exportGauge("StackSys", stats.Memory.StackSys).
-		exportGauge("Sys", stats.Memory.Sys).
-		exportGauge("TotalAlloc", stats.Memory.TotalAlloc)
-
-	// отправляем обновляемое произвольное значение
-	sender.exportGauge("RandomValue", stats.RandomValue)
-	// отправляем счётчик обновления метрик пакета runtime
-	sender.exportCounter("PollCount", stats.PollCount)
-
-	return sender.err
+func (hs *httpSender) exportMetrics(metrics []metric.NamedMetric) *httpSender {
+	for _, requestMetric := range adapter.NewUpdateRequestMetrics(metrics) {
+		hs.export(requestMetric)
+	}
+
+	return hs
+}
+
+// SendMetrics - отправляет метрики по одной на /update/.
+func (hs httpSender) SendMetrics(metrics []metric.NamedMetric) error {
+	hs.err = nil
+	hs.batch = nil
+
+	return hs.exportMetrics(metrics).err
+}
+
+// SendMetricsBatch - отправляет все метрики одним запросом на /updates/.
+func (hs httpSender) SendMetricsBatch(metrics []metric.NamedMetric) error {
+	hs.err = nil
+	hs.batch = make([]adapter.RequestMetric, 0)
+
+	return hs.exportMetrics(metrics).flush().err
 }
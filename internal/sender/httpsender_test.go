@@ -0,0 +1,83 @@
+package sender
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+)
+
+func TestHTTPSenderRetry(t *testing.T) {
+	metrics := []metric.NamedMetric{{Name: "Alloc", Value: metric.Gauge(1.5)}}
+	backoff := retry.New(time.Millisecond, time.Millisecond)
+
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		// retriable - ошибка после исчерпания попыток допускает отправку позже.
+		retriable bool
+		attempts  int32
+	}{
+		{
+			name:     "retry unavailable server",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			attempts: 3,
+		},
+		{
+			name:      "attempts exhausted",
+			statuses:  []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+			wantErr:   true,
+			retriable: true,
+			attempts:  3,
+		},
+		{
+			name:     "fail fast on client error",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			wantErr:  true,
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+
+			hs := NewSender(strings.TrimPrefix(server.URL, "http://"), time.Second, "", nil, backoff)
+
+			err := hs.SendMetricsBatch(metrics)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, tt.retriable, retry.IsRetriable(err))
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.attempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestHTTPSenderRetryConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	address := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	hs := NewSender(address, time.Second, "", nil, retry.New(time.Millisecond))
+
+	err := hs.SendMetrics([]metric.NamedMetric{{Name: "PollCount", Value: metric.Counter(1)}})
+	require.Error(t, err)
+	require.True(t, retry.IsNetworkError(err))
+}
@@ -0,0 +1,28 @@
+package sender
+
+import (
+	"context"
+
+	"github.com/a-x-a/go-metric/internal/retry"
+)
+
+// withRetry - выполняет отправку send с повторными попытками политики backoff.
+// Если попытки исчерпаны на ошибке, допускающей повторную попытку, то она
+// возвращается с пометкой retry.Retriable: очередь неотправленных метрик
+// оставляет такой снимок до следующей отправки, а остальные ошибки считает
+// окончательными.
+func withRetry(backoff retry.Backoff, send func() error) error {
+	var transient bool
+
+	err := backoff.Do(context.Background(), func() error {
+		err := send()
+		transient = retry.IsRetriable(err)
+
+		return err
+	})
+	if err != nil && transient {
+		return retry.Retriable(err)
+	}
+
+	return err
+}
@@ -0,0 +1,152 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+	"github.com/a-x-a/go-metric/internal/signer"
+)
+
+type wsSender struct {
+	url     string
+	dialer  *websocket.Dialer
+	timeout time.Duration
+	// key - ключ подписи сообщений, если пустой, то сообщения не подписываются.
+	key string
+	// backoff - политика повторных попыток отправки.
+	backoff retry.Backoff
+
+	// mu - соединение используется отправителями по очереди:
+	// подтверждения приходят в порядке отправки сообщений.
+	mu   sync.Mutex
+	conn *websocket.Conn
+	seq  uint64
+}
+
+// NewWSSender - создаёт отправителя метрик через постоянное соединение WebSocket
+// с сервером serverAddress. Соединение устанавливается при первой отправке
+// и устанавливается заново после любой ошибки соединения.
+func NewWSSender(serverAddress string, timeout time.Duration, key string, backoff retry.Backoff) *wsSender {
+	return &wsSender{
+		url:     fmt.Sprintf("ws://%s/ws", serverAddress),
+		dialer:  &websocket.Dialer{HandshakeTimeout: timeout},
+		timeout: timeout,
+		key:     key,
+		backoff: backoff,
+	}
+}
+
+// SendMetrics - отправляет метрики по одной в отдельных сообщениях.
+func (ws *wsSender) SendMetrics(metrics []metric.NamedMetric) error {
+	for _, requestMetric := range adapter.NewUpdateRequestMetrics(metrics) {
+		if err := ws.sendFrame([]adapter.RequestMetric{requestMetric}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendMetricsBatch - отправляет все метрики одним сообщением.
+func (ws *wsSender) SendMetricsBatch(metrics []metric.NamedMetric) error {
+	requestMetrics := adapter.NewUpdateRequestMetrics(metrics)
+	if len(requestMetrics) == 0 {
+		return nil
+	}
+
+	return ws.sendFrame(requestMetrics)
+}
+
+// Close - закрывает соединение с сервером.
+func (ws *wsSender) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.conn == nil {
+		return nil
+	}
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	ws.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(ws.timeout))
+
+	return ws.reset()
+}
+
+func (ws *wsSender) sendFrame(requestMetrics []adapter.RequestMetric) error {
+	data, err := json.Marshal(requestMetrics)
+	if err != nil {
+		return err
+	}
+
+	return withRetry(ws.backoff, func() error {
+		return ws.send(data)
+	})
+}
+
+// send - выполняет одну попытку отправки сообщения и ожидает его подтверждения.
+// Ошибки соединения и коды 502, 503 и 504 допускают повторную попытку.
+func (ws *wsSender) send(data []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.conn == nil {
+		conn, _, err := ws.dialer.Dial(ws.url, nil)
+		if err != nil {
+			return retry.Retriable(err)
+		}
+
+		ws.conn = conn
+	}
+
+	ws.seq++
+
+	frame := adapter.Frame{Seq: ws.seq, Metrics: data}
+	if len(ws.key) > 0 {
+		frame.Hash = signer.Hash(data, ws.key)
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(ws.timeout))
+
+	if err := ws.conn.WriteJSON(frame); err != nil {
+		ws.reset()
+		return retry.Retriable(err)
+	}
+
+	ws.conn.SetReadDeadline(time.Now().Add(ws.timeout))
+
+	var ack adapter.Ack
+	if err := ws.conn.ReadJSON(&ack); err != nil {
+		ws.reset()
+		return retry.Retriable(err)
+	}
+
+	if ack.Seq != frame.Seq {
+		ws.reset()
+		return retry.Retriable(fmt.Errorf("unexpected ack %d for frame %d", ack.Seq, frame.Seq))
+	}
+
+	switch ack.Code {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return retry.Retriable(fmt.Errorf("metrics send failed: (%d) %s", ack.Code, ack.Error))
+	}
+
+	return fmt.Errorf("metrics send failed: (%d) %s", ack.Code, ack.Error)
+}
+
+// reset - закрывает соединение, следующая отправка устанавливает новое.
+func (ws *wsSender) reset() error {
+	err := ws.conn.Close()
+	ws.conn = nil
+
+	return err
+}
@@ -0,0 +1,99 @@
+package sender
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/a-x-a/go-metric/internal/adapter"
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+	"github.com/a-x-a/go-metric/internal/signer"
+)
+
+func TestWSSenderReconnect(t *testing.T) {
+	const key = "secret"
+
+	var connections, frames int32
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// первое соединение обрывается, не подтвердив сообщение.
+		first := atomic.AddInt32(&connections, 1) == 1
+
+		for {
+			var frame adapter.Frame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+
+			atomic.AddInt32(&frames, 1)
+
+			if first {
+				return
+			}
+
+			ack := adapter.Ack{Seq: frame.Seq, Code: http.StatusOK}
+			if err := signer.Verify(frame.Metrics, key, frame.Hash); err != nil {
+				ack.Code = http.StatusBadRequest
+			}
+
+			if strings.Contains(string(frame.Metrics), "Invalid") {
+				ack.Code = http.StatusBadRequest
+			}
+
+			if err := conn.WriteJSON(ack); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	ws := NewWSSender(strings.TrimPrefix(server.URL, "http://"), time.Second, key, retry.New(time.Millisecond))
+	defer ws.Close()
+
+	require.NoError(t, ws.SendMetricsBatch([]metric.NamedMetric{
+		{Name: "Alloc", Value: metric.Gauge(1.5)},
+		{Name: "PollCount", Value: metric.Counter(1)},
+	}))
+	require.Equal(t, int32(2), atomic.LoadInt32(&connections))
+	require.Equal(t, int32(2), atomic.LoadInt32(&frames))
+
+	// соединение используется повторно, метрики отправляются по одной.
+	require.NoError(t, ws.SendMetrics([]metric.NamedMetric{
+		{Name: "Alloc", Value: metric.Gauge(2.5)},
+		{Name: "PollCount", Value: metric.Counter(2)},
+	}))
+	require.Equal(t, int32(2), atomic.LoadInt32(&connections))
+	require.Equal(t, int32(4), atomic.LoadInt32(&frames))
+
+	// ошибка в сообщении не повторяется.
+	err := ws.SendMetricsBatch([]metric.NamedMetric{{Name: "Invalid", Value: metric.Gauge(1)}})
+	require.Error(t, err)
+	require.False(t, retry.IsNetworkError(err))
+	require.Equal(t, int32(5), atomic.LoadInt32(&frames))
+}
+
+func TestWSSenderConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	address := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	ws := NewWSSender(address, time.Second, "", retry.New(time.Millisecond))
+
+	err := ws.SendMetrics([]metric.NamedMetric{{Name: "PollCount", Value: metric.Counter(1)}})
+	require.Error(t, err)
+	require.True(t, retry.IsNetworkError(err))
+}
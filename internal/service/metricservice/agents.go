@@ -0,0 +1,61 @@
+package metricservice
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+type (
+	// AgentInfo - агент, отправлявший метрики на сервер.
+	AgentInfo struct {
+		ID       string
+		LastSeen time.Time
+	}
+
+	// agentRegistry - время последнего получения метрик от каждого агента.
+	// Агент определяется по метке instance полученных метрик.
+	agentRegistry struct {
+		sync.Mutex
+		lastSeen map[string]time.Time
+		now      func() time.Time
+	}
+)
+
+func newAgentRegistry() *agentRegistry {
+	return &agentRegistry{
+		lastSeen: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// seen - отмечает получение метрик records от агентов.
+func (r *agentRegistry) seen(records []storage.Record) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	for _, record := range records {
+		if id, ok := record.GetLabels()[metric.InstanceLabel]; ok {
+			r.lastSeen[id] = now
+		}
+	}
+}
+
+// list - возвращает агентов в порядке возрастания идентификатора.
+func (r *agentRegistry) list() []AgentInfo {
+	r.Lock()
+	defer r.Unlock()
+
+	agents := make([]AgentInfo, 0, len(r.lastSeen))
+	for id, lastSeen := range r.lastSeen {
+		agents = append(agents, AgentInfo{ID: id, LastSeen: lastSeen})
+	}
+
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+
+	return agents
+}
@@ -0,0 +1,115 @@
+package metricservice
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+type (
+	// Subscription - подписка на обновления метрик, принятые сервисом.
+	// Обновления, не прочитанные подписчиком, накапливаются в буфере
+	// размером SubscriptionBuffer, при переполнении буфера новые
+	// обновления отбрасываются и учитываются в Dropped.
+	Subscription struct {
+		updates chan storage.Record
+		match   func(storage.Record) bool
+		dropped atomic.Uint64
+		broker  *broker
+		once    sync.Once
+	}
+
+	// broker - рассылает обновления метрик подписчикам.
+	broker struct {
+		sync.RWMutex
+		subscribers map[*Subscription]struct{}
+	}
+)
+
+const (
+	// SubscriptionBuffer - количество обновлений, ожидающих чтения подписчиком.
+	SubscriptionBuffer = 256
+)
+
+func newBroker() *broker {
+	return &broker{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe - подписывает на обновления метрик, удовлетворяющих условиям отбора q.
+// Параметры страницы Limit и Cursor не используются.
+// Подписку необходимо закрыть методом Close.
+func (s metricService) Subscribe(q Query) (*Subscription, error) {
+	q.Limit, q.Cursor = 0, ""
+
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.broker.subscribe(match), nil
+}
+
+// Updates - возвращает канал обновлений метрик, закрываемый при закрытии подписки.
+func (sub *Subscription) Updates() <-chan storage.Record {
+	return sub.updates
+}
+
+// Dropped - возвращает количество обновлений, отброшенных из-за переполнения
+// буфера с момента предыдущего вызова.
+func (sub *Subscription) Dropped() uint64 {
+	return sub.dropped.Swap(0)
+}
+
+// Close - закрывает подписку.
+func (sub *Subscription) Close() {
+	sub.once.Do(func() {
+		sub.broker.unsubscribe(sub)
+	})
+}
+
+func (b *broker) subscribe(match func(storage.Record) bool) *Subscription {
+	sub := &Subscription{
+		updates: make(chan storage.Record, SubscriptionBuffer),
+		match:   match,
+		broker:  b,
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+func (b *broker) unsubscribe(sub *Subscription) {
+	b.Lock()
+	defer b.Unlock()
+
+	delete(b.subscribers, sub)
+	close(sub.updates)
+}
+
+// publish - передаёт обновления records подписчикам без ожидания:
+// медленный подписчик не задерживает сохранение метрик.
+func (b *broker) publish(records []storage.Record) {
+	b.RLock()
+	defer b.RUnlock()
+
+	for sub := range b.subscribers {
+		for _, record := range records {
+			if !sub.match(record) {
+				continue
+			}
+
+			select {
+			case sub.updates <- record:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package metricservice
 
 import (
+	"errors"
+	"time"
+
 	"go.uber.org/zap"
 
 	"github.com/a-x-a/go-metric/internal/models/metric"
@@ -11,16 +14,31 @@ type (
 	metricService struct {
 		storage storage.Storage
 		logger  *zap.Logger
+		agents  *agentRegistry
+		broker  *broker
+	}
+
+	historyStorage interface {
+		History(name string, from, to time.Time) ([]storage.Sample, bool)
 	}
 )
 
+var (
+	// ErrHistoryNotSupported - хранилище не сохраняет историю значений метрик.
+	ErrHistoryNotSupported = errors.New("metricservice: storage doesn't keep metrics history")
+)
+
 func New(stor storage.Storage, logger *zap.Logger) *metricService {
 	return &metricService{
 		storage: stor,
 		logger:  logger,
+		agents:  newAgentRegistry(),
+		broker:  newBroker(),
 	}
 }
 
+// Push - сохраняет значение value метрики типа kind с именем name.
+// Для set значение value - элемент, добавляемый в множество.
 func (s *metricService) Push(name, kind, value string) error {
 	metricKind, err := metric.GetKind(kind)
 	if err != nil {
@@ -44,17 +62,23 @@ func (s *metricService) Push(name, kind, value string) error {
 		if err != nil {
 			return err
 		}
-		if v, ok := s.storage.Get(name); ok {
-			if oldVal, ok := v.GetValue().(metric.Counter); ok {
-				val += oldVal
-			}
-		}
+		record.SetValue(val)
+	case metric.KindSet:
+		val := metric.Set{}
+		val.Add(value)
 		record.SetValue(val)
 	default:
 		return metric.ErrorInvalidMetricKind
 	}
 
-	return s.storage.Push(name, record)
+	records, err := s.storage.Update([]storage.Record{record})
+	if err != nil {
+		return err
+	}
+
+	s.broker.publish(records)
+
+	return nil
 }
 
 func (s *metricService) PushCounter(name string, value metric.Counter) (metric.Counter, error) {
@@ -63,14 +87,20 @@ func (s *metricService) PushCounter(name string, value metric.Counter) (metric.C
 		return 0, err
 	}
 
-	if v, ok := s.storage.Get(name); ok {
-		if oldVal, ok := v.GetValue().(metric.Counter); ok {
-			value += oldVal
-		}
-	}
 	record.SetValue(value)
 
-	return value, s.storage.Push(name, record)
+	records, err := s.storage.Update([]storage.Record{record})
+	if err != nil {
+		return 0, err
+	}
+
+	s.broker.publish(records)
+
+	if v, ok := records[0].GetValue().(metric.Counter); ok {
+		value = v
+	}
+
+	return value, nil
 }
 
 func (s *metricService) PushGauge(name string, value metric.Gauge) (metric.Gauge, error) {
@@ -81,22 +111,91 @@ func (s *metricService) PushGauge(name string, value metric.Gauge) (metric.Gauge
 
 	record.SetValue(value)
 
-	err = s.storage.Push(name, record)
+	// Update, в отличие от Push, сохраняет срок хранения, заданный метрике ранее.
+	records, err := s.storage.Update([]storage.Record{record})
 	if err != nil {
 		return 0, err
 	}
 
+	s.broker.publish(records)
+
 	return value, nil
 }
 
+// PushBatch - атомарно сохраняет набор метрик одной операцией хранилища.
+// Значения счётчиков суммируются с сохранёнными ранее и между собой внутри набора,
+// значения histogram, summary, sketch и set объединяются по правилам metric.Merge.
+// Возвращает итоговые значения метрик в порядке их следования в наборе.
+func (s *metricService) PushBatch(records []storage.Record) ([]storage.Record, error) {
+	for _, record := range records {
+		if err := storage.ValidateName(record.GetName()); err != nil {
+			return nil, err
+		}
+
+		if err := record.GetLabels().Validate(); err != nil {
+			return nil, err
+		}
+
+		switch v := record.GetValue().(type) {
+		case metric.Gauge, metric.Counter:
+		case metric.Histogram:
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		case metric.Summary:
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		case metric.Sketch:
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		case metric.Set:
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, metric.ErrorInvalidMetricKind
+		}
+	}
+
+	result, err := s.storage.Update(records)
+	if err != nil {
+		return nil, err
+	}
+
+	s.agents.seen(result)
+	s.broker.publish(result)
+
+	return result, nil
+}
+
+// Agents - возвращает агентов, отправлявших метрики с меткой instance
+// с момента запуска сервера, и время последнего получения метрик от них.
+func (s metricService) Agents() []AgentInfo {
+	return s.agents.list()
+}
+
 func (s metricService) Get(name, kind string) (string, error) {
+	return s.GetLabeled(name, kind, nil)
+}
+
+// GetLabeled - возвращает значение метрики с именем name и метками labels.
+// Если метки не указаны и метрики без меток нет, то возвращается значение
+// единственной метрики типа kind с именем name, например отправленной агентом
+// с метками instance и host. Если таких метрик несколько, то возвращается
+// ошибка metric.ErrorAmbiguousMetric.
+func (s metricService) GetLabeled(name, kind string, labels metric.Labels) (string, error) {
 	if _, err := metric.GetKind(kind); err != nil {
 		return "", err
 	}
 
-	record, ok := s.storage.Get(name)
+	record, ok := s.storage.Get(metric.Key(name, labels))
 	if !ok {
-		return "", metric.ErrorMetricNotFound
+		var err error
+		if record, err = s.resolve(name, kind, labels); err != nil {
+			return "", err
+		}
 	}
 
 	value := record.GetValue().String()
@@ -109,3 +208,142 @@ func (s metricService) GetAll() []storage.Record {
 
 	return records
 }
+
+// GetRecord - возвращает метрику типа kind с именем name и метками labels.
+// Метрика без меток ищется так же, как в GetLabeled.
+func (s metricService) GetRecord(name, kind string, labels metric.Labels) (storage.Record, error) {
+	record, err := s.record(name, kind, labels)
+	if errors.Is(err, metric.ErrorMetricNotFound) {
+		return s.resolve(name, kind, labels)
+	}
+
+	return record, err
+}
+
+// record - возвращает метрику типа kind точно с именем name и метками labels.
+func (s metricService) record(name, kind string, labels metric.Labels) (storage.Record, error) {
+	if _, err := metric.GetKind(kind); err != nil {
+		return storage.Record{}, err
+	}
+
+	record, ok := s.storage.Get(metric.Key(name, labels))
+	if !ok || record.GetValue() == nil || record.GetValue().Kind() != kind {
+		return storage.Record{}, metric.ErrorMetricNotFound
+	}
+
+	return record, nil
+}
+
+// resolve - возвращает единственную метрику типа kind с именем name и любыми
+// метками, если метки labels не указаны.
+func (s metricService) resolve(name, kind string, labels metric.Labels) (storage.Record, error) {
+	if len(labels) > 0 {
+		return storage.Record{}, metric.ErrorMetricNotFound
+	}
+
+	var (
+		found storage.Record
+		count int
+	)
+
+	for _, record := range s.storage.GetAll() {
+		if record.GetName() != name || record.GetValue() == nil || record.GetValue().Kind() != kind {
+			continue
+		}
+
+		found = record
+		count++
+	}
+
+	switch count {
+	case 0:
+		return storage.Record{}, metric.ErrorMetricNotFound
+	case 1:
+		return found, nil
+	}
+
+	return storage.Record{}, metric.ErrorAmbiguousMetric
+}
+
+// Delete - удаляет метрику типа kind точно с именем name и метками labels
+// и возвращает её последнее значение.
+func (s metricService) Delete(name, kind string, labels metric.Labels) (storage.Record, error) {
+	record, err := s.record(name, kind, labels)
+	if err != nil {
+		return storage.Record{}, err
+	}
+
+	deleted, err := s.storage.Delete(record.Key())
+	if err != nil {
+		return storage.Record{}, err
+	}
+
+	// метрика удалена параллельным запросом.
+	if len(deleted) == 0 {
+		return storage.Record{}, metric.ErrorMetricNotFound
+	}
+
+	return deleted[0], nil
+}
+
+// Quantile - возвращает оценку квантиля уровня q эскиза sketch с именем name и метками labels.
+func (s metricService) Quantile(name string, labels metric.Labels, q float64) (float64, error) {
+	record, err := s.GetRecord(name, string(metric.KindSketch), labels)
+	if err != nil {
+		return 0, err
+	}
+
+	return record.GetValue().(metric.Sketch).Quantile(q)
+}
+
+// Select - возвращает метрики, удовлетворяющие всем условиям отбора matchers.
+func (s metricService) Select(matchers []metric.Matcher) []storage.Record {
+	records := s.storage.GetAll()
+
+	result := make([]storage.Record, 0, len(records))
+	for _, record := range records {
+		if metric.MatchLabels(matchers, record.GetName(), record.GetLabels()) {
+			result = append(result, record)
+		}
+	}
+
+	return result
+}
+
+// History - возвращает историю значений метрики за период [from, to].
+func (s metricService) History(name, kind string, from, to time.Time) ([]storage.Sample, error) {
+	return s.HistoryLabeled(name, kind, nil, from, to)
+}
+
+// HistoryLabeled - возвращает историю значений метрики с именем name
+// и метками labels за период [from, to]. Метрика без меток ищется так же,
+// как в GetLabeled.
+func (s metricService) HistoryLabeled(name, kind string, labels metric.Labels, from, to time.Time) ([]storage.Sample, error) {
+	if _, err := metric.GetKind(kind); err != nil {
+		return nil, err
+	}
+
+	hs, ok := s.storage.(historyStorage)
+	if !ok {
+		return nil, ErrHistoryNotSupported
+	}
+
+	record, err := s.GetRecord(name, kind, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	samples, ok := hs.History(record.Key(), from, to)
+	if !ok {
+		return nil, metric.ErrorMetricNotFound
+	}
+
+	result := make([]storage.Sample, 0, len(samples))
+	for _, v := range samples {
+		if v.Value.Kind() == kind {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,507 @@
+package metricservice
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+func Test_metricServiceConcurrentCounters(t *testing.T) {
+	const (
+		workers    = 64
+		increments = 500
+	)
+
+	fileName := os.TempDir() + string(os.PathSeparator) + "test_concurrent_123456789.json"
+	defer os.Remove(fileName)
+
+	tests := []struct {
+		name    string
+		storage storage.Storage
+	}{
+		{
+			name:    "memory storage",
+			storage: storage.NewMemStorage(),
+		},
+		{
+			name:    "file storage",
+			storage: storage.NewWithFileStorage(fileName, false, zap.NewNop()),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			s := New(tt.storage, zap.NewNop())
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < increments; j++ {
+						var err error
+						switch (i + j) % 3 {
+						case 0:
+							err = s.Push("PollCount", "counter", "1")
+						case 1:
+							_, err = s.PushCounter("PollCount", 1)
+						default:
+							record, _ := storage.NewRecord("PollCount")
+							record.SetValue(metric.Counter(1))
+							_, err = s.PushBatch([]storage.Record{record})
+						}
+						require.NoError(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			value, err := s.Get("PollCount", "counter")
+			require.NoError(err)
+			require.Equal(metric.Counter(workers*increments).String(), value)
+		})
+	}
+}
+
+func Test_metricServicePushKeepsTTL(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	for _, value := range []metric.Metric{metric.Gauge(1.5), metric.Counter(1)} {
+		record, _ := storage.NewRecord(value.Kind())
+		record.SetValue(value)
+		require.NoError(record.SetTTL(time.Minute))
+		_, err := s.PushBatch([]storage.Record{record})
+		require.NoError(err)
+	}
+
+	// обновление без срока хранения не сбрасывает сохранённый срок.
+	_, err := s.PushGauge("gauge", 2.5)
+	require.NoError(err)
+	_, err = s.PushCounter("counter", 2)
+	require.NoError(err)
+
+	for _, kind := range []string{"gauge", "counter"} {
+		record, err := s.GetRecord(kind, kind, nil)
+		require.NoError(err)
+		require.Equal(time.Minute, record.GetTTL())
+	}
+}
+
+func Test_metricServiceRejectsLabelsInName(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	labeled, _ := storage.NewRecord("foo")
+	labeled.SetValue(metric.Gauge(1))
+	labeled.SetLabels(metric.Labels{"x": "1"})
+	_, err := s.PushBatch([]storage.Record{labeled})
+	require.NoError(err)
+
+	// имя в синтаксисе меток совпало бы с ключом серии foo{x="1"}.
+	name := `foo{x="1"}`
+	require.ErrorIs(s.Push(name, "gauge", "2"), storage.ErrInvalidName)
+	_, err = s.PushGauge(name, 2)
+	require.ErrorIs(err, storage.ErrInvalidName)
+	_, err = s.PushCounter(name, 2)
+	require.ErrorIs(err, storage.ErrInvalidName)
+
+	record, err := s.GetRecord("foo", "gauge", metric.Labels{"x": "1"})
+	require.NoError(err)
+	require.Equal(metric.Gauge(1), record.GetValue())
+}
+
+func Test_metricServicePushBatch(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	counter, _ := storage.NewRecord("PollCount")
+	counter.SetValue(metric.Counter(2))
+	gauge, _ := storage.NewRecord("Alloc")
+	gauge.SetValue(metric.Gauge(1.5))
+
+	got, err := s.PushBatch([]storage.Record{counter, gauge, counter})
+	require.NoError(err)
+	require.Len(got, 3)
+	require.Equal(metric.Counter(2), got[0].GetValue())
+	require.Equal(metric.Gauge(1.5), got[1].GetValue())
+	require.Equal(metric.Counter(4), got[2].GetValue())
+
+	empty, _ := storage.NewRecord("Empty")
+	_, err = s.PushBatch([]storage.Record{empty})
+	require.ErrorIs(err, metric.ErrorInvalidMetricKind)
+
+	histogram, _ := storage.NewRecord("Latency")
+	histogram.SetValue(metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{1, 1}, Sum: 2, Count: 2})
+
+	got, err = s.PushBatch([]storage.Record{histogram, histogram})
+	require.NoError(err)
+	require.Equal(metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{2, 2}, Sum: 4, Count: 4}, got[1].GetValue())
+
+	histogram.SetValue(metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{1}, Count: 1})
+	_, err = s.PushBatch([]storage.Record{histogram})
+	require.ErrorIs(err, metric.ErrorInvalidHistogram)
+
+	summary, _ := storage.NewRecord("Duration")
+	summary.SetValue(metric.Summary{Quantiles: []metric.Quantile{{Quantile: -1}}})
+	_, err = s.PushBatch([]storage.Record{summary})
+	require.ErrorIs(err, metric.ErrorInvalidSummary)
+
+	sketch, _ := storage.NewRecord("Size")
+	sketch.SetValue(metric.Sketch{Positive: metric.SketchBins{0: 1}, Count: 1, Min: 1, Max: 1})
+	_, err = s.PushBatch([]storage.Record{sketch})
+	require.ErrorIs(err, metric.ErrorInvalidSketch)
+}
+
+func Test_metricServiceDelete(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	record, _ := storage.NewRecord("Requests")
+	record.SetLabels(metric.Labels{"host": "a"})
+	record.SetValue(metric.Counter(3))
+
+	_, err := s.PushBatch([]storage.Record{record})
+	require.NoError(err)
+
+	_, err = s.Delete("Requests", "gauge", metric.Labels{"host": "a"})
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+
+	deleted, err := s.Delete("Requests", "counter", metric.Labels{"host": "a"})
+	require.NoError(err)
+	require.Equal(metric.Counter(3), deleted.GetValue())
+
+	_, err = s.Delete("Requests", "counter", metric.Labels{"host": "a"})
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+	require.Empty(s.GetAll())
+}
+
+func Test_metricServiceSet(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	for _, member := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		require.NoError(s.Push("Visitors", "set", member))
+	}
+
+	value, err := s.Get("Visitors", "set")
+	require.NoError(err)
+	require.Equal("2", value)
+
+	// регистры другого сервера объединяются с сохранёнными.
+	federated := metric.Set{}
+	federated.Add("10.0.0.2")
+	federated.Add("10.0.0.3")
+
+	record, _ := storage.NewRecord("Visitors")
+	record.SetValue(federated)
+
+	got, err := s.PushBatch([]storage.Record{record})
+	require.NoError(err)
+	require.Equal(uint64(3), got[0].GetValue().(metric.Set).Estimate())
+
+	record.SetValue(metric.Set{Registers: make([]uint8, 3)})
+	_, err = s.PushBatch([]storage.Record{record})
+	require.ErrorIs(err, metric.ErrorInvalidSet)
+}
+
+func Test_metricServiceQuery(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	records := make([]storage.Record, 0)
+	for _, name := range []string{"HeapSys", "Alloc", "HeapAlloc", "HeapInuse", "PollCount"} {
+		record, _ := storage.NewRecord(name)
+		record.SetValue(metric.Gauge(1))
+		if name == "PollCount" {
+			record.SetValue(metric.Counter(1))
+		}
+
+		records = append(records, record)
+	}
+
+	_, err := s.PushBatch(records)
+	require.NoError(err)
+
+	names := func(page Page) []string {
+		result := make([]string, 0, len(page.Records))
+		for _, record := range page.Records {
+			result = append(result, record.GetName())
+		}
+
+		return result
+	}
+
+	page, err := s.Query(Query{})
+	require.NoError(err)
+	require.Equal([]string{"Alloc", "HeapAlloc", "HeapInuse", "HeapSys", "PollCount"}, names(page))
+	require.Empty(page.Next)
+
+	page, err = s.Query(Query{Kind: "counter"})
+	require.NoError(err)
+	require.Equal([]string{"PollCount"}, names(page))
+
+	page, err = s.Query(Query{Regexp: "^Heap(Alloc|Sys)$"})
+	require.NoError(err)
+	require.Equal([]string{"HeapAlloc", "HeapSys"}, names(page))
+
+	// страницы по две метрики.
+	got := make([]string, 0)
+	query := Query{Match: "Heap*", Kind: "gauge", Limit: 2}
+	for i := 0; ; i++ {
+		page, err = s.Query(query)
+		require.NoError(err)
+		got = append(got, names(page)...)
+
+		if len(page.Next) == 0 {
+			require.Equal(1, i)
+			break
+		}
+
+		query.Cursor = page.Next
+	}
+	require.Equal([]string{"HeapAlloc", "HeapInuse", "HeapSys"}, got)
+
+	_, err = s.Query(Query{Match: "Heap["})
+	require.ErrorIs(err, ErrInvalidQuery)
+
+	_, err = s.Query(Query{Regexp: "("})
+	require.ErrorIs(err, ErrInvalidQuery)
+
+	_, err = s.Query(Query{Limit: -1})
+	require.ErrorIs(err, ErrInvalidQuery)
+
+	_, err = s.Query(Query{Kind: "unknown"})
+	require.ErrorIs(err, metric.ErrorInvalidMetricKind)
+
+	_, err = s.Query(Query{Cursor: "!"})
+	require.ErrorIs(err, ErrInvalidCursor)
+}
+
+func Test_metricServiceSubscribe(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	all, err := s.Subscribe(Query{})
+	require.NoError(err)
+	defer all.Close()
+
+	heap, err := s.Subscribe(Query{Match: "Heap*", Kind: "gauge"})
+	require.NoError(err)
+	defer heap.Close()
+
+	_, err = s.Subscribe(Query{Regexp: "("})
+	require.ErrorIs(err, ErrInvalidQuery)
+
+	_, err = s.PushGauge("HeapAlloc", 1.5)
+	require.NoError(err)
+	require.NoError(s.Push("PollCount", "counter", "2"))
+	_, err = s.PushCounter("PollCount", 3)
+	require.NoError(err)
+
+	record, _ := storage.NewRecord("HeapInuse")
+	record.SetValue(metric.Gauge(2.5))
+	_, err = s.PushBatch([]storage.Record{record})
+	require.NoError(err)
+
+	got := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		update := <-all.Updates()
+		got = append(got, update.GetName()+"="+update.GetValue().String())
+	}
+	require.Equal([]string{"HeapAlloc=1.5", "PollCount=2", "PollCount=5", "HeapInuse=2.5"}, got)
+
+	for _, name := range []string{"HeapAlloc", "HeapInuse"} {
+		update := <-heap.Updates()
+		require.Equal(name, update.GetName())
+	}
+	require.Empty(heap.Updates())
+
+	// медленный подписчик не задерживает обновления, лишние отбрасываются.
+	for i := 0; i < SubscriptionBuffer+10; i++ {
+		_, err = s.PushGauge("HeapAlloc", metric.Gauge(i))
+		require.NoError(err)
+	}
+	require.Len(heap.Updates(), SubscriptionBuffer)
+	require.Equal(uint64(10), heap.Dropped())
+	require.Equal(uint64(0), heap.Dropped())
+
+	heap.Close()
+	heap.Close()
+
+	for range heap.Updates() {
+	}
+
+	_, err = s.PushGauge("HeapAlloc", 1)
+	require.NoError(err)
+}
+
+func Test_metricServiceQuantile(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	// эскизы агентов с разными диапазонами значений объединяются на сервере.
+	for _, scale := range []float64{0.001, 1000} {
+		value, err := metric.NewSketch(metric.DefaultSketchAccuracy)
+		require.NoError(err)
+
+		for i := 1; i <= 100; i++ {
+			value.Add(float64(i) * scale)
+		}
+
+		record, _ := storage.NewRecord("Size")
+		record.SetValue(value)
+
+		_, err = s.PushBatch([]storage.Record{record})
+		require.NoError(err)
+	}
+
+	got, err := s.Quantile("Size", nil, 0.25)
+	require.NoError(err)
+	require.InEpsilon(0.05, got, metric.DefaultSketchAccuracy)
+
+	got, err = s.Quantile("Size", nil, 0.99)
+	require.NoError(err)
+	require.InEpsilon(98000, got, metric.DefaultSketchAccuracy)
+
+	_, err = s.Quantile("Size", nil, 2)
+	require.ErrorIs(err, metric.ErrorInvalidQuantile)
+
+	_, err = s.Quantile("Unknown", nil, 0.5)
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+}
+
+func Test_metricServiceLabels(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	a, _ := storage.NewRecord("Requests")
+	a.SetLabels(metric.Labels{"host": "a"})
+	a.SetValue(metric.Counter(1))
+	b, _ := storage.NewRecord("Requests")
+	b.SetLabels(metric.Labels{"host": "b"})
+	b.SetValue(metric.Counter(2))
+	alloc, _ := storage.NewRecord("Alloc")
+	alloc.SetLabels(metric.Labels{"host": "a"})
+	alloc.SetValue(metric.Gauge(1.5))
+
+	_, err := s.PushBatch([]storage.Record{a, b, alloc})
+	require.NoError(err)
+
+	value, err := s.GetLabeled("Requests", "counter", metric.Labels{"host": "b"})
+	require.NoError(err)
+	require.Equal("2", value)
+
+	// без меток возвращается единственная метрика с таким именем и типом.
+	_, err = s.Get("Requests", "counter")
+	require.ErrorIs(err, metric.ErrorAmbiguousMetric)
+
+	value, err = s.Get("Alloc", "gauge")
+	require.NoError(err)
+	require.Equal("1.5", value)
+
+	record, err := s.GetRecord("Alloc", "gauge", nil)
+	require.NoError(err)
+	require.Equal(alloc, record)
+
+	_, err = s.Get("Alloc", "counter")
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+
+	_, err = s.GetLabeled("Alloc", "gauge", metric.Labels{"host": "b"})
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+
+	// удаляется только метрика точно с указанными метками.
+	_, err = s.Delete("Alloc", "gauge", nil)
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+
+	matchers, err := metric.ParseSelector(`{host="a"}`)
+	require.NoError(err)
+	require.ElementsMatch([]storage.Record{a, alloc}, s.Select(matchers))
+
+	invalid, _ := storage.NewRecord("Requests")
+	invalid.SetLabels(metric.Labels{"__name__": "x"})
+	invalid.SetValue(metric.Counter(1))
+
+	_, err = s.PushBatch([]storage.Record{invalid})
+	require.ErrorIs(err, metric.ErrorInvalidLabel)
+}
+
+func Test_metricServiceHistory(t *testing.T) {
+	require := require.New(t)
+
+	s := New(storage.NewMemStorage(), zap.NewNop())
+	_, err := s.History("Alloc", "gauge", time.Time{}, time.Now())
+	require.ErrorIs(err, ErrHistoryNotSupported)
+
+	s = New(storage.NewWithHistoryStorage(storage.NewMemStorage(), time.Hour, 10), zap.NewNop())
+	_, err = s.PushGauge("Alloc", 1.5)
+	require.NoError(err)
+	_, err = s.PushGauge("Alloc", 2.5)
+	require.NoError(err)
+
+	samples, err := s.History("Alloc", "gauge", time.Time{}, time.Now())
+	require.NoError(err)
+	require.Len(samples, 2)
+	require.Equal(metric.Gauge(2.5), samples[1].Value)
+
+	_, err = s.History("Alloc", "counter", time.Time{}, time.Now())
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+
+	_, err = s.History("Alloc", "unknown", time.Time{}, time.Now())
+	require.ErrorIs(err, metric.ErrorInvalidMetricKind)
+
+	record, _ := storage.NewRecord("Requests")
+	record.SetLabels(metric.Labels{"host": "a"})
+	record.SetValue(metric.Counter(2))
+	_, err = s.PushBatch([]storage.Record{record})
+	require.NoError(err)
+
+	samples, err = s.HistoryLabeled("Requests", "counter", metric.Labels{"host": "a"}, time.Time{}, time.Now())
+	require.NoError(err)
+	require.Len(samples, 1)
+
+	_, err = s.HistoryLabeled("Requests", "counter", metric.Labels{"host": "b"}, time.Time{}, time.Now())
+	require.ErrorIs(err, metric.ErrorMetricNotFound)
+}
+
+func Test_metricServiceAgents(t *testing.T) {
+	require := require.New(t)
+	s := New(storage.NewMemStorage(), zap.NewNop())
+
+	now := time.Unix(100, 0)
+	s.agents.now = func() time.Time { return now }
+
+	push := func(instance string) {
+		record, _ := storage.NewRecord("HeapAlloc")
+		if len(instance) > 0 {
+			record.SetLabels(metric.Labels{metric.InstanceLabel: instance})
+		}
+		record.SetValue(metric.Gauge(1))
+
+		_, err := s.PushBatch([]storage.Record{record})
+		require.NoError(err)
+	}
+
+	push("b")
+	push("")
+	now = time.Unix(200, 0)
+	push("a")
+	now = time.Unix(300, 0)
+	push("b")
+
+	require.Equal([]AgentInfo{
+		{ID: "a", LastSeen: time.Unix(200, 0)},
+		{ID: "b", LastSeen: time.Unix(300, 0)},
+	}, s.Agents())
+
+	// значения агентов не перезаписывают друг друга.
+	require.Len(s.GetAll(), 3)
+}
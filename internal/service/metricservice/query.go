@@ -0,0 +1,152 @@
+package metricservice
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/storage"
+)
+
+type (
+	// Query - условия постраничного отбора метрик.
+	Query struct {
+		// Match - шаблон имени метрики в синтаксисе path.Match: `*`, `?` и `[...]`.
+		Match string
+		// Regexp - регулярное выражение, которому должно соответствовать имя метрики.
+		Regexp string
+		// Kind - тип метрики.
+		Kind string
+		// Limit - максимальное количество метрик на странице
+		// (по умолчанию DefaultQueryLimit, не больше MaxQueryLimit).
+		Limit int
+		// Cursor - курсор страницы, возвращённый с предыдущей страницей.
+		Cursor string
+	}
+
+	// Page - страница метрик в порядке возрастания их идентификаторов.
+	Page struct {
+		Records []storage.Record
+		// Next - курсор следующей страницы, пустой для последней страницы.
+		Next string
+	}
+)
+
+const (
+	// DefaultQueryLimit - количество метрик на странице по умолчанию.
+	DefaultQueryLimit = 100
+	// MaxQueryLimit - максимальное количество метрик на странице.
+	MaxQueryLimit = 1000
+)
+
+var (
+	// ErrInvalidQuery - некорректные условия отбора метрик.
+	ErrInvalidQuery = errors.New("metricservice: invalid query")
+	// ErrInvalidCursor - некорректный курсор страницы.
+	ErrInvalidCursor = errors.New("metricservice: invalid cursor")
+)
+
+// Query - возвращает страницу метрик, удовлетворяющих условиям отбора q.
+// Метрики перебираются в хранилище по порядку, начиная с курсора,
+// без чтения всех метрик.
+func (s metricService) Query(q Query) (Page, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return Page{}, err
+	}
+
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultQueryLimit
+	}
+
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+
+	page := Page{Records: make([]storage.Record, 0)}
+
+	err = s.storage.Scan(after, func(record storage.Record) bool {
+		if !match(record) {
+			return true
+		}
+
+		// следующая страница не пуста.
+		if len(page.Records) == limit {
+			page.Next = encodeCursor(page.Records[limit-1].Key())
+			return false
+		}
+
+		page.Records = append(page.Records, record)
+
+		return true
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	return page, nil
+}
+
+// matcher - проверяет условия отбора и возвращает функцию отбора метрик.
+func (q Query) matcher() (func(storage.Record) bool, error) {
+	if q.Limit < 0 {
+		return nil, fmt.Errorf("%w: limit %d", ErrInvalidQuery, q.Limit)
+	}
+
+	if len(q.Kind) > 0 {
+		if _, err := metric.GetKind(q.Kind); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := path.Match(q.Match, ""); err != nil {
+		return nil, fmt.Errorf("%w: match %q: %s", ErrInvalidQuery, q.Match, err)
+	}
+
+	var re *regexp.Regexp
+	if len(q.Regexp) > 0 {
+		var err error
+		if re, err = regexp.Compile(q.Regexp); err != nil {
+			return nil, fmt.Errorf("%w: regexp %q: %s", ErrInvalidQuery, q.Regexp, err)
+		}
+	}
+
+	return func(record storage.Record) bool {
+		value := record.GetValue()
+		if value == nil || (len(q.Kind) > 0 && value.Kind() != q.Kind) {
+			return false
+		}
+
+		if len(q.Match) > 0 {
+			if ok, _ := path.Match(q.Match, record.GetName()); !ok {
+				return false
+			}
+		}
+
+		return re == nil || re.MatchString(record.GetName())
+	}, nil
+}
+
+// encodeCursor - возвращает курсор страницы, следующей за метрикой с идентификатором key.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeCursor - возвращает идентификатор метрики, после которой начинается страница.
+func decodeCursor(cursor string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	return string(key), nil
+}
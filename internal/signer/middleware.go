@@ -0,0 +1,75 @@
+package signer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// SignMiddleware - проверяет подпись тела входящих запросов и подписывает ответы.
+// POST-запросы без подписи или с неверной подписью отклоняются с кодом 400.
+// Если ключ не задан, то запросы передаются дальше без проверки.
+func SignMiddleware(key string, logger *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(key) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hash := r.Header.Get(HeaderHash)
+			if len(hash) > 0 || r.Method == http.MethodPost {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					logger.Error("read request body", zap.Error(err))
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				r.Body.Close()
+
+				if err := Verify(body, key, hash); err != nil {
+					logger.Info("request signature mismatch", zap.String("uri", r.RequestURI))
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			sw := &signWriter{ResponseWriter: w, code: http.StatusOK}
+
+			next.ServeHTTP(sw, r)
+
+			sw.Header().Set(HeaderHash, Hash(sw.buf.Bytes(), key))
+			sw.ResponseWriter.WriteHeader(sw.code)
+
+			if _, err := sw.ResponseWriter.Write(sw.buf.Bytes()); err != nil {
+				logger.Error("write signed response", zap.Error(err))
+			}
+		})
+	}
+}
+
+// signWriter - накапливает ответ, чтобы подписать его до отправки заголовков.
+type signWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+}
+
+func (s *signWriter) Write(p []byte) (int, error) {
+	s.wroteHeader = true
+	return s.buf.Write(p)
+}
+
+func (s *signWriter) WriteHeader(statusCode int) {
+	if s.wroteHeader {
+		return
+	}
+
+	s.wroteHeader = true
+	s.code = statusCode
+}
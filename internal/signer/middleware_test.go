@@ -0,0 +1,105 @@
+package signer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSignMiddleware(t *testing.T) {
+	const key = "secret"
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+
+	data := []byte(`{"id":"Alloc","type":"gauge","value":1.5}`)
+
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		body   []byte
+		hash   string
+		code   int
+		signed bool
+	}{
+		{
+			name:   "valid signature",
+			key:    key,
+			method: http.MethodPost,
+			body:   data,
+			hash:   Hash(data, key),
+			code:   http.StatusOK,
+			signed: true,
+		},
+		{
+			name:   "mismatched signature",
+			key:    key,
+			method: http.MethodPost,
+			body:   data,
+			hash:   Hash(data, "another"),
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "missing signature",
+			key:    key,
+			method: http.MethodPost,
+			body:   data,
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "unsigned get request",
+			key:    key,
+			method: http.MethodGet,
+			code:   http.StatusOK,
+			signed: true,
+		},
+		{
+			name:   "without key",
+			method: http.MethodPost,
+			body:   data,
+			code:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/update/", bytes.NewReader(tt.body))
+			if len(tt.hash) > 0 {
+				req.Header.Set(HeaderHash, tt.hash)
+			}
+
+			w := httptest.NewRecorder()
+			SignMiddleware(tt.key, zap.NewNop())(echo).ServeHTTP(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, string(tt.body), string(body))
+
+			if tt.signed {
+				assert.NoError(t, Verify(body, tt.key, resp.Header.Get(HeaderHash)))
+			} else {
+				assert.Empty(t, resp.Header.Get(HeaderHash))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// HeaderHash - заголовок с подписью тела запроса или ответа.
+	HeaderHash = "HashSHA256"
+	// MetadataHash - ключ метаданных gRPC с подписью сообщения запроса.
+	MetadataHash = "hashsha256"
+)
+
+var (
+	// ErrInvalidHash - подпись не совпадает с содержимым.
+	ErrInvalidHash = errors.New("signer: подпись не совпадает с содержимым")
+)
+
+// Hash - возвращает подпись HMAC-SHA256 данных data на ключе key в шестнадцатеричном виде.
+func Hash(data []byte, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify - проверяет, что hash является подписью HMAC-SHA256 данных data на ключе key.
+// Если подпись не совпадает, то возвращает ошибку ErrInvalidHash.
+func Verify(data []byte, key, hash string) error {
+	got, err := hex.DecodeString(hash)
+	if err != nil {
+		return ErrInvalidHash
+	}
+
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+
+	if !hmac.Equal(got, h.Sum(nil)) {
+		return ErrInvalidHash
+	}
+
+	return nil
+}
+
+// HashMessage - возвращает подпись HMAC-SHA256 сообщения protobuf msg на ключе key.
+// Сообщение сериализуется детерминированно, чтобы метки из map подписывались
+// в одном порядке у агента и на сервере.
+func HashMessage(msg proto.Message, key string) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	return Hash(data, key), nil
+}
+
+// VerifyMessage - проверяет, что hash является подписью HMAC-SHA256 сообщения
+// protobuf msg на ключе key. Если подпись не совпадает, то возвращает ошибку ErrInvalidHash.
+func VerifyMessage(msg proto.Message, key, hash string) error {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return Verify(data, key, hash)
+}
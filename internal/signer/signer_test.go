@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	data := []byte(`{"id":"PollCount","type":"counter","delta":1}`)
+	hash := Hash(data, "secret")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     string
+		hash    string
+		wantErr bool
+	}{
+		{
+			name: "valid signature",
+			data: data,
+			key:  "secret",
+			hash: hash,
+		},
+		{
+			name:    "another key",
+			data:    data,
+			key:     "another",
+			hash:    hash,
+			wantErr: true,
+		},
+		{
+			name:    "modified data",
+			data:    []byte(`{"id":"PollCount","type":"counter","delta":100}`),
+			key:     "secret",
+			hash:    hash,
+			wantErr: true,
+		},
+		{
+			name:    "malformed hash",
+			data:    data,
+			key:     "secret",
+			hash:    "not a hex",
+			wantErr: true,
+		},
+		{
+			name:    "empty hash",
+			data:    data,
+			key:     "secret",
+			hash:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.data, tt.key, tt.hash)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidHash)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,612 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	// драйвер PostgreSQL для database/sql.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+)
+
+type dbStorage struct {
+	db     *sql.DB
+	logger *zap.Logger
+	// backoff - политика повторных попыток записи при временной недоступности базы данных.
+	backoff retry.Backoff
+}
+
+const (
+	// dbTimeout - максимальное время выполнения запроса к базе данных.
+	dbTimeout = 5 * time.Second
+	// dbScanBatch - количество записей, читаемых из базы данных одним запросом при Scan.
+	dbScanBatch = 100
+
+	// id - идентификатор метрики (имя и метки), name и labels - имя и метки метрики
+	// в формате JSON, data - значение метрики histogram или summary в формате JSON,
+	// updated_at - время последнего изменения, ttl - собственный срок хранения
+	// метрики без обновлений в микросекундах.
+	// Колонки name, labels, data, updated_at и ttl добавляются в таблицы, созданные до их появления.
+	// Для записей без имени имя метрики совпадает с id.
+	queryCreateTable = `CREATE TABLE IF NOT EXISTS metrics (
+		id         TEXT PRIMARY KEY,
+		kind       VARCHAR(32) NOT NULL,
+		delta      BIGINT,
+		value      DOUBLE PRECISION,
+		name       TEXT,
+		labels     TEXT,
+		data       TEXT,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		ttl        BIGINT
+	);
+	ALTER TABLE metrics
+		ALTER COLUMN id TYPE TEXT,
+		ADD COLUMN IF NOT EXISTS name TEXT,
+		ADD COLUMN IF NOT EXISTS labels TEXT,
+		ADD COLUMN IF NOT EXISTS data TEXT,
+		ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		ADD COLUMN IF NOT EXISTS ttl BIGINT`
+	queryUpsert = `INSERT INTO metrics (id, kind, delta, value, name, labels, data, ttl) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET kind = EXCLUDED.kind, delta = EXCLUDED.delta, value = EXCLUDED.value,
+			name = EXCLUDED.name, labels = EXCLUDED.labels, data = EXCLUDED.data,
+			updated_at = now(), ttl = COALESCE(EXCLUDED.ttl, metrics.ttl)`
+	queryInsertNew = `INSERT INTO metrics (id, kind, delta, value, name, labels, data, ttl) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO NOTHING`
+	queryIncrement = `INSERT INTO metrics (id, kind, delta, value, name, labels, ttl) VALUES ($1, $2, $3, NULL, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE SET
+			delta = CASE WHEN metrics.kind = EXCLUDED.kind THEN metrics.delta + EXCLUDED.delta ELSE EXCLUDED.delta END,
+			kind = EXCLUDED.kind, value = NULL, name = EXCLUDED.name, labels = EXCLUDED.labels, data = NULL,
+			updated_at = now(), ttl = COALESCE(EXCLUDED.ttl, metrics.ttl)
+		RETURNING delta`
+	querySelect          = `SELECT id, kind, delta, value, name, labels, data FROM metrics WHERE id = $1`
+	querySelectForUpdate = `SELECT id, kind, delta, value, name, labels, data FROM metrics WHERE id = $1 FOR UPDATE`
+	querySelectAll       = `SELECT id, kind, delta, value, name, labels, data FROM metrics`
+	queryDelete          = `DELETE FROM metrics WHERE id = $1 RETURNING id, kind, delta, value, name, labels, data`
+	// идентификаторы сравниваются побайтно, как и в остальных хранилищах.
+	queryScan = `SELECT id, kind, delta, value, name, labels, data FROM metrics
+		WHERE id COLLATE "C" > $1 ORDER BY id COLLATE "C" LIMIT $2`
+	// $1 - срок хранения метрик без собственного срока в микросекундах,
+	// время истечения сравнивается с часами базы данных now().
+	queryExpire = `DELETE FROM metrics
+		WHERE COALESCE(ttl, $1) > 0 AND updated_at + COALESCE(ttl, $1) * INTERVAL '1 microsecond' < now()
+		RETURNING id, kind, delta, value, name, labels, data`
+)
+
+var (
+	_ Storage = &dbStorage{}
+
+	// ErrInvalidRecordValue - запись не содержит значения метрики.
+	ErrInvalidRecordValue = errors.New("storage: a record has to have a valid value")
+)
+
+// NewDBStorage - подключается к базе данных PostgreSQL по строке подключения dsn
+// и создаёт таблицу метрик, если её ещё нет.
+func NewDBStorage(dsn string, log *zap.Logger) (*dbStorage, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := newDBStorage(db, log)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+func newDBStorage(db *sql.DB, log *zap.Logger) (*dbStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.ExecContext(ctx, queryCreateTable); err != nil {
+		return nil, err
+	}
+
+	return &dbStorage{db: db, logger: log, backoff: retry.Default()}, nil
+}
+
+func (d *dbStorage) Push(name string, record Record) error {
+	record.name = name
+
+	return d.PushBatch([]Record{record})
+}
+
+// PushBatch - сохраняет записи одной транзакцией.
+// Запись значений идемпотентна, поэтому транзакция повторяется при любой временной ошибке.
+func (d *dbStorage) PushBatch(records []Record) error {
+	return d.backoff.Do(context.Background(), func() error {
+		err := d.pushBatch(records)
+		if isTransientDBError(err) {
+			return retry.Retriable(err)
+		}
+
+		return err
+	})
+}
+
+func (d *dbStorage) pushBatch(records []Record) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, queryUpsert)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, record := range records {
+		if record.value == nil {
+			return ErrInvalidRecordValue
+		}
+
+		args, err := upsertArgs(record)
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Update - применяет записи одной транзакцией.
+// Увеличение счётчиков не идемпотентно, поэтому транзакция повторяется,
+// только если её не удалось начать, иначе при сбое во время фиксации
+// значения счётчиков могли бы быть увеличены дважды.
+func (d *dbStorage) Update(records []Record) ([]Record, error) {
+	var result []Record
+
+	err := d.backoff.Do(context.Background(), func() error {
+		var err error
+		result, err = d.update(records)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (d *dbStorage) update(records []Record) ([]Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		if isTransientDBError(err) {
+			return nil, retry.Retriable(err)
+		}
+
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	result := make([]Record, 0, len(records))
+
+	for _, record := range records {
+		labels, err := labelsToDB(record.labels)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := record.value.(type) {
+		case metric.Counter:
+			var delta int64
+			err := tx.QueryRowContext(ctx, queryIncrement, record.Key(), v.Kind(), int64(v), record.name, labels, ttlToDB(record.ttl)).Scan(&delta)
+			if err != nil {
+				return nil, err
+			}
+
+			record.value = metric.Counter(delta)
+
+		case metric.Gauge:
+			if _, err := tx.ExecContext(ctx, queryUpsert, record.Key(), v.Kind(), nil, float64(v), record.name, labels, nil, ttlToDB(record.ttl)); err != nil {
+				return nil, err
+			}
+
+		case metric.Histogram, metric.Summary, metric.Sketch, metric.Set:
+			merged, err := mergeDistribution(ctx, tx, record)
+			if err != nil {
+				return nil, err
+			}
+
+			record.value = merged
+
+		default:
+			return nil, ErrInvalidRecordValue
+		}
+
+		result = append(result, record)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (d *dbStorage) Get(key string) (Record, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	record, err := scanRecord(d.db.QueryRowContext(ctx, querySelect, key))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			d.logger.Error("storage get record", zap.String("key", key), zap.Error(err))
+		}
+
+		return Record{}, false
+	}
+
+	return record, true
+}
+
+func (d *dbStorage) GetAll() []Record {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	records := make([]Record, 0)
+
+	rows, err := d.db.QueryContext(ctx, querySelectAll)
+	if err != nil {
+		d.logger.Error("storage get all records", zap.Error(err))
+		return records
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			d.logger.Error("storage get all records", zap.Error(err))
+			return records
+		}
+
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		d.logger.Error("storage get all records", zap.Error(err))
+	}
+
+	return records
+}
+
+// Scan - читает записи из базы данных частями по dbScanBatch записей.
+func (d *dbStorage) Scan(after string, fn func(Record) bool) error {
+	for {
+		records, err := d.scan(after)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if !fn(record) {
+				return nil
+			}
+		}
+
+		if len(records) < dbScanBatch {
+			return nil
+		}
+
+		after = records[len(records)-1].Key()
+	}
+}
+
+// scan - возвращает не более dbScanBatch записей с идентификаторами больше after.
+func (d *dbStorage) scan(after string) ([]Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, queryScan, after, dbScanBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	records := make([]Record, 0, dbScanBatch)
+
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Delete - удаляет записи одной транзакцией.
+func (d *dbStorage) Delete(keys ...string) ([]Record, error) {
+	var deleted []Record
+
+	err := d.backoff.Do(context.Background(), func() error {
+		var err error
+		deleted, err = d.delete(keys)
+		if isTransientDBError(err) {
+			return retry.Retriable(err)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+func (d *dbStorage) delete(keys []string) ([]Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	deleted := make([]Record, 0, len(keys))
+
+	for _, key := range keys {
+		record, err := scanRecord(tx.QueryRowContext(ctx, queryDelete, key))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		deleted = append(deleted, record)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// Expire - удаляет устаревшие записи одним запросом. Время изменения записей
+// устанавливается часами базы данных, поэтому и срок хранения отсчитывается
+// от её текущего времени now(): момент now не используется, чтобы расхождение
+// часов сервера и базы данных не удаляло записи раньше срока.
+func (d *dbStorage) Expire(_ time.Time, ttl time.Duration) ([]Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, queryExpire, ttl.Microseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deleted := make([]Record, 0)
+
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deleted = append(deleted, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// Close - закрывает подключение к базе данных.
+func (d *dbStorage) Close() error {
+	return d.db.Close()
+}
+
+// isTransientDBError - проверяет, вызвана ли ошибка временной недоступностью базы данных:
+// сбоем сети, превышением времени ожидания или ошибкой соединения PostgreSQL.
+func isTransientDBError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if retry.IsNetworkError(err) || errors.Is(err, driver.ErrBadConn) || pgconn.Timeout(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgErr.Code == pgerrcode.CannotConnectNow ||
+			pgErr.Code == pgerrcode.TooManyConnections
+	}
+
+	return false
+}
+
+// mergeDistribution - объединяет значение histogram или summary записи
+// с сохранённым значением и возвращает результат. Новая запись вставляется
+// как есть, а существующая блокируется до конца транзакции, чтобы параллельные
+// обновления не потеряли наблюдения.
+func mergeDistribution(ctx context.Context, tx *sql.Tx, record Record) (metric.Metric, error) {
+	args, err := upsertArgs(record)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, queryInsertNew, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return record.value, err
+	}
+
+	stored, err := scanRecord(tx.QueryRowContext(ctx, querySelectForUpdate, record.Key()))
+	if err != nil {
+		return nil, err
+	}
+
+	record.value = metric.Merge(stored.value, record.value)
+
+	args, err = upsertArgs(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, queryUpsert, args...); err != nil {
+		return nil, err
+	}
+
+	return record.value, nil
+}
+
+// upsertArgs - возвращает аргументы запросов queryUpsert и queryInsertNew для записи.
+func upsertArgs(record Record) ([]any, error) {
+	var (
+		delta sql.NullInt64
+		value sql.NullFloat64
+		data  sql.NullString
+	)
+
+	switch v := record.value.(type) {
+	case metric.Counter:
+		delta = sql.NullInt64{Int64: int64(v), Valid: true}
+	case metric.Gauge:
+		value = sql.NullFloat64{Float64: float64(v), Valid: true}
+	case metric.Histogram, metric.Summary, metric.Sketch, metric.Set:
+		b, err := json.Marshal(distributionToJSON(v))
+		if err != nil {
+			return nil, err
+		}
+
+		data = sql.NullString{String: string(b), Valid: true}
+	}
+
+	labels, err := labelsToDB(record.labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{record.Key(), record.value.Kind(), delta, value, record.name, labels, data, ttlToDB(record.ttl)}, nil
+}
+
+// ttlToDB - возвращает срок хранения в микросекундах для колонки ttl
+// или NULL, если у записи нет собственного срока хранения.
+func ttlToDB(ttl time.Duration) sql.NullInt64 {
+	if ttl <= 0 {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: ttl.Microseconds(), Valid: true}
+}
+
+// labelsToDB - возвращает метки в формате JSON для колонки labels или NULL, если меток нет.
+func labelsToDB(labels metric.Labels) (sql.NullString, error) {
+	if len(labels) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecord(row rowScanner) (Record, error) {
+	var (
+		id     string
+		kind   string
+		delta  sql.NullInt64
+		value  sql.NullFloat64
+		name   sql.NullString
+		labels sql.NullString
+		data   sql.NullString
+	)
+
+	if err := row.Scan(&id, &kind, &delta, &value, &name, &labels, &data); err != nil {
+		return Record{}, err
+	}
+
+	metricKind, err := metric.GetKind(kind)
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{name: id}
+	if name.Valid {
+		record.name = name.String
+	}
+
+	if labels.Valid && len(labels.String) > 0 {
+		l := metric.Labels{}
+		if err := json.Unmarshal([]byte(labels.String), &l); err != nil {
+			return Record{}, err
+		}
+
+		record.SetLabels(l)
+	}
+
+	switch metricKind {
+	case metric.KindCounter:
+		record.SetValue(metric.Counter(delta.Int64))
+	case metric.KindGauge:
+		record.SetValue(metric.Gauge(value.Float64))
+	case metric.KindHistogram, metric.KindSummary, metric.KindSketch, metric.KindSet:
+		j := JSONDistribution{}
+		if data.Valid {
+			if err := json.Unmarshal([]byte(data.String), &j); err != nil {
+				return Record{}, err
+			}
+		}
+
+		record.SetValue(j.toMetric(metricKind))
+	}
+
+	return record, nil
+}
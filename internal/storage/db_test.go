@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+	"github.com/a-x-a/go-metric/internal/retry"
+)
+
+func newTestDBStorage(t *testing.T) (*dbStorage, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+
+	mock.ExpectPing()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS metrics")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ds, err := newDBStorage(db, zap.NewNop())
+	require.NoError(t, err)
+
+	ds.backoff = retry.New(time.Millisecond, time.Millisecond)
+
+	return ds, mock
+}
+
+func Test_newDBStorage(t *testing.T) {
+	t.Run("create schema", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+		require.NotNil(t, ds)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ping error", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		require.NoError(t, err)
+
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+		_, err = newDBStorage(db, zap.NewNop())
+		require.Error(t, err)
+	})
+}
+
+func Test_dbStoragePush(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+	prep.ExpectExec().
+		WithArgs("PollCount", "counter", int64(10), nil, "PollCount", nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := ds.Push("PollCount", Record{name: "PollCount", value: metric.Counter(10)})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStoragePushLabels(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+	prep.ExpectExec().
+		WithArgs(`Alloc{host="a",region="eu"}`, "gauge", nil, 1.5, "Alloc", `{"host":"a","region":"eu"}`, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	record := Record{name: "Alloc", value: metric.Gauge(1.5)}
+	record.SetLabels(metric.Labels{"region": "eu", "host": "a"})
+
+	require.NoError(t, ds.Push("Alloc", record))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStoragePushBatch(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+		prep.ExpectExec().
+			WithArgs("Alloc", "gauge", nil, 12.345, "Alloc", nil, nil, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		prep.ExpectExec().
+			WithArgs("PollCount", "counter", int64(123), nil, "PollCount", nil, nil, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := ds.PushBatch([]Record{
+			{name: "Alloc", value: metric.Gauge(12.345)},
+			{name: "PollCount", value: metric.Counter(123)},
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+		prep.ExpectExec().
+			WithArgs("Alloc", "gauge", nil, 12.345, "Alloc", nil, nil, nil).
+			WillReturnError(errors.New("insert failed"))
+		mock.ExpectRollback()
+
+		err := ds.PushBatch([]Record{
+			{name: "Alloc", value: metric.Gauge(12.345)},
+			{name: "PollCount", value: metric.Counter(123)},
+		})
+		require.Error(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("record without value", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+		mock.ExpectRollback()
+
+		err := ds.PushBatch([]Record{{name: "Alloc"}})
+		require.ErrorIs(t, err, ErrInvalidRecordValue)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_dbStorageUpdateTTL(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO metrics")).
+		WithArgs("Alloc", "gauge", nil, 1.5, "Alloc", nil, nil, int64(90000000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	record := Record{name: "Alloc", value: metric.Gauge(1.5)}
+	require.NoError(t, record.SetTTL(90*time.Second))
+
+	_, err := ds.Update([]Record{record})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageUpdate(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO metrics")).
+		WithArgs("PollCount", "counter", int64(5), "PollCount", nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"delta"}).AddRow(int64(15)))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO metrics")).
+		WithArgs("Alloc", "gauge", nil, 12.345, "Alloc", nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	got, err := ds.Update([]Record{
+		{name: "PollCount", value: metric.Counter(5)},
+		{name: "Alloc", value: metric.Gauge(12.345)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Record{
+		{name: "PollCount", value: metric.Counter(15)},
+		{name: "Alloc", value: metric.Gauge(12.345)},
+	}, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageUpdateHistogram(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+	update := metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{1, 1, 0}, Sum: 0.55, Count: 2}
+	stored := `{"sum":1,"count":3,"bounds":[0.1,1],"buckets":[1,0,2]}`
+
+	t.Run("new metric", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO metrics")).
+			WithArgs("Latency", "histogram", nil, nil, "Latency", nil, `{"sum":0.55,"count":2,"bounds":[0.1,1],"buckets":[1,1,0]}`, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		got, err := ds.Update([]Record{{name: "Latency", value: update}})
+		require.NoError(t, err)
+		require.Equal(t, update, got[0].GetValue())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("merge with stored", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO metrics")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).
+			WithArgs("Latency").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "delta", "value", "name", "labels", "data"}).
+				AddRow("Latency", "histogram", nil, nil, "Latency", nil, stored))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO metrics")).
+			WithArgs("Latency", "histogram", nil, nil, "Latency", nil, `{"sum":1.55,"count":5,"bounds":[0.1,1],"buckets":[2,1,2]}`, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		got, err := ds.Update([]Record{{name: "Latency", value: update}})
+		require.NoError(t, err)
+		require.Equal(t, metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{2, 1, 2}, Sum: 1.55, Count: 5}, got[0].GetValue())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func Test_dbStorageGet(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+	columns := []string{"id", "kind", "delta", "value", "name", "labels", "data"}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, kind, delta, value, name, labels, data FROM metrics WHERE id = $1")).
+		WithArgs("PollCount").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("PollCount", "counter", int64(123), nil, nil, nil, nil))
+
+	record, ok := ds.Get("PollCount")
+	require.True(t, ok)
+	require.Equal(t, Record{name: "PollCount", value: metric.Counter(123)}, record)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, kind, delta, value, name, labels, data FROM metrics WHERE id = $1")).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	_, ok = ds.Get("unknown")
+	require.False(t, ok)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageGetAll(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+	records := []Record{
+		{name: "Alloc", value: metric.Gauge(12.345)},
+		{name: "PollCount", value: metric.Counter(123)},
+		{name: "Requests", labels: metric.Labels{"host": "a"}, value: metric.Counter(7)},
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, kind, delta, value, name, labels, data FROM metrics")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "delta", "value", "name", "labels", "data"}).
+			AddRow("Alloc", "gauge", nil, 12.345, nil, nil, nil).
+			AddRow("PollCount", "counter", int64(123), nil, "PollCount", nil, nil).
+			AddRow(`Requests{host="a"}`, "counter", int64(7), nil, "Requests", `{"host":"a"}`, nil))
+
+	got := ds.GetAll()
+	require.ElementsMatch(t, records, got)
+
+	mock.ExpectClose()
+	require.NoError(t, ds.Close())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageScan(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+	columns := []string{"id", "kind", "delta", "value", "name", "labels", "data"}
+
+	full := sqlmock.NewRows(columns)
+	for i := 0; i < dbScanBatch; i++ {
+		full.AddRow(fmt.Sprintf("Gauge%03d", i), "gauge", nil, float64(i), nil, nil, nil)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id COLLATE "C" > $1`)).
+		WithArgs("", dbScanBatch).
+		WillReturnRows(full)
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id COLLATE "C" > $1`)).
+		WithArgs(fmt.Sprintf("Gauge%03d", dbScanBatch-1), dbScanBatch).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(`Requests{host="a"}`, "counter", int64(7), nil, "Requests", `{"host":"a"}`, nil))
+
+	got := make([]Record, 0)
+	err := ds.Scan("", func(record Record) bool {
+		got = append(got, record)
+		return true
+	})
+	require.NoError(t, err)
+	require.Len(t, got, dbScanBatch+1)
+	require.Equal(t, Record{name: "Requests", labels: metric.Labels{"host": "a"}, value: metric.Counter(7)}, got[dbScanBatch])
+
+	// остановка перебора не приводит к чтению следующей части.
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id COLLATE "C" > $1`)).
+		WithArgs("Alloc", dbScanBatch).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("PollCount", "counter", int64(1), nil, nil, nil, nil).
+			AddRow("Random", "gauge", nil, 1.5, nil, nil, nil))
+
+	got = got[:0]
+	err = ds.Scan("Alloc", func(record Record) bool {
+		got = append(got, record)
+		return false
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Record{{name: "PollCount", value: metric.Counter(1)}}, got)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageDelete(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+	columns := []string{"id", "kind", "delta", "value", "name", "labels", "data"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM metrics WHERE id = $1")).
+		WithArgs("PollCount").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("PollCount", "counter", int64(123), nil, "PollCount", nil, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM metrics WHERE id = $1")).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectCommit()
+
+	deleted, err := ds.Delete("PollCount", "unknown")
+	require.NoError(t, err)
+	require.Equal(t, []Record{{name: "PollCount", value: metric.Counter(123)}}, deleted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageExpire(t *testing.T) {
+	ds, mock := newTestDBStorage(t)
+	now := time.Now()
+
+	// срок хранения отсчитывается от времени базы данных, а не от now.
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM metrics")).
+		WithArgs(int64(600000000)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "delta", "value", "name", "labels", "data"}).
+			AddRow(`Alloc{instance="old"}`, "gauge", nil, 1.5, "Alloc", `{"instance":"old"}`, nil))
+
+	deleted, err := ds.Expire(now, 10*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []Record{{name: "Alloc", labels: metric.Labels{"instance": "old"}, value: metric.Gauge(1.5)}}, deleted)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_dbStorageRetry(t *testing.T) {
+	errConnection := &pgconn.PgError{Code: "08006"}
+
+	t.Run("push batch after connection failure", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		mock.ExpectBegin().WillReturnError(errConnection)
+		mock.ExpectBegin()
+		prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+		prep.ExpectExec().
+			WithArgs("Alloc", "gauge", nil, 12.345, "Alloc", nil, nil, nil).
+			WillReturnError(errConnection)
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		prep = mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+		prep.ExpectExec().
+			WithArgs("Alloc", "gauge", nil, 12.345, "Alloc", nil, nil, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := ds.PushBatch([]Record{{name: "Alloc", value: metric.Gauge(12.345)}})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("push batch attempts exhausted", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		for i := 0; i < 3; i++ {
+			mock.ExpectBegin().WillReturnError(errConnection)
+		}
+
+		err := ds.PushBatch([]Record{{name: "Alloc", value: metric.Gauge(12.345)}})
+		require.ErrorIs(t, err, errConnection)
+		require.False(t, retry.IsRetriable(err))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update is not retried after begin", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		mock.ExpectBegin().WillReturnError(errConnection)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO metrics")).
+			WithArgs("PollCount", "counter", int64(5), "PollCount", nil, nil).
+			WillReturnError(errConnection)
+		mock.ExpectRollback()
+
+		_, err := ds.Update([]Record{{name: "PollCount", value: metric.Counter(5)}})
+		require.ErrorIs(t, err, errConnection)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not transient error", func(t *testing.T) {
+		ds, mock := newTestDBStorage(t)
+
+		mock.ExpectBegin().WillReturnError(&pgconn.PgError{Code: "42P01"})
+
+		err := ds.PushBatch([]Record{{name: "Alloc", value: metric.Gauge(12.345)}})
+		require.Error(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -16,38 +18,228 @@ type withFileStorage struct {
 	path     string
 	syncMode bool
 	logger   *zap.Logger
+	wal      *wal
+	// stopSync, syncDone - остановка и завершение периодического сброса журнала
+	// для политики FsyncInterval, nil - если сброс не запущен.
+	stopSync chan struct{}
+	syncDone chan struct{}
 }
 
-var _ Storage = &withFileStorage{}
+const (
+	// walCompactThreshold - количество записей журнала, после которого
+	// сохраняется снимок хранилища, а журнал очищается.
+	walCompactThreshold = 10000
+)
+
+var (
+	_ Storage = &withFileStorage{}
+
+	// ErrInvalidPath - не указан путь к файлу хранилища.
+	ErrInvalidPath = errors.New("storage: file path is empty")
+)
 
+// NewWithFileStorage - создаёт хранилище в памяти с сохранением снимка в файл path.
+// В синхронном режиме каждое изменение дописывается в журнал path.wal,
+// который сбрасывается на диск после каждой записи.
 func NewWithFileStorage(path string, syncMode bool, log *zap.Logger) *withFileStorage {
 	return &withFileStorage{
 		memStorage: NewMemStorage(),
 		path:       path,
 		syncMode:   syncMode,
 		logger:     log,
+		wal:        newWAL(path+".wal", FsyncAlways),
+	}
+}
+
+// setFsyncPolicy - устанавливает политику сброса журнала на диск. Для политики
+// FsyncInterval в синхронном режиме запускается периодический сброс журнала,
+// который останавливается в Close.
+func (m *withFileStorage) setFsyncPolicy(policy FsyncPolicy) {
+	m.wal.policy = policy
+
+	if m.syncMode && policy == FsyncInterval && m.stopSync == nil {
+		m.stopSync = make(chan struct{})
+		m.syncDone = make(chan struct{})
+
+		go m.syncLoop(m.stopSync, m.syncDone)
+	}
+}
+
+// syncLoop - раз в walSyncInterval сбрасывает на диск записи журнала,
+// после которых сброса не было, пока не будет закрыт канал stop.
+func (m *withFileStorage) syncLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(walSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.Lock()
+			if m.wal.unsynced > 0 {
+				if err := m.wal.sync(); err != nil {
+					m.logger.Error("wal sync", zap.String("file", m.wal.path), zap.Error(err))
+				}
+			}
+			m.Unlock()
+		}
 	}
 }
 
+// Close - останавливает периодический сброс журнала, сбрасывает журнал на диск
+// и закрывает его файл.
+func (m *withFileStorage) Close() error {
+	if m.stopSync != nil {
+		close(m.stopSync)
+		<-m.syncDone
+
+		m.stopSync, m.syncDone = nil, nil
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	return m.wal.close()
+}
+
 func (m *withFileStorage) Push(name string, record Record) error {
+	if !m.syncMode {
+		return m.memStorage.Push(name, record)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
 	if err := m.memStorage.Push(name, record); err != nil {
 		return err
 	}
 
-	if m.syncMode {
-		return m.Save()
+	record.name = name
+
+	return m.log([]Record{record})
+}
+
+func (m *withFileStorage) PushBatch(records []Record) error {
+	if !m.syncMode {
+		return m.memStorage.PushBatch(records)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if err := m.memStorage.PushBatch(records); err != nil {
+		return err
+	}
+
+	return m.log(records)
+}
+
+func (m *withFileStorage) Update(records []Record) ([]Record, error) {
+	if !m.syncMode {
+		return m.memStorage.Update(records)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	result, err := m.memStorage.Update(records)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, m.log(result)
+}
+
+// Delete - удаляет записи. В синхронном режиме удаление записывается в журнал,
+// чтобы удалённые метрики не восстановились из снимка при загрузке.
+func (m *withFileStorage) Delete(keys ...string) ([]Record, error) {
+	if !m.syncMode {
+		return m.memStorage.Delete(keys...)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	deleted, err := m.memStorage.Delete(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, m.logDeleted(deleted)
+}
+
+// Expire - удаляет устаревшие записи. В синхронном режиме удаление записывается в журнал.
+func (m *withFileStorage) Expire(now time.Time, ttl time.Duration) ([]Record, error) {
+	if !m.syncMode {
+		return m.memStorage.Expire(now, ttl)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	deleted, err := m.memStorage.Expire(now, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, m.logDeleted(deleted)
+}
+
+// logDeleted - дописывает в журнал записи об удалении метрик.
+func (m *withFileStorage) logDeleted(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	tombstones := make([]Record, 0, len(records))
+	for _, record := range records {
+		tombstones = append(tombstones, Record{name: record.name, labels: record.labels, deleted: true})
+	}
+
+	return m.log(tombstones)
+}
+
+// log - дописывает изменения в журнал и, если журнал разросся,
+// сохраняет снимок хранилища.
+func (m *withFileStorage) log(records []Record) error {
+	if len(m.path) == 0 {
+		return ErrInvalidPath
+	}
+
+	if err := m.wal.append(records); err != nil {
+		return err
+	}
+
+	if m.wal.records >= walCompactThreshold {
+		return m.save()
 	}
 
 	return nil
 }
 
+// Save - сохраняет снимок хранилища в файл и очищает журнал.
+// Снимок сначала пишется во временный файл, который затем атомарно
+// переименовывается, поэтому сбой во время записи не портит прежний снимок.
 func (m *withFileStorage) Save() error {
 	m.Lock()
 	defer m.Unlock()
 
+	return m.save()
+}
+
+func (m *withFileStorage) save() error {
+	if len(m.path) == 0 {
+		return ErrInvalidPath
+	}
+
 	m.logger.Info("start save storage to file", zap.String("file", m.path))
 
-	f, err := os.OpenFile(m.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	tmp := m.path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
@@ -61,56 +253,154 @@ func (m *withFileStorage) Save() error {
 		return err
 	}
 
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, m.path); err != nil {
+		return err
+	}
+
+	if err := m.wal.truncate(); err != nil {
+		return err
+	}
+
 	m.logger.Info("saved storage to file", zap.String("file", m.path))
 
 	return nil
 }
 
+// Load - восстанавливает хранилище из снимка и журнала.
+// Недописанная последняя запись журнала отбрасывается.
 func (m *withFileStorage) Load() error {
 	m.Lock()
 	defer m.Unlock()
 
+	if len(m.path) == 0 {
+		return ErrInvalidPath
+	}
+
 	m.logger.Info("loading storage from file", zap.String("file", m.path))
 
-	file, err := os.Open(m.path)
-	if err != nil {
-		return err
+	data := make(map[string]Record)
+
+	snapErr := loadSnapshot(m.path, &data)
+	if snapErr != nil && !errors.Is(snapErr, os.ErrNotExist) {
+		return snapErr
 	}
 
-	defer file.Close()
+	count, err := m.wal.replay(func(r Record) {
+		if r.deleted {
+			delete(data, r.Key())
+			return
+		}
 
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&m.data); err != nil {
+		data[r.Key()] = r
+	})
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
 
-	m.logger.Info("storage loded from file", zap.String("file", m.path))
+	// нет ни снимка, ни записей журнала.
+	if snapErr != nil && count == 0 {
+		return snapErr
+	}
+
+	m.memStorage.reset(data)
+
+	m.logger.Info("storage loded from file", zap.String("file", m.path), zap.Int("wal records", count))
 
 	return nil
 }
 
-type JSONMetric struct {
-	Name  string  `json:"name"`            // имя метрики
-	Kind  string  `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+func loadSnapshot(path string, data *map[string]Record) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+
+	return decoder.Decode(data)
 }
 
+type (
+	JSONMetric struct {
+		Name   string        `json:"name"`             // имя метрики
+		Labels metric.Labels `json:"labels,omitempty"` // метки метрики
+		Kind   string        `json:"type"`             // тип метрики: gauge, counter, histogram, summary, sketch или set
+		Delta  int64         `json:"delta,omitempty"`  // значение метрики в случае передачи counter
+		Value  float64       `json:"value,omitempty"`  // значение метрики в случае передачи gauge
+		JSONDistribution
+		TTL     string `json:"ttl,omitempty"`     // срок хранения метрики без обновлений, например 10m
+		Deleted bool   `json:"deleted,omitempty"` // запись журнала об удалении метрики
+	}
+
+	// JSONDistribution - значение метрики histogram, summary, sketch или set.
+	JSONDistribution struct {
+		Sum       float64        `json:"sum,omitempty"`       // сумма наблюдений
+		Count     uint64         `json:"count,omitempty"`     // количество наблюдений
+		Bounds    []float64      `json:"bounds,omitempty"`    // верхние границы корзин histogram без +Inf
+		Buckets   []uint64       `json:"buckets,omitempty"`   // количество наблюдений в корзинах histogram
+		Quantiles []JSONQuantile `json:"quantiles,omitempty"` // квантили summary
+		Sketch    *JSONSketch    `json:"sketch,omitempty"`    // корзины sketch
+		Registers []byte         `json:"registers,omitempty"` // регистры HyperLogLog set в base64
+	}
+
+	// JSONSketch - корзины квантильного эскиза sketch.
+	JSONSketch struct {
+		RelativeAccuracy float64         `json:"accuracy"`           // относительная погрешность квантилей
+		Min              float64         `json:"min"`                // минимальное наблюдение
+		Max              float64         `json:"max"`                // максимальное наблюдение
+		Zero             uint64          `json:"zero,omitempty"`     // количество нулевых наблюдений
+		Positive         *JSONSketchBins `json:"positive,omitempty"` // корзины положительных наблюдений
+		Negative         *JSONSketchBins `json:"negative,omitempty"` // корзины модулей отрицательных наблюдений
+	}
+
+	// JSONSketchBins - корзины эскиза подряд начиная с индекса Offset.
+	JSONSketchBins struct {
+		Offset int      `json:"offset"` // индекс первой корзины
+		Counts []uint64 `json:"counts"` // количество наблюдений в корзинах
+	}
+
+	// JSONQuantile - значение квантиля summary.
+	JSONQuantile struct {
+		Quantile float64 `json:"quantile"` // уровень квантиля
+		Value    float64 `json:"value"`    // значение квантиля
+	}
+)
+
 func recordToJSONMetric(r Record) JSONMetric {
 	j := JSONMetric{
-		Name: r.name,
-		Kind: r.value.Kind(),
+		Name:    r.name,
+		Labels:  r.labels,
+		Deleted: r.deleted,
 	}
 
-	switch {
-	case r.value.IsCounter():
-		if v, ok := r.GetValue().(metric.Counter); ok {
-			j.Delta = int64(v)
-		}
-	case r.value.IsGauge():
-		if v, ok := r.GetValue().(metric.Gauge); ok {
-			j.Value = float64(v)
-		}
+	if r.ttl > 0 {
+		j.TTL = r.ttl.String()
+	}
+
+	if r.deleted {
+		return j
+	}
+
+	j.Kind = r.value.Kind()
+
+	switch v := r.GetValue().(type) {
+	case metric.Counter:
+		j.Delta = int64(v)
+	case metric.Gauge:
+		j.Value = float64(v)
+	case metric.Histogram, metric.Summary, metric.Sketch, metric.Set:
+		j.JSONDistribution = distributionToJSON(v)
 	}
 
 	return j
@@ -118,6 +408,13 @@ func recordToJSONMetric(r Record) JSONMetric {
 
 func jsonMetricToRecord(j JSONMetric, r *Record) {
 	r.name = j.Name
+	r.SetLabels(j.Labels)
+	r.deleted = j.Deleted
+
+	if ttl, err := time.ParseDuration(j.TTL); err == nil && ttl > 0 {
+		r.ttl = ttl
+	}
+
 	kind, _ := metric.GetKind(j.Kind)
 
 	switch kind {
@@ -127,5 +424,100 @@ func jsonMetricToRecord(j JSONMetric, r *Record) {
 	case metric.KindCounter:
 		val := metric.Counter(j.Delta)
 		r.SetValue(val)
+	case metric.KindHistogram, metric.KindSummary, metric.KindSketch, metric.KindSet:
+		r.SetValue(j.JSONDistribution.toMetric(kind))
+	}
+}
+
+// distributionToJSON - возвращает значение метрики histogram, summary, sketch или set в формате JSON.
+func distributionToJSON(value metric.Metric) JSONDistribution {
+	j := JSONDistribution{}
+
+	switch v := value.(type) {
+	case metric.Histogram:
+		j.Sum, j.Count = v.Sum, v.Count
+		j.Bounds = v.Bounds
+		j.Buckets = v.Buckets
+	case metric.Summary:
+		j.Sum, j.Count = v.Sum, v.Count
+		for _, q := range v.Quantiles {
+			j.Quantiles = append(j.Quantiles, JSONQuantile{Quantile: q.Quantile, Value: q.Value})
+		}
+	case metric.Sketch:
+		j.Sum, j.Count = v.Sum, v.Count
+		j.Sketch = &JSONSketch{
+			RelativeAccuracy: v.RelativeAccuracy,
+			Min:              v.Min,
+			Max:              v.Max,
+			Zero:             v.Zero,
+			Positive:         sketchBinsToJSON(v.Positive),
+			Negative:         sketchBinsToJSON(v.Negative),
+		}
+	case metric.Set:
+		j.Registers = v.Registers
 	}
+
+	return j
+}
+
+// toMetric - возвращает значение метрики типа kind: histogram, summary, sketch или set.
+func (j JSONDistribution) toMetric(kind metric.MetricKind) metric.Metric {
+	if kind == metric.KindSet {
+		return metric.Set{Registers: j.Registers}
+	}
+
+	if kind == metric.KindSketch {
+		s := metric.Sketch{Sum: j.Sum, Count: j.Count}
+		if j.Sketch != nil {
+			s.RelativeAccuracy = j.Sketch.RelativeAccuracy
+			s.Min, s.Max, s.Zero = j.Sketch.Min, j.Sketch.Max, j.Sketch.Zero
+			s.Positive = j.Sketch.Positive.toBins(s.RelativeAccuracy)
+			s.Negative = j.Sketch.Negative.toBins(s.RelativeAccuracy)
+		}
+
+		return s
+	}
+
+	if kind == metric.KindSummary {
+		s := metric.Summary{Sum: j.Sum, Count: j.Count}
+		for _, q := range j.Quantiles {
+			s.Quantiles = append(s.Quantiles, metric.Quantile{Quantile: q.Quantile, Value: q.Value})
+		}
+
+		return s
+	}
+
+	h := metric.Histogram{Bounds: j.Bounds, Buckets: j.Buckets, Sum: j.Sum, Count: j.Count}
+	if len(h.Buckets) == 0 {
+		h.Buckets = make([]uint64, len(h.Bounds)+1)
+	}
+
+	return h
+}
+
+// sketchBinsToJSON - возвращает корзины эскиза в компактном виде.
+func sketchBinsToJSON(bins metric.SketchBins) *JSONSketchBins {
+	if len(bins) == 0 {
+		return nil
+	}
+
+	offset, counts := bins.Dense()
+
+	return &JSONSketchBins{Offset: offset, Counts: counts}
+}
+
+// toBins - возвращает корзины эскиза с погрешностью relativeAccuracy.
+// Сохраняются только проверенные эскизы, поэтому корзины с не допустимыми
+// индексами, например из повреждённого файла, отбрасываются.
+func (j *JSONSketchBins) toBins(relativeAccuracy float64) metric.SketchBins {
+	if j == nil {
+		return nil
+	}
+
+	bins, err := metric.NewSketchBins(relativeAccuracy, j.Offset, j.Counts)
+	if err != nil {
+		return nil
+	}
+
+	return bins
 }
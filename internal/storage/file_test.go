@@ -2,7 +2,9 @@ package storage
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -20,6 +22,14 @@ func Test_FileStorage(t *testing.T) {
 		{name: "Alloc", value: metric.Gauge(12.345)},
 		{name: "PollCount", value: metric.Counter(123)},
 		{name: "Random", value: metric.Gauge(1313.131)},
+		{name: "Random", labels: metric.Labels{"host": "a"}, value: metric.Gauge(1.5)},
+		{name: "Latency", value: metric.Histogram{Bounds: []float64{0.1, 1}, Buckets: []uint64{1, 0, 2}, Sum: 7.05, Count: 3}},
+		{name: "Duration", value: metric.Summary{Quantiles: []metric.Quantile{{Quantile: 0.5, Value: 0.2}}, Sum: 3, Count: 10}},
+		{name: "Size", value: metric.Sketch{
+			RelativeAccuracy: 0.01, Positive: metric.SketchBins{-5: 1, 0: 2}, Negative: metric.SketchBins{3: 1},
+			Count: 4, Sum: 0.9, Min: -1.06, Max: 1,
+		}},
+		{name: "Users", value: metric.Set{Registers: []uint8{0, 3, 1, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 7}}},
 	}
 
 	for _, v := range records {
@@ -66,4 +76,220 @@ func Test_FileStorage(t *testing.T) {
 
 	err = os.Remove(fileName)
 	require.NoError(t, err)
+
+	err = os.Remove(fileName + ".wal")
+	require.NoError(t, err)
+}
+
+func Test_FileStoragePushBatch(t *testing.T) {
+	require := require.New(t)
+
+	log := zap.NewNop()
+	fileName := os.TempDir() + string(os.PathSeparator) + "test_batch_123456789.json"
+	m := NewWithFileStorage(fileName, true, log)
+	records := []Record{
+		{name: "Alloc", value: metric.Gauge(12.345)},
+		{name: "PollCount", value: metric.Counter(123)},
+	}
+
+	err := m.PushBatch(records)
+	require.NoError(err)
+	require.FileExists(fileName + ".wal")
+
+	m2 := NewWithFileStorage(fileName, false, log)
+	err = m2.Load()
+	require.NoError(err)
+	require.ElementsMatch(records, m2.GetAll())
+
+	err = os.Remove(fileName + ".wal")
+	require.NoError(err)
+}
+
+func Test_FileStorageWAL(t *testing.T) {
+	require := require.New(t)
+
+	log := zap.NewNop()
+	fileName := filepath.Join(t.TempDir(), "metrics.json")
+
+	m := NewWithFileStorage(fileName, true, log)
+	require.NoError(m.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(1.5)}))
+	_, err := m.Update([]Record{{name: "PollCount", value: metric.Counter(2)}})
+	require.NoError(err)
+
+	// снимок и журнал: журнал очищается после сохранения снимка.
+	require.NoError(m.Save())
+	require.FileExists(fileName)
+
+	info, err := os.Stat(fileName + ".wal")
+	require.NoError(err)
+	require.Zero(info.Size())
+
+	_, err = m.Update([]Record{
+		{name: "PollCount", value: metric.Counter(3)},
+		{name: "Alloc", value: metric.Gauge(2.5)},
+	})
+	require.NoError(err)
+
+	// восстановление: снимок, затем журнал.
+	m2 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m2.Load())
+	require.ElementsMatch(m.GetAll(), m2.GetAll())
+
+	// повторное применение журнала не меняет значения счётчиков.
+	m3 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m3.Load())
+	require.NoError(m3.Load())
+
+	record, ok := m3.Get("PollCount")
+	require.True(ok)
+	require.Equal(metric.Counter(5), record.GetValue())
+}
+
+func Test_FileStorageDelete(t *testing.T) {
+	require := require.New(t)
+
+	log := zap.NewNop()
+	fileName := filepath.Join(t.TempDir(), "metrics.json")
+
+	m := NewWithFileStorage(fileName, true, log)
+	stale := Record{name: "Alloc", labels: metric.Labels{"instance": "old"}, value: metric.Gauge(1.5)}
+	require.NoError(stale.SetTTL(time.Minute))
+	require.NoError(m.PushBatch([]Record{
+		stale,
+		{name: "PollCount", value: metric.Counter(2)},
+		{name: "Random", value: metric.Gauge(3)},
+	}))
+	require.NoError(m.Save())
+
+	// удаления после снимка записываются в журнал.
+	deleted, err := m.Delete("PollCount")
+	require.NoError(err)
+	require.Len(deleted, 1)
+
+	deleted, err = m.Expire(time.Now().Add(2*time.Minute), 0)
+	require.NoError(err)
+	require.Equal([]Record{stale}, deleted)
+
+	m2 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m2.Load())
+	require.Equal([]Record{{name: "Random", value: metric.Gauge(3)}}, m2.GetAll())
+
+	// после сохранения снимка удалённые метрики в нём отсутствуют.
+	require.NoError(m2.Save())
+
+	m3 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m3.Load())
+	require.Equal(m2.GetAll(), m3.GetAll())
+}
+
+func Test_FileStorageWALTornRecord(t *testing.T) {
+	require := require.New(t)
+
+	log := zap.NewNop()
+	fileName := filepath.Join(t.TempDir(), "metrics.json")
+
+	m := NewWithFileStorage(fileName, true, log)
+	for i := 1; i <= 3; i++ {
+		_, err := m.Update([]Record{{name: "PollCount", value: metric.Counter(1)}})
+		require.NoError(err)
+	}
+
+	info, err := os.Stat(fileName + ".wal")
+	require.NoError(err)
+
+	// имитируем сбой во время дописывания последней записи.
+	require.NoError(os.Truncate(fileName+".wal", info.Size()-3))
+
+	m2 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m2.Load())
+
+	record, ok := m2.Get("PollCount")
+	require.True(ok)
+	require.Equal(metric.Counter(2), record.GetValue())
+
+	// журнал обрезан до последней целой записи и пригоден для дописывания.
+	_, err = m2.Update([]Record{{name: "PollCount", value: metric.Counter(1)}})
+	require.NoError(err)
+
+	m3 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m3.Load())
+
+	record, ok = m3.Get("PollCount")
+	require.True(ok)
+	require.Equal(metric.Counter(3), record.GetValue())
+}
+
+func Test_FileStorageWALCorruptedRecord(t *testing.T) {
+	require := require.New(t)
+
+	log := zap.NewNop()
+	fileName := filepath.Join(t.TempDir(), "metrics.json")
+
+	m := NewWithFileStorage(fileName, true, log)
+	require.NoError(m.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(1.5)}))
+	require.NoError(m.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(2.5)}))
+
+	data, err := os.ReadFile(fileName + ".wal")
+	require.NoError(err)
+
+	data[len(data)-2] ^= 0xff
+	require.NoError(os.WriteFile(fileName+".wal", data, 0600))
+
+	m2 := NewWithFileStorage(fileName, true, log)
+	require.NoError(m2.Load())
+
+	record, ok := m2.Get("Alloc")
+	require.True(ok)
+	require.Equal(metric.Gauge(1.5), record.GetValue())
+}
+
+func Test_FileStorageWALSyncInterval(t *testing.T) {
+	require := require.New(t)
+
+	fileName := filepath.Join(t.TempDir(), "metrics.json")
+
+	ds, err := NewDataStorage("", fileName, 0, string(FsyncInterval), zap.NewNop())
+	require.NoError(err)
+
+	m := ds.(*withFileStorage)
+	require.NotNil(m.stopSync)
+
+	// первая запись сбрасывается сразу, следующая - только по таймеру.
+	require.NoError(m.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(1.5)}))
+	require.NoError(m.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(2.5)}))
+
+	unsynced := func() int {
+		m.Lock()
+		defer m.Unlock()
+
+		return m.wal.unsynced
+	}
+
+	require.Equal(1, unsynced())
+	require.Eventually(func() bool { return unsynced() == 0 }, 3*walSyncInterval, 10*time.Millisecond)
+
+	require.NoError(m.Close())
+	require.Nil(m.stopSync)
+
+	// журнал после закрытия снова открывается при записи.
+	require.NoError(m.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(3.5)}))
+	require.NoError(m.Close())
+
+	m2 := NewWithFileStorage(fileName, true, zap.NewNop())
+	require.NoError(m2.Load())
+
+	record, ok := m2.Get("Alloc")
+	require.True(ok)
+	require.Equal(metric.Gauge(3.5), record.GetValue())
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	for _, policy := range []string{"always", "interval", "never"} {
+		got, err := ParseFsyncPolicy(policy)
+		require.NoError(t, err)
+		require.Equal(t, FsyncPolicy(policy), got)
+	}
+
+	_, err := ParseFsyncPolicy("")
+	require.ErrorIs(t, err, ErrInvalidFsyncPolicy)
 }
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+)
+
+type (
+	// Sample - значение метрики в момент времени.
+	Sample struct {
+		Timestamp time.Time
+		Value     metric.Metric
+	}
+
+	// withHistoryStorage - хранилище, дополнительно сохраняющее историю значений
+	// каждой метрики в кольцевом буфере ограниченного размера и срока хранения.
+	withHistoryStorage struct {
+		Storage
+		sync.Mutex
+		history   map[string]*ring
+		retention time.Duration
+		size      int
+		now       func() time.Time
+	}
+
+	// ring - кольцевой буфер значений метрики, упорядоченных по времени.
+	ring struct {
+		samples []Sample
+		start   int
+		count   int
+	}
+)
+
+var _ Storage = &withHistoryStorage{}
+
+// NewWithHistoryStorage - создаёт хранилище с историей значений поверх хранилища base.
+// Для каждой метрики хранится не более size значений не старше retention.
+func NewWithHistoryStorage(base Storage, retention time.Duration, size int) *withHistoryStorage {
+	return &withHistoryStorage{
+		Storage:   base,
+		history:   make(map[string]*ring),
+		retention: retention,
+		size:      size,
+		now:       time.Now,
+	}
+}
+
+func (h *withHistoryStorage) Push(name string, record Record) error {
+	if err := h.Storage.Push(name, record); err != nil {
+		return err
+	}
+
+	record.name = name
+	h.record([]Record{record})
+
+	return nil
+}
+
+func (h *withHistoryStorage) PushBatch(records []Record) error {
+	if err := h.Storage.PushBatch(records); err != nil {
+		return err
+	}
+
+	h.record(records)
+
+	return nil
+}
+
+func (h *withHistoryStorage) Update(records []Record) ([]Record, error) {
+	result, err := h.Storage.Update(records)
+	if err != nil {
+		return nil, err
+	}
+
+	h.record(result)
+
+	return result, nil
+}
+
+// Delete - удаляет записи вместе с историей их значений.
+func (h *withHistoryStorage) Delete(keys ...string) ([]Record, error) {
+	deleted, err := h.Storage.Delete(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	h.forget(deleted)
+
+	return deleted, nil
+}
+
+// Expire - удаляет устаревшие записи вместе с историей их значений.
+func (h *withHistoryStorage) Expire(now time.Time, ttl time.Duration) ([]Record, error) {
+	deleted, err := h.Storage.Expire(now, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	h.forget(deleted)
+
+	return deleted, nil
+}
+
+// History - возвращает значения метрики с идентификатором key за период [from, to]
+// в порядке возрастания времени.
+func (h *withHistoryStorage) History(key string, from, to time.Time) ([]Sample, bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	r, ok := h.history[key]
+	if !ok {
+		return nil, false
+	}
+
+	r.expire(h.now().Add(-h.retention))
+
+	samples := make([]Sample, 0)
+	for i := 0; i < r.count; i++ {
+		s := r.at(i)
+		if s.Timestamp.Before(from) || s.Timestamp.After(to) {
+			continue
+		}
+
+		samples = append(samples, s)
+	}
+
+	return samples, true
+}
+
+func (h *withHistoryStorage) record(records []Record) {
+	h.Lock()
+	defer h.Unlock()
+
+	now := h.now()
+
+	for _, record := range records {
+		r, ok := h.history[record.Key()]
+		if !ok {
+			r = &ring{samples: make([]Sample, h.size)}
+			h.history[record.Key()] = r
+		}
+
+		r.expire(now.Add(-h.retention))
+		r.push(Sample{Timestamp: now, Value: record.value})
+	}
+}
+
+// forget - удаляет историю значений записей.
+func (h *withHistoryStorage) forget(records []Record) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, record := range records {
+		delete(h.history, record.Key())
+	}
+}
+
+func (r *ring) at(i int) Sample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+// push - добавляет значение в буфер, вытесняя самое старое при переполнении.
+func (r *ring) push(s Sample) {
+	if len(r.samples) == 0 {
+		return
+	}
+
+	if r.count == len(r.samples) {
+		r.samples[r.start] = s
+		r.start = (r.start + 1) % len(r.samples)
+		return
+	}
+
+	r.samples[(r.start+r.count)%len(r.samples)] = s
+	r.count++
+}
+
+// expire - удаляет значения, полученные раньше момента deadline.
+func (r *ring) expire(deadline time.Time) {
+	for r.count > 0 && r.samples[r.start].Timestamp.Before(deadline) {
+		r.samples[r.start] = Sample{}
+		r.start = (r.start + 1) % len(r.samples)
+		r.count--
+	}
+}
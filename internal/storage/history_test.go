@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
+)
+
+func Test_HistoryStorageDelete(t *testing.T) {
+	require := require.New(t)
+
+	h := NewWithHistoryStorage(NewMemStorage(), time.Minute, 3)
+	require.NoError(h.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(1)}))
+
+	deleted, err := h.Delete("Alloc")
+	require.NoError(err)
+	require.Len(deleted, 1)
+
+	_, ok := h.History("Alloc", time.Time{}, time.Now())
+	require.False(ok)
+}
+
+func Test_HistoryStorage(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Unix(1000, 0)
+	h := NewWithHistoryStorage(NewMemStorage(), time.Minute, 3)
+	h.now = func() time.Time { return now }
+
+	require.NoError(h.Push("Alloc", Record{name: "Alloc", value: metric.Gauge(1)}))
+
+	now = now.Add(10 * time.Second)
+	_, err := h.Update([]Record{
+		{name: "Alloc", value: metric.Gauge(2)},
+		{name: "PollCount", value: metric.Counter(5)},
+	})
+	require.NoError(err)
+
+	now = now.Add(10 * time.Second)
+	_, err = h.Update([]Record{{name: "PollCount", value: metric.Counter(5)}})
+	require.NoError(err)
+
+	samples, ok := h.History("PollCount", time.Time{}, now)
+	require.True(ok)
+	require.Equal([]Sample{
+		{Timestamp: time.Unix(1010, 0), Value: metric.Counter(5)},
+		{Timestamp: time.Unix(1020, 0), Value: metric.Counter(10)},
+	}, samples)
+
+	samples, ok = h.History("Alloc", time.Unix(1005, 0), now)
+	require.True(ok)
+	require.Equal([]Sample{{Timestamp: time.Unix(1010, 0), Value: metric.Gauge(2)}}, samples)
+
+	_, ok = h.History("unknown", time.Time{}, now)
+	require.False(ok)
+
+	record, ok := h.Get("PollCount")
+	require.True(ok)
+	require.Equal(metric.Counter(10), record.GetValue())
+
+	// переполнение кольцевого буфера вытесняет самые старые значения.
+	for i := 3; i <= 5; i++ {
+		now = now.Add(time.Second)
+		require.NoError(h.PushBatch([]Record{{name: "Alloc", value: metric.Gauge(i)}}))
+	}
+
+	samples, ok = h.History("Alloc", time.Time{}, now)
+	require.True(ok)
+	require.Len(samples, 3)
+	require.Equal(metric.Gauge(3), samples[0].Value)
+	require.Equal(metric.Gauge(5), samples[2].Value)
+
+	// значения старше срока хранения удаляются.
+	now = now.Add(time.Minute - time.Second)
+
+	samples, ok = h.History("Alloc", time.Time{}, now)
+	require.True(ok)
+	require.Len(samples, 2)
+	require.Equal(metric.Gauge(4), samples[0].Value)
+
+	now = now.Add(time.Hour)
+
+	samples, ok = h.History("Alloc", time.Time{}, now)
+	require.True(ok)
+	require.Empty(samples)
+}
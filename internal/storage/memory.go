@@ -1,34 +1,83 @@
 package storage
 
 import (
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/a-x-a/go-metric/internal/models/metric"
 )
 
 type memStorage struct {
 	sync.Mutex
 	data map[string]Record
+	// keys - идентификаторы записей в порядке возрастания для Scan.
+	keys []string
+	// updated - время последнего изменения записей для удаления устаревших.
+	updated map[string]time.Time
+	now     func() time.Time
 }
 
 var _ Storage = &memStorage{}
 
 func NewMemStorage() *memStorage {
 	return &memStorage{
-		data: make(map[string]Record),
+		data:    make(map[string]Record),
+		updated: make(map[string]time.Time),
+		now:     time.Now,
 	}
 }
 
 func (m *memStorage) Push(name string, record Record) error {
 	m.Lock()
 	defer m.Unlock()
-	m.data[name] = record
+
+	record.name = name
+	m.set(record)
 
 	return nil
 }
 
-func (m *memStorage) Get(name string) (Record, bool) {
+func (m *memStorage) PushBatch(records []Record) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, record := range records {
+		m.set(record)
+	}
+
+	return nil
+}
+
+// Update - применяет обновления. Срок хранения записи без собственного
+// срока в обновлении остаётся прежним.
+func (m *memStorage) Update(records []Record) ([]Record, error) {
+	result := make([]Record, 0, len(records))
+
+	m.Lock()
+	defer m.Unlock()
+
+	for _, record := range records {
+		stored := m.data[record.Key()]
+		record.value = metric.Merge(stored.value, record.value)
+
+		if record.ttl == 0 {
+			record.ttl = stored.ttl
+		}
+
+		m.set(record)
+		result = append(result, record)
+	}
+
+	return result, nil
+}
+
+// Get - возвращает запись по идентификатору key: имени метрики без меток
+// или результату metric.Key для метрики с метками.
+func (m *memStorage) Get(key string) (Record, bool) {
 	m.Lock()
 	defer m.Unlock()
-	record, ok := m.data[name]
+	record, ok := m.data[key]
 
 	return record, ok
 }
@@ -47,6 +96,63 @@ func (m *memStorage) GetAll() []Record {
 	return records
 }
 
+func (m *memStorage) Scan(after string, fn func(Record) bool) error {
+	m.Lock()
+	defer m.Unlock()
+
+	i := sort.SearchStrings(m.keys, after)
+	if i < len(m.keys) && m.keys[i] == after {
+		i++
+	}
+
+	for ; i < len(m.keys); i++ {
+		if !fn(m.data[m.keys[i]]) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *memStorage) Delete(keys ...string) ([]Record, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	deleted := make([]Record, 0, len(keys))
+
+	for _, key := range keys {
+		if record, ok := m.data[key]; ok {
+			m.remove(key)
+			deleted = append(deleted, record)
+		}
+	}
+
+	return deleted, nil
+}
+
+func (m *memStorage) Expire(now time.Time, ttl time.Duration) ([]Record, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	deleted := make([]Record, 0)
+
+	for key, record := range m.data {
+		expiry := ttl
+		if record.ttl > 0 {
+			expiry = record.ttl
+		}
+
+		if expiry <= 0 || !m.updated[key].Add(expiry).Before(now) {
+			continue
+		}
+
+		m.remove(key)
+		deleted = append(deleted, record)
+	}
+
+	return deleted, nil
+}
+
 func (m *memStorage) GetSnapShot() *memStorage {
 	m.Lock()
 	defer m.Unlock()
@@ -57,5 +163,53 @@ func (m *memStorage) GetSnapShot() *memStorage {
 		snap[k] = v
 	}
 
-	return &memStorage{data: snap}
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+
+	return &memStorage{data: snap, keys: keys}
+}
+
+// set - сохраняет запись и время её изменения.
+func (m *memStorage) set(record Record) {
+	key := record.Key()
+	if _, ok := m.data[key]; !ok {
+		i := sort.SearchStrings(m.keys, key)
+		m.keys = append(m.keys, "")
+		copy(m.keys[i+1:], m.keys[i:])
+		m.keys[i] = key
+	}
+
+	m.data[key] = record
+	m.updated[key] = m.now()
+}
+
+// remove - удаляет запись с идентификатором key.
+func (m *memStorage) remove(key string) {
+	delete(m.data, key)
+	delete(m.updated, key)
+
+	i := sort.SearchStrings(m.keys, key)
+	if i < len(m.keys) && m.keys[i] == key {
+		m.keys = append(m.keys[:i], m.keys[i+1:]...)
+	}
+}
+
+// reset - заменяет содержимое хранилища записями data, восстановленными
+// из файла. Срок хранения восстановленных записей отсчитывается заново.
+func (m *memStorage) reset(data map[string]Record) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := m.now()
+
+	m.data = data
+	m.keys = make([]string, 0, len(data))
+	m.updated = make(map[string]time.Time, len(data))
+
+	for key := range data {
+		m.keys = append(m.keys, key)
+		m.updated[key] = now
+	}
+
+	sort.Strings(m.keys)
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -59,6 +60,93 @@ func Test_Push(t *testing.T) {
 	}
 }
 
+func Test_PushBatch(t *testing.T) {
+	m := NewMemStorage()
+	records := []Record{
+		{name: "Alloc", value: metric.Gauge(12.3456)},
+		{name: "PollCount", value: metric.Counter(123)},
+		{name: "Random", value: metric.Gauge(1313.1313)},
+	}
+
+	err := m.PushBatch(records)
+	require.NoError(t, err)
+	require.ElementsMatch(t, records, m.GetAll())
+}
+
+func Test_Labels(t *testing.T) {
+	m := NewMemStorage()
+	a := Record{name: "Requests", labels: metric.Labels{"host": "a"}, value: metric.Counter(1)}
+	b := Record{name: "Requests", labels: metric.Labels{"host": "b"}, value: metric.Counter(2)}
+
+	require.NoError(t, m.PushBatch([]Record{a, b}))
+
+	got, err := m.Update([]Record{
+		{name: "Requests", labels: metric.Labels{"host": "a"}, value: metric.Counter(10)},
+		{name: "Requests", value: metric.Counter(5)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, metric.Counter(11), got[0].GetValue())
+	require.Equal(t, metric.Counter(5), got[1].GetValue())
+
+	record, ok := m.Get(`Requests{host="b"}`)
+	require.True(t, ok)
+	require.Equal(t, b, record)
+
+	record, ok = m.Get("Requests")
+	require.True(t, ok)
+	require.Nil(t, record.GetLabels())
+	require.Len(t, m.GetAll(), 3)
+}
+
+func Test_UpdateDistribution(t *testing.T) {
+	m := NewMemStorage()
+	h := metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{1, 1}, Sum: 2.5, Count: 2}
+	s := metric.Summary{Quantiles: []metric.Quantile{{Quantile: 0.5, Value: 1}}, Sum: 2, Count: 2}
+	sk := metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{1: 2}, Count: 2, Sum: 2, Min: 1, Max: 1}
+
+	_, err := m.Update([]Record{{name: "Latency", value: h}, {name: "Duration", value: s}, {name: "Size", value: sk}})
+	require.NoError(t, err)
+
+	got, err := m.Update([]Record{{name: "Latency", value: h}, {name: "Duration", value: metric.Summary{Sum: 1, Count: 1}}, {name: "Size", value: sk}})
+	require.NoError(t, err)
+	require.Equal(t, metric.Histogram{Bounds: []float64{1}, Buckets: []uint64{2, 2}, Sum: 5, Count: 4}, got[0].GetValue())
+	require.Equal(t, metric.Summary{Quantiles: s.Quantiles, Sum: 3, Count: 3}, got[1].GetValue())
+	require.Equal(t, metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{1: 4}, Count: 4, Sum: 4, Min: 1, Max: 1}, got[2].GetValue())
+
+	a, b := metric.Set{Registers: make([]uint8, 16)}, metric.Set{Registers: make([]uint8, 16)}
+	a.Registers[1], b.Registers[1], b.Registers[2] = 3, 2, 5
+
+	_, err = m.Update([]Record{{name: "Users", value: a}})
+	require.NoError(t, err)
+
+	got, err = m.Update([]Record{{name: "Users", value: b}})
+	require.NoError(t, err)
+	require.Equal(t, []uint8{0, 3, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, got[0].GetValue().(metric.Set).Registers)
+}
+
+func Test_Update(t *testing.T) {
+	m := NewMemStorage()
+	m.Push("PollCount", Record{name: "PollCount", value: metric.Counter(10)})
+	m.Push("Random", Record{name: "Random", value: metric.Gauge(1.5)})
+
+	got, err := m.Update([]Record{
+		{name: "PollCount", value: metric.Counter(5)},
+		{name: "Random", value: metric.Gauge(2.5)},
+		{name: "PollCount", value: metric.Counter(1)},
+		{name: "NewCount", value: metric.Counter(3)},
+	})
+	require.NoError(t, err)
+
+	want := []Record{
+		{name: "PollCount", value: metric.Counter(15)},
+		{name: "Random", value: metric.Gauge(2.5)},
+		{name: "PollCount", value: metric.Counter(16)},
+		{name: "NewCount", value: metric.Counter(3)},
+	}
+	require.Equal(t, want, got)
+	require.Equal(t, want[2], m.data["PollCount"])
+}
+
 func Test_Get(t *testing.T) {
 	m := NewMemStorage()
 	records := [...]Record{
@@ -136,6 +224,36 @@ func Test_GetAll(t *testing.T) {
 	require.Equal(t, len(records), len(got))
 }
 
+func Test_Scan(t *testing.T) {
+	m := NewMemStorage()
+	a := Record{name: "Alloc", value: metric.Gauge(1)}
+	b := Record{name: "Requests", labels: metric.Labels{"host": "b"}, value: metric.Counter(2)}
+	c := Record{name: "Requests", labels: metric.Labels{"host": "a"}, value: metric.Counter(3)}
+	d := Record{name: "HeapAlloc", value: metric.Gauge(4)}
+	require.NoError(t, m.PushBatch([]Record{a, b, c, d}))
+
+	scan := func(after string, limit int) []Record {
+		got := make([]Record, 0)
+		err := m.Scan(after, func(record Record) bool {
+			got = append(got, record)
+			return len(got) < limit
+		})
+		require.NoError(t, err)
+
+		return got
+	}
+
+	require.Equal(t, []Record{a, d, c, b}, scan("", 10))
+	require.Equal(t, []Record{a, d}, scan("", 2))
+	require.Equal(t, []Record{c, b}, scan(d.Key(), 10))
+	require.Equal(t, []Record{c}, scan("I", 1))
+	require.Empty(t, scan(b.Key(), 10))
+
+	_, err := m.Delete(d.Key())
+	require.NoError(t, err)
+	require.Equal(t, []Record{a, c, b}, scan("", 10))
+}
+
 func Test_GetSnapShot(t *testing.T) {
 	m := NewMemStorage()
 	records := [...]Record{
@@ -155,3 +273,47 @@ func Test_GetSnapShot(t *testing.T) {
 	require.ElementsMatch(t, r, rs)
 	require.Equal(t, len(r), len(rs))
 }
+
+func Test_Delete(t *testing.T) {
+	m := NewMemStorage()
+	a := Record{name: "Requests", labels: metric.Labels{"host": "a"}, value: metric.Counter(1)}
+	b := Record{name: "Alloc", value: metric.Gauge(2)}
+	require.NoError(t, m.PushBatch([]Record{a, b}))
+
+	deleted, err := m.Delete(a.Key(), "unknown")
+	require.NoError(t, err)
+	require.Equal(t, []Record{a}, deleted)
+
+	_, ok := m.Get(a.Key())
+	require.False(t, ok)
+	require.Equal(t, []Record{b}, m.GetAll())
+}
+
+func Test_Expire(t *testing.T) {
+	now := time.Now()
+	m := NewMemStorage()
+	m.now = func() time.Time { return now }
+
+	stale := Record{name: "Stale", value: metric.Gauge(1)}
+	short := Record{name: "Short", value: metric.Gauge(2), ttl: time.Second}
+	long := Record{name: "Long", value: metric.Gauge(3), ttl: time.Hour}
+	require.NoError(t, m.PushBatch([]Record{stale, short, long}))
+
+	// обновление без срока хранения сохраняет собственный срок записи.
+	got, err := m.Update([]Record{{name: "Short", value: metric.Gauge(4)}})
+	require.NoError(t, err)
+	require.Equal(t, time.Second, got[0].GetTTL())
+
+	deleted, err := m.Expire(now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	require.Equal(t, []Record{got[0]}, deleted)
+
+	deleted, err = m.Expire(now.Add(time.Minute), 10*time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+
+	deleted, err = m.Expire(now.Add(time.Minute), 30*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, []Record{stale}, deleted)
+	require.Equal(t, []Record{long}, m.GetAll())
+}
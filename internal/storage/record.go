@@ -3,29 +3,48 @@ package storage
 import (
 	"encoding/json"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/a-x-a/go-metric/internal/models/metric"
 )
 
 type (
 	Record struct {
-		name  string
-		value metric.Metric
+		name   string
+		labels metric.Labels
+		value  metric.Metric
+		// ttl - срок хранения записи без обновлений, 0 - срок хранения по умолчанию.
+		ttl time.Duration
+		// deleted - запись журнала об удалении метрики.
+		deleted bool
 	}
 )
 
 var (
-	// ErrInvalidName - не корректное имя записи.
+	// ErrInvalidName - не корректное имя записи: пустое или с символами `{`, `}` и `"`,
+	// из-за которых ключ метрики без меток совпал бы с ключом серии с метками.
 	ErrInvalidName = errors.New("record: a record has to have a valid name")
+	// ErrInvalidTTL - отрицательный срок хранения записи.
+	ErrInvalidTTL = errors.New("record: a record ttl has to be non-negative")
 )
 
 func NewRecord(name string) (Record, error) {
-	if name == "" {
-		return Record{}, ErrInvalidName
+	if err := ValidateName(name); err != nil {
+		return Record{}, err
 	}
 	return Record{name: name}, nil
 }
 
+// ValidateName - проверяет имя записи, см. ErrInvalidName.
+func ValidateName(name string) error {
+	if name == "" || strings.ContainsAny(name, `{}"`) {
+		return ErrInvalidName
+	}
+
+	return nil
+}
+
 func (r *Record) SetValue(value metric.Metric) {
 	r.value = value
 }
@@ -38,6 +57,44 @@ func (r *Record) GetName() string {
 	return r.name
 }
 
+// SetLabels - устанавливает метки записи. Пустой набор меток не сохраняется.
+func (r *Record) SetLabels(labels metric.Labels) {
+	if len(labels) == 0 {
+		r.labels = nil
+		return
+	}
+
+	r.labels = make(metric.Labels, len(labels))
+	for k, v := range labels {
+		r.labels[k] = v
+	}
+}
+
+func (r *Record) GetLabels() metric.Labels {
+	return r.labels
+}
+
+// SetTTL - устанавливает срок хранения записи без обновлений.
+// Нулевой срок означает срок хранения по умолчанию.
+func (r *Record) SetTTL(ttl time.Duration) error {
+	if ttl < 0 {
+		return ErrInvalidTTL
+	}
+
+	r.ttl = ttl
+
+	return nil
+}
+
+func (r *Record) GetTTL() time.Duration {
+	return r.ttl
+}
+
+// Key - возвращает идентификатор записи в хранилище: имя и метки метрики.
+func (r *Record) Key() string {
+	return metric.Key(r.name, r.labels)
+}
+
 func (r Record) MarshalJSON() ([]byte, error) {
 	j := recordToJSONMetric(r)
 	return json.Marshal(j)
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -27,6 +28,18 @@ func TestNewRecord(t *testing.T) {
 			want:       Record{},
 			wantErr:    true,
 		},
+		{
+			name:       "name with labels syntax",
+			recordName: `foo{x="1"}`,
+			want:       Record{},
+			wantErr:    true,
+		},
+		{
+			name:       "name with brace",
+			recordName: "foo}",
+			want:       Record{},
+			wantErr:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -117,4 +130,41 @@ func TestRecord_MarshalJSON(t *testing.T) {
 
 		require.NoError(err)
 	})
+
+	t.Run("ttl", func(t *testing.T) {
+		require := require.New(t)
+
+		r, err := NewRecord("Alloc")
+		require.NoError(err)
+
+		r.SetValue(metric.Gauge(1.5))
+		require.NoError(r.SetTTL(90 * time.Second))
+		require.ErrorIs(r.SetTTL(-time.Second), ErrInvalidTTL)
+
+		data, err := r.MarshalJSON()
+		require.NoError(err)
+		require.JSONEq(`{"name":"Alloc","type":"gauge","value":1.5,"ttl":"1m30s"}`, string(data))
+
+		got := Record{}
+		require.NoError(got.UnmarshalJSON(data))
+		require.Equal(r, got)
+	})
+
+	t.Run("compact sketch", func(t *testing.T) {
+		require := require.New(t)
+
+		r, err := NewRecord("Size")
+		require.NoError(err)
+
+		r.SetValue(metric.Sketch{RelativeAccuracy: 0.01, Positive: metric.SketchBins{2: 1, 4: 3}, Count: 4, Sum: 4.2, Min: 1, Max: 1.1})
+
+		data, err := r.MarshalJSON()
+		require.NoError(err)
+		require.JSONEq(`{"name":"Size","type":"sketch","sum":4.2,"count":4,
+			"sketch":{"accuracy":0.01,"min":1,"max":1.1,"positive":{"offset":2,"counts":[1,0,3]}}}`, string(data))
+
+		got := Record{}
+		require.NoError(got.UnmarshalJSON(data))
+		require.Equal(r, got)
+	})
 }
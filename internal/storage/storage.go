@@ -9,15 +9,49 @@ import (
 type (
 	Storage interface {
 		Push(name string, record Record) error
-		Get(name string) (Record, bool)
+		PushBatch(records []Record) error
+		// Update - атомарно применяет набор обновлений: значения датчиков (gauge)
+		// перезаписываются, значения счётчиков (counter) прибавляются к сохранённым.
+		// Возвращает итоговые записи в порядке следования обновлений.
+		Update(records []Record) ([]Record, error)
+		// Get - возвращает запись по идентификатору: имени метрики без меток
+		// или результату metric.Key для метрики с метками.
+		Get(key string) (Record, bool)
 		GetAll() []Record
+		// Scan - передаёт функции fn записи с идентификаторами больше after
+		// в порядке возрастания идентификаторов, пока fn возвращает true.
+		// Пустой after означает начало. Функция fn не должна обращаться к хранилищу.
+		Scan(after string, fn func(Record) bool) error
+		// Delete - удаляет записи с идентификаторами keys.
+		// Возвращает удалённые записи, отсутствующие записи пропускаются.
+		Delete(keys ...string) ([]Record, error)
+		// Expire - удаляет записи, не обновлявшиеся к моменту now дольше своего
+		// срока хранения, а записи без собственного срока - дольше срока ttl.
+		// Нулевой срок ttl не ограничивает хранение записей без собственного срока.
+		// Возвращает удалённые записи.
+		Expire(now time.Time, ttl time.Duration) ([]Record, error)
 	}
 )
 
-func NewDataStorage(path string, storeInterval time.Duration, log *zap.Logger) Storage {
+// NewDataStorage - создаёт хранилище метрик. Если указана строка подключения dsn,
+// то метрики хранятся в базе данных, иначе в памяти с сохранением в файл path.
+// При синхронной записи (storeInterval == 0) журнал сбрасывается на диск согласно политике fsync.
+func NewDataStorage(dsn, path string, storeInterval time.Duration, fsync string, log *zap.Logger) (Storage, error) {
+	if len(dsn) > 0 {
+		return NewDBStorage(dsn, log)
+	}
+
 	if len(path) == 0 {
-		return NewMemStorage()
+		return NewMemStorage(), nil
 	}
 
-	return NewWithFileStorage(path, storeInterval == 0, log)
+	policy, err := ParseFsyncPolicy(fsync)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := NewWithFileStorage(path, storeInterval == 0, log)
+	fs.setFsyncPolicy(policy)
+
+	return fs, nil
 }
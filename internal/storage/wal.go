@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"os"
+	"time"
+)
+
+type (
+	// FsyncPolicy - политика сброса журнала упреждающей записи на диск.
+	FsyncPolicy string
+
+	// wal - журнал упреждающей записи (write-ahead log).
+	// Каждая запись журнала: длина данных (4 байта), контрольная сумма CRC-32C
+	// данных (4 байта) и сами данные - запись хранилища в формате JSON.
+	// В журнал пишутся итоговые значения метрик, поэтому повторное применение
+	// записей не меняет состояние хранилища.
+	wal struct {
+		path     string
+		policy   FsyncPolicy
+		file     *os.File
+		records  int
+		lastSync time.Time
+		// unsynced - количество записей, не сброшенных на диск.
+		unsynced int
+	}
+)
+
+const (
+	// FsyncAlways - сбрасывать журнал на диск после каждой записи.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval - сбрасывать журнал на диск не чаще одного раза в walSyncInterval,
+	// но и не реже: записи, после которых сброса не было, сбрасываются по таймеру.
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever - не сбрасывать журнал на диск, полагаясь на операционную систему.
+	FsyncNever FsyncPolicy = "never"
+
+	// walSyncInterval - интервал сброса журнала на диск для политики FsyncInterval.
+	walSyncInterval = time.Second
+	// walHeaderSize - размер заголовка записи журнала.
+	walHeaderSize = 8
+)
+
+var (
+	// ErrInvalidFsyncPolicy - не корректная политика сброса журнала на диск.
+	ErrInvalidFsyncPolicy = errors.New("storage: invalid fsync policy")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ParseFsyncPolicy - возвращает политику сброса журнала для строкового представления.
+func ParseFsyncPolicy(policy string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(policy); p {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+		return p, nil
+	}
+
+	return "", ErrInvalidFsyncPolicy
+}
+
+func newWAL(path string, policy FsyncPolicy) *wal {
+	return &wal{path: path, policy: policy}
+}
+
+// append - дописывает записи в конец журнала.
+func (w *wal) append(records []Record) error {
+	if w.file == nil {
+		f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+
+		w.file = f
+	}
+
+	buf := make([]byte, 0)
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		header := make([]byte, walHeaderSize)
+		binary.BigEndian.PutUint32(header, uint32(len(data)))
+		binary.BigEndian.PutUint32(header[4:], crc32.Checksum(data, crcTable))
+
+		buf = append(buf, header...)
+		buf = append(buf, data...)
+	}
+
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+
+	w.records += len(records)
+	w.unsynced += len(records)
+
+	switch w.policy {
+	case FsyncAlways:
+		return w.sync()
+	case FsyncInterval:
+		if time.Since(w.lastSync) >= walSyncInterval {
+			return w.sync()
+		}
+	}
+
+	return nil
+}
+
+// replay - применяет к хранилищу записи журнала в порядке их добавления.
+// Повреждённая или недописанная запись и всё, что следует за ней, отбрасываются,
+// а журнал обрезается до последней целой записи.
+// Если журнала нет, то возвращает ошибку os.ErrNotExist.
+func (w *wal) replay(apply func(Record)) (int, error) {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, count := 0, 0
+
+	for offset < len(data) {
+		record, size, ok := decodeWALRecord(data[offset:])
+		if !ok {
+			if err := os.Truncate(w.path, int64(offset)); err != nil {
+				return count, err
+			}
+
+			break
+		}
+
+		apply(record)
+
+		offset += size
+		count++
+	}
+
+	w.records = count
+
+	return count, nil
+}
+
+// truncate - очищает журнал после сохранения снимка хранилища.
+func (w *wal) truncate() error {
+	w.records = 0
+
+	if w.file != nil {
+		if err := w.file.Truncate(0); err != nil {
+			return err
+		}
+
+		return w.sync()
+	}
+
+	err := os.Truncate(w.path, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (w *wal) sync() error {
+	w.lastSync = time.Now()
+	w.unsynced = 0
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.file.Sync()
+}
+
+// close - сбрасывает журнал на диск и закрывает его файл.
+// Следующая запись снова откроет файл журнала.
+func (w *wal) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+
+	w.file = nil
+
+	return err
+}
+
+func decodeWALRecord(data []byte) (Record, int, bool) {
+	if len(data) < walHeaderSize {
+		return Record{}, 0, false
+	}
+
+	size := int(binary.BigEndian.Uint32(data))
+	checksum := binary.BigEndian.Uint32(data[4:])
+
+	if len(data)-walHeaderSize < size {
+		return Record{}, 0, false
+	}
+
+	payload := data[walHeaderSize : walHeaderSize+size]
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return Record{}, 0, false
+	}
+
+	record := Record{}
+	if err := json.Unmarshal(payload, &record); err != nil || len(record.name) == 0 {
+		return Record{}, 0, false
+	}
+
+	return record, walHeaderSize + size, true
+}